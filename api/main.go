@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"gozap/core"
 	"gozap/domain"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -32,7 +37,10 @@ func main() {
 	   Executa as migrações do banco de dados.
 	   Isso garante que o esquema do banco de dados esteja atualizado.
 	*/
-	app.Postgres.RunMigrate()
+	err = app.Postgres.RunMigrate()
+	if err != nil {
+		log.Fatalf("Could not run migrations: %v", err)
+	}
 
 	/*
 	   Conecta ao banco de dados WhatsMeow.
@@ -53,6 +61,53 @@ func main() {
 	}
 	defer app.Messenger.Close()
 
+	/*
+	   O contexto é cancelado ao receber SIGINT ou SIGTERM, iniciando o encerramento gracioso
+	   do servidor HTTP, do relay do outbox e do consumer no modo de binário único.
+	*/
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	/*
+	   No modo de binário único, o consumer roda neste mesmo processo e compartilha o Messenger da API.
+	   É ativado com STANDALONE=true ou com MESSENGER_DRIVER=Memory, que não tem um servidor de mensageria
+	   e só entrega as mensagens dentro do próprio processo.
+	*/
+	consumerDone := make(chan struct{})
+	var redisConn *core.RedisClient
+	if standalone() {
+		/*
+		   O Redis só é usado na posse das sessões e na divisão das partições entre réplicas.
+		   Sem REDIS_DSN, o consumer do binário único roda como réplica única, sem posse das sessões,
+		   e o modo com MESSENGER_DRIVER=Memory não depende de nenhum servidor além do Postgres.
+		*/
+		if os.Getenv("REDIS_DSN") != "" {
+			redisConn, err = app.Redis.Connect()
+			if err != nil {
+				log.Fatalf("Could not connect to redis: %v", err)
+			}
+			defer redisConn.Close()
+		}
+
+		go func() {
+			defer close(consumerDone)
+			err := domain.Consumer{
+				Postgres:    postgresConn,
+				WhatsMeowDB: whatsMeowConn,
+				Redis:       redisConn,
+				Messenger:   app.Messenger,
+				NewMessenger: func() core.MessengerInterface {
+					return core.NewMessenger(core.ParseDriverMessage(os.Getenv("MESSENGER_DRIVER")))
+				},
+			}.Run(ctx)
+			if err != nil {
+				log.Fatalf("Could not consume messages: %v", err)
+			}
+		}()
+	} else {
+		close(consumerDone)
+	}
+
 	/*
 	   Cria um novo roteador usando o pacote chi.
 	   O middleware Logger é usado para registrar todas as solicitações HTTP.
@@ -64,30 +119,277 @@ func main() {
 	   Cria um novo manipulador para o serviço WhatsApp.
 	   O manipulador é responsável por lidar com as solicitações HTTP relacionadas ao WhatsApp.
 	*/
+	mediaRepository := domain.MediaRepository{
+		DB: postgresConn,
+	}
+	messageRepository := domain.MessageRepository{
+		DB: postgresConn,
+	}
+
+	/*
+	   Com OUTBOX_RELAY=true, a API publica as mensagens no outbox do Postgres, na mesma transação em que
+	   as registra, e o relay as encaminha ao driver MESSENGER_DRIVER.
+	   Com MESSENGER_DRIVER=Outbox, o próprio outbox é a fila e não há encaminhamento.
+	*/
+	publisher := app.Messenger
+	var relayOutbox core.MessengerInterface
+	if os.Getenv("OUTBOX_RELAY") == "true" && core.ParseDriverMessage(os.Getenv("MESSENGER_DRIVER")) != core.Outbox {
+		outbox := core.NewMessenger(core.Outbox)
+
+		/*
+		   O relay consome cada fila do outbox com um único worker, para encaminhar as mensagens em ordem de publicação.
+		*/
+		outbox.(*core.OutboxMessenger).Limits.Workers = 1
+		err = outbox.Connect()
+		if err != nil {
+			log.Fatalf("Could not connect to outbox: %v", err)
+		}
+		defer outbox.Close()
+
+		err = core.OutboxRelay{
+			Outbox: outbox,
+			Target: app.Messenger,
+			Queues: messageQueues(),
+			Retry: core.RetryPolicy{
+				BaseDelay: core.GetEnvDuration("MESSAGE_RETRY_DELAY", time.Second),
+				MaxDelay:  core.GetEnvDuration("MESSAGE_RETRY_MAX_DELAY", time.Minute),
+			},
+		}.Run(ctx)
+		if err != nil {
+			log.Fatalf("Could not start outbox relay: %v", err)
+		}
+		publisher = outbox
+		relayOutbox = outbox
+	}
+
+	whatsAppService := domain.WhatsAppService{
+		Messenger: publisher,
+		WhatsAppRepository: domain.WhatsAppRepository{
+			WhatsMeowDB: whatsMeowConn,
+			DB:          postgresConn,
+		},
+		SessionRepository: domain.SessionRepository{
+			DB: postgresConn,
+		},
+		MediaRepository:   mediaRepository,
+		MessageRepository: messageRepository,
+		Pairings:          domain.NewPairingRegistry(),
+		Partitions:        core.GetEnvInt("QUEUE_PARTITIONS", 0),
+		MediaRetention:    core.GetEnvDuration("MEDIA_RETENTION", 7*24*time.Hour),
+	}
 	handler := domain.WhatsAppHandler{
-		WhatsAppService: domain.WhatsAppService{
+		WhatsAppService: whatsAppService,
+	}
+
+	/*
+	   Cria um novo manipulador para o gerenciamento de contas.
+	*/
+	accountRepository := domain.AccountRepository{
+		DB: postgresConn,
+	}
+	accountHandler := domain.AccountHandler{
+		AccountService: domain.AccountService{
+			AccountRepository:  accountRepository,
+			WebhookSecretGrace: core.GetEnvDuration("WEBHOOK_SECRET_GRACE", 24*time.Hour),
+		},
+	}
+
+	/*
+	   Cria um novo manipulador para o ciclo de vida das sessões.
+	*/
+	sessionHandler := domain.SessionHandler{
+		SessionService: domain.SessionService{
+			SessionRepository: whatsAppService.SessionRepository,
+			AccountRepository: accountRepository,
+			WhatsAppService:   whatsAppService,
+		},
+	}
+
+	/*
+	   Cria um novo manipulador para os arquivos de mídia usados nas mensagens.
+	*/
+	mediaHandler := domain.MediaHandler{
+		MediaService: domain.MediaService{
+			MediaRepository: mediaRepository,
+		},
+	}
+
+	/*
+	   Cria um novo manipulador para a consulta do status das mensagens enviadas.
+	*/
+	messageHandler := domain.MessageHandler{
+		MessageService: domain.MessageService{
+			MessageRepository: messageRepository,
+			Messenger:         publisher,
+		},
+	}
+
+	/*
+	   Inicia o agendador, que publica as mensagens agendadas quando vencem.
+	   Com drivers de entrega agendada nativa, publica apenas as que não foram publicadas no agendamento.
+	*/
+	go domain.MessageScheduler{
+		MessageRepository: messageRepository,
+		Messenger:         publisher,
+		Interval:          core.GetEnvDuration("SCHEDULER_INTERVAL", time.Second),
+	}.Run(ctx)
+
+	/*
+	   Remove as mídias expiradas, como as guardadas a partir do base64 das mensagens após MEDIA_RETENTION.
+	*/
+	go domain.MediaPurger{
+		MediaRepository: mediaRepository,
+		Interval:        core.GetEnvDuration("MEDIA_PURGE_INTERVAL", time.Hour),
+	}.Run(ctx)
+
+	/*
+	   Cria um novo manipulador para as campanhas e inicia o envio dos destinatários pendentes.
+	   O envio usa o WhatsAppService, como as mensagens enviadas em /send.
+	*/
+	campaignRepository := domain.CampaignRepository{
+		DB: postgresConn,
+	}
+	campaignHandler := domain.CampaignHandler{
+		CampaignService: domain.CampaignService{
+			CampaignRepository: campaignRepository,
+			WhatsAppService:    whatsAppService,
+		},
+	}
+	go domain.CampaignRunner{
+		CampaignRepository: campaignRepository,
+		WhatsAppService:    whatsAppService,
+		Interval:           core.GetEnvDuration("CAMPAIGN_INTERVAL", time.Second),
+		BatchSize:          core.GetEnvInt("CAMPAIGN_BATCH_SIZE", 100),
+	}.Run(ctx)
+
+	/*
+	   Cria um novo manipulador para inspecionar e reprocessar a fila de mensagens mortas.
+	   Com OUTBOX_RELAY, inclui as mensagens que esgotaram as tentativas de encaminhamento do outbox.
+	*/
+	deadLetterHandler := domain.DeadLetterHandler{
+		DeadLetterService: domain.DeadLetterService{
 			Messenger: app.Messenger,
-			WhatsAppRepository: domain.WhatsAppRepository{
-				WhatsMeowDB: whatsMeowConn,
-				DB:          postgresConn,
-			},
+			Outbox:    relayOutbox,
+			Queues:    messageQueues(),
+		},
+	}
+
+	/*
+	   Cria um novo manipulador para a verificação de saúde usada pelos orquestradores.
+	   O driver de mensageria informa indisponível enquanto reconecta ao servidor.
+	*/
+	healthHandler := domain.HealthHandler{
+		HealthService: domain.HealthService{
+			Messenger: app.Messenger,
+			Postgres:  postgresConn,
+			Redis:     redisConn,
 		},
 	}
 
 	/*
 	   Define as rotas HTTP e os manipuladores correspondentes.
+	   /health: Manipulador para verificar a saúde da API e das suas dependências.
 	   /connect: Manipulador para conectar ao serviço WhatsApp.
+	   /connect/phone: Manipulador para conectar pelo número de telefone.
 	   /validate: Manipulador para validar dados.
-	   /send: Manipulador para enviar mensagens.
+	   /send: Manipulador para enviar mensagens de texto e de mídia.
+	   /media: Manipulador para enviar arquivos referenciados por mediaId.
+	   /messages: Manipulador para consultar o status das mensagens enviadas.
+	   /messages/scheduled: Manipuladores para listar, reagendar e cancelar as mensagens agendadas.
+	   /dead-letters: Manipuladores da fila de mensagens mortas.
+	   /accounts: Manipuladores para o gerenciamento de contas.
+	   /sessions: Manipuladores para o ciclo de vida das sessões.
+	   /campaigns: Manipuladores para o envio em massa e o acompanhamento das campanhas.
 	*/
+	r.Get("/health", healthHandler.Check)
 	r.Get("/connect", handler.Connect)
+	r.Post("/connect/phone", handler.ConnectPhone)
 	r.Post("/validate", handler.Validate)
 	r.Post("/send", handler.Send)
+	r.Post("/media", mediaHandler.Upload)
+	r.Get("/messages/scheduled", messageHandler.ListScheduled)
+	r.Get("/messages/{id}", messageHandler.GetByID)
+	r.Put("/messages/{id}/schedule", messageHandler.Reschedule)
+	r.Delete("/messages/{id}/schedule", messageHandler.Cancel)
+	r.Get("/dead-letters", deadLetterHandler.List)
+	r.Post("/dead-letters/{id}/replay", deadLetterHandler.Replay)
+
+	r.Route("/accounts", func(r chi.Router) {
+		r.Post("/", accountHandler.Create)
+		r.Get("/", accountHandler.List)
+		r.Get("/{id}", accountHandler.GetByID)
+		r.Put("/{id}", accountHandler.Update)
+		r.Delete("/{id}", accountHandler.Delete)
+		r.Post("/{id}/webhook-secret/rotate", accountHandler.RotateWebhookSecret)
+	})
+
+	r.Route("/sessions", func(r chi.Router) {
+		r.Post("/", sessionHandler.Create)
+		r.Get("/", sessionHandler.List)
+		r.Get("/{id}", sessionHandler.GetByID)
+		r.Get("/{id}/pairing/stream", sessionHandler.StreamPairing)
+		r.Post("/{id}/logout", sessionHandler.Logout)
+	})
+
+	r.Route("/campaigns", func(r chi.Router) {
+		r.Post("/", campaignHandler.Create)
+		r.Get("/", campaignHandler.List)
+		r.Get("/{id}", campaignHandler.GetByID)
+		r.Get("/{id}/recipients", campaignHandler.ListRecipients)
+		r.Post("/{id}/pause", campaignHandler.Pause)
+		r.Post("/{id}/resume", campaignHandler.Resume)
+		r.Post("/{id}/cancel", campaignHandler.Cancel)
+	})
 
 	/*
 	   Inicia o servidor HTTP na porta especificada.
 	   A porta é obtida a partir da variável de ambiente PORT.
 	*/
-	fmt.Println("API running on port " + os.Getenv("PORT"))
-	log.Fatal(http.ListenAndServe(":"+os.Getenv("PORT"), r))
+	server := &http.Server{
+		Addr:    ":" + os.Getenv("PORT"),
+		Handler: r,
+	}
+
+	go func() {
+		fmt.Println("API running on port " + os.Getenv("PORT"))
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	/*
+	   Ao receber o sinal de término, deixa de aceitar conexões e aguarda as requisições em andamento
+	   por no máximo SHUTDOWN_TIMEOUT. No modo de binário único, aguarda também o encerramento do consumer.
+	*/
+	<-ctx.Done()
+	log.Println("Shutting down API")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), core.GetEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("Error shutting down API: %v", err)
+	}
+	<-consumerDone
+}
+
+/*
+Função messageQueues retorna as filas de mensagens: a fila de cada partição, com QUEUE_PARTITIONS,
+ou apenas QUEUE_MESSAGE.
+*/
+func messageQueues() []string {
+	queue := os.Getenv("QUEUE_MESSAGE")
+	if partitions := core.GetEnvInt("QUEUE_PARTITIONS", 0); partitions > 0 {
+		return core.PartitionQueues(queue, partitions)
+	}
+	return []string{queue}
+}
+
+/*
+Função standalone indica se a API deve rodar também o consumer, no modo de binário único.
+*/
+func standalone() bool {
+	return os.Getenv("STANDALONE") == "true" || core.ParseDriverMessage(os.Getenv("MESSENGER_DRIVER")) == core.Memory
 }
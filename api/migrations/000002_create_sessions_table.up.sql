@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    account_id UUID REFERENCES accounts (id),
+    status VARCHAR(32) NOT NULL,
+    auth_code TEXT NOT NULL DEFAULT '',
+    jid VARCHAR(255),
+    push_name VARCHAR(255),
+    platform VARCHAR(100),
+    ready_at TIMESTAMPTZ,
+    failure_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS sessions_account_id_idx ON sessions (account_id);
+CREATE INDEX IF NOT EXISTS sessions_status_idx ON sessions (status);
@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS webhook_url TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE accounts DROP COLUMN IF EXISTS webhook_previous_secret_expires_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS webhook_previous_secret;
+ALTER TABLE accounts DROP COLUMN IF EXISTS webhook_secret;
@@ -0,0 +1,7 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS webhook_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS webhook_previous_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS webhook_previous_secret_expires_at TIMESTAMPTZ;
+
+UPDATE accounts
+SET webhook_secret = 'whsec_' || replace(gen_random_uuid()::text, '-', '') || replace(gen_random_uuid()::text, '-', '')
+WHERE webhook_secret = '';
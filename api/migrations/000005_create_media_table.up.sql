@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS media (
+    id VARCHAR(64) PRIMARY KEY,
+    mime_type VARCHAR(255) NOT NULL,
+    file_name VARCHAR(255) NOT NULL DEFAULT '',
+    size BIGINT NOT NULL,
+    data BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
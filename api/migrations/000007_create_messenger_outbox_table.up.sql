@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS messenger_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    queue VARCHAR(255) NOT NULL,
+    body BYTEA NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 1,
+    available_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_by VARCHAR(64),
+    locked_until TIMESTAMPTZ,
+    reason TEXT,
+    dead_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS messenger_outbox_pending_idx ON messenger_outbox (queue, id) WHERE dead_at IS NULL;
+CREATE INDEX IF NOT EXISTS messenger_outbox_dead_idx ON messenger_outbox (queue, id) WHERE dead_at IS NOT NULL;
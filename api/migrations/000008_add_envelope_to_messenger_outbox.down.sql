@@ -0,0 +1,3 @@
+ALTER TABLE messenger_outbox DROP COLUMN IF EXISTS content_type;
+ALTER TABLE messenger_outbox DROP COLUMN IF EXISTS headers;
+ALTER TABLE messenger_outbox DROP COLUMN IF EXISTS message_id;
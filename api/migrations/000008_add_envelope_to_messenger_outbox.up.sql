@@ -0,0 +1,3 @@
+ALTER TABLE messenger_outbox ADD COLUMN IF NOT EXISTS message_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE messenger_outbox ADD COLUMN IF NOT EXISTS headers JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE messenger_outbox ADD COLUMN IF NOT EXISTS content_type VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS scheduled_messages;
+DROP INDEX IF EXISTS messages_scheduled_idx;
+ALTER TABLE messages DROP COLUMN IF EXISTS scheduled_at;
@@ -0,0 +1,11 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS messages_scheduled_idx ON messages (scheduled_at) WHERE status = 'scheduled';
+
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+    id VARCHAR(64) PRIMARY KEY REFERENCES messages (id) ON DELETE CASCADE,
+    queue VARCHAR(255) NOT NULL,
+    body BYTEA NOT NULL,
+    published_at TIMESTAMPTZ,
+    locked_until TIMESTAMPTZ
+);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS campaigns (
+    id VARCHAR(64) PRIMARY KEY,
+    session_id VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    template JSONB NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS campaigns_session_id_idx ON campaigns (session_id);
+CREATE INDEX IF NOT EXISTS campaigns_status_idx ON campaigns (status);
+
+CREATE TABLE IF NOT EXISTS campaign_recipients (
+    id BIGSERIAL PRIMARY KEY,
+    campaign_id VARCHAR(64) NOT NULL REFERENCES campaigns (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    variables JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    message_id VARCHAR(64) REFERENCES messages (id) ON DELETE SET NULL,
+    error TEXT,
+    locked_until TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS campaign_recipients_campaign_position_idx ON campaign_recipients (campaign_id, position);
+CREATE INDEX IF NOT EXISTS campaign_recipients_pending_idx ON campaign_recipients (campaign_id, position) WHERE status = 'pending';
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS webhook_retries (
+    id VARCHAR(64) PRIMARY KEY,
+    envelope JSONB NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 0,
+    available_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webhook_retries_available_at_idx ON webhook_retries (available_at);
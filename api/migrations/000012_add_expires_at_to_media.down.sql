@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS media_expires_at_idx;
+
+ALTER TABLE media DROP COLUMN IF EXISTS expires_at;
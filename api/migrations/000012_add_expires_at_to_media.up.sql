@@ -0,0 +1,3 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS media_expires_at_idx ON media (expires_at) WHERE expires_at IS NOT NULL;
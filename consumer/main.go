@@ -1,12 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"gozap/core"
 	"gozap/domain"
 	"log"
+	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -34,57 +37,52 @@ func main() {
 	}
 	defer app.Messenger.Close()
 
-	sendMessage := domain.SendMessage{
-		WhatsAppRepository: domain.WhatsAppRepository{
-			WhatsMeowDB: whatsMeowConn,
-			DB:          postgresConn,
-		},
-	}
-
-	sessionManager := core.NewSessionManager()
-
-	handler := func(msg core.Message) {
-
-		ProcessMessage(sendMessage, sessionManager, msg)
+	/*
+	   Aguarda um sinal de término para encerrar o pool de clientes e os webhooks de forma limpa.
+	*/
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	/*
+	   Com HEALTH_PORT, expõe /health para os orquestradores verificarem o consumer,
+	   inclusive durante a reconexão ao servidor de mensageria.
+	*/
+	if port := os.Getenv("HEALTH_PORT"); port != "" {
+		healthHandler := domain.HealthHandler{
+			HealthService: domain.HealthService{
+				Messenger: app.Messenger,
+				Postgres:  postgresConn,
+				Redis:     redisConn,
+			},
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/health", healthHandler.Check)
+		server := &http.Server{
+			Addr:    ":" + port,
+			Handler: mux,
+		}
+		defer server.Close()
+
+		go func() {
+			err := server.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Could not start health server: %v", err)
+			}
+		}()
 	}
 
-	err = app.Messenger.Consume(os.Getenv("QUEUE_MESSAGE"), handler)
+	err = domain.Consumer{
+		Postgres:    postgresConn,
+		WhatsMeowDB: whatsMeowConn,
+		Redis:       redisConn,
+		Messenger:   app.Messenger,
+		NewMessenger: func() core.MessengerInterface {
+			return core.NewMessenger(core.ParseDriverMessage(os.Getenv("MESSENGER_DRIVER")))
+		},
+	}.Run(ctx)
 	if err != nil {
 		log.Fatalf("Could not consume messages: %v", err)
 	}
-
-	time.Sleep(5 * time.Second)
-	select {}
-}
-
-func ProcessMessage(sendMessage domain.SendMessage, sessionManager *core.SessionManager, msg core.Message) {
-
-	var incomingMsg domain.Message
-	err := json.Unmarshal(msg.Body, &incomingMsg)
-	if err != nil {
-		msg.Nack()
-		log.Printf("Error unmarshalling message: %v", err)
-		return
-	}
-
-	if !sessionManager.StartSession(incomingMsg.SessionId) {
-		log.Printf("Session %s already in process. Skipping message: %s", incomingMsg.SessionId, incomingMsg.Message)
-		msg.Nack()
-		return
-	}
-
-	err = sendMessage.Send(&domain.Message{
-		SessionId: incomingMsg.SessionId,
-		To:        incomingMsg.To,
-		Message:   incomingMsg.Message,
-	})
-	if err != nil {
-		log.Printf("Error sending message: %v", err)
-		msg.Nack()
-		return
-	}
-
-	log.Printf("Processing message for session %s: %s", incomingMsg.SessionId, incomingMsg.Message)
-	msg.Ack()
-	sessionManager.EndSession(incomingMsg.SessionId)
+	log.Println("Consumer stopped")
 }
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+Definição de variáveis de erro específicas para o estado das conexões com os servidores de mensageria.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrMessengerDisconnected = errors.New("messenger.disconnected: connection to the message broker is down")
+)
+
+/*
+Política de espera entre as tentativas de reconexão aos servidores de mensageria.
+*/
+var reconnectPolicy = RetryPolicy{
+	BaseDelay: time.Second,
+	MaxDelay:  30 * time.Second,
+}
+
+/*
+Estrutura connectionState acompanha o estado da conexão de um driver de mensageria.
+Publicações feitas durante uma queda aguardam a reconexão pelo canal ready, fechado quando a conexão é restabelecida.
+*/
+type connectionState struct {
+	mu        sync.Mutex
+	connected bool
+	ready     chan struct{}
+	lastError error
+}
+
+/*
+Função newConnectionState cria o estado de uma conexão ainda não estabelecida.
+*/
+func newConnectionState() *connectionState {
+	return &connectionState{
+		ready: make(chan struct{}),
+	}
+}
+
+/*
+Método up marca a conexão como estabelecida e libera as publicações em espera.
+*/
+func (s *connectionState) up() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastError = nil
+	if !s.connected {
+		s.connected = true
+		close(s.ready)
+	}
+}
+
+/*
+Método down marca a conexão como perdida, guardando o motivo para o health check.
+*/
+func (s *connectionState) down(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastError = err
+	if s.connected {
+		s.connected = false
+		s.ready = make(chan struct{})
+	}
+}
+
+/*
+Método wait aguarda a conexão ser estabelecida ou o contexto expirar.
+Retorna:
+- nil se a conexão estiver estabelecida.
+- ErrMessengerDisconnected com o motivo da queda, se o contexto expirar antes da reconexão.
+*/
+func (s *connectionState) wait(ctx context.Context) error {
+	s.mu.Lock()
+	ready := s.ready
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return s.health()
+	}
+}
+
+/*
+Método health retorna nil se a conexão estiver estabelecida ou o motivo da queda.
+Um estado nil indica um driver ainda não conectado.
+*/
+func (s *connectionState) health() error {
+	if s == nil {
+		return ErrMessengerDisconnected
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.connected {
+		return nil
+	}
+	if s.lastError != nil {
+		return fmt.Errorf("%w: %v", ErrMessengerDisconnected, s.lastError)
+	}
+	return ErrMessengerDisconnected
+}
+
+/*
+Tempo máximo de espera de uma publicação quando PublishTimeout não é informado.
+*/
+const defaultPublishTimeout = 10 * time.Second
+
+/*
+Função publishContext limita o contexto de uma publicação ao prazo timeout, ou a defaultPublishTimeout se zero.
+O prazo inclui a espera pela reconexão durante uma queda do servidor de mensageria.
+*/
+func publishContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = defaultPublishTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+/*
+Interface HealthInterface é implementada pelos drivers de mensageria que informam o estado da conexão.
+Health retorna nil se a conexão estiver ativa ou o motivo da falha.
+*/
+type HealthInterface interface {
+	Health() error
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+/*
+Definição de variáveis de erro específicas para as filas de mensagens mortas (dead-letter).
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrDeadLetterNotFound    = errors.New("deadletter.not_found: dead-letter entry not found")
+	ErrDeadLetterUnsupported = errors.New("deadletter.unsupported: messenger driver does not support dead-letter inspection")
+	ErrDeadLetterScanLimit   = errors.New("deadletter.scan_limit: dead-letter entry not found within the scanned entries")
+)
+
+/*
+Motivos gravados nas mensagens enviadas à fila de mensagens mortas pelos drivers.
+*/
+const (
+	DeadLetterReasonMaxAttempts = "max_attempts_exceeded"
+)
+
+/*
+Estrutura DeadLetter representa uma mensagem que esgotou as tentativas ou foi rejeitada.
+Campos:
+- ID: Identificador da entrada na fila de mensagens mortas.
+- Queue: Fila ou assunto de origem da mensagem.
+- MessageID: Identificador original da mensagem.
+- Headers: Cabeçalhos originais da mensagem.
+- Body: Conteúdo original da mensagem.
+- Attempt: Número de tentativas feitas antes do descarte.
+- Reason: Motivo do descarte.
+- FailedAt: Momento do descarte.
+*/
+type DeadLetter struct {
+	ID        string
+	Queue     string
+	MessageID string
+	Headers   map[string]string
+	Body      []byte
+	Attempt   int
+	Reason    string
+	FailedAt  time.Time
+}
+
+/*
+Interface DeadLetterInterface é implementada pelos drivers que permitem inspecionar
+e reprocessar a fila de mensagens mortas.
+*/
+type DeadLetterInterface interface {
+	ListDeadLetters(queueName string, limit int) ([]DeadLetter, error)
+	ReplayDeadLetter(queueName string, id string) error
+}
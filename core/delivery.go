@@ -0,0 +1,177 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+/*
+Estrutura ConsumerLimits define a concorrência do consumo e a quantidade de mensagens em processamento de um driver.
+Campos:
+- Workers: Quantidade de goroutines que entregam as mensagens de cada consumo ao handler.
+- Prefetch: Quantidade de mensagens recebidas do servidor antes da confirmação;
+Qos no RabbitMQ e tamanho do lote de leitura nos demais drivers.
+- MaxInFlight: Quantidade máxima de mensagens entregues e ainda não confirmadas, somando todos os consumos do driver.
+Atingido o limite, o recebimento aguarda as confirmações. Zero não limita.
+*/
+type ConsumerLimits struct {
+	Workers     int
+	Prefetch    int
+	MaxInFlight int
+}
+
+/*
+Método prefetch retorna Prefetch ou, se não informado, o valor padrão do driver.
+*/
+func (l ConsumerLimits) prefetch(fallback int) int {
+	if l.Prefetch <= 0 {
+		return fallback
+	}
+	return l.Prefetch
+}
+
+/*
+Estrutura inFlightLimit limita a quantidade de mensagens entregues e não confirmadas de um driver.
+Um limite nil não limita.
+*/
+type inFlightLimit struct {
+	slots chan struct{}
+}
+
+/*
+Função newInFlightLimit cria o limite de mensagens em processamento, ou nil se max for zero.
+*/
+func newInFlightLimit(max int) *inFlightLimit {
+	if max <= 0 {
+		return nil
+	}
+	return &inFlightLimit{
+		slots: make(chan struct{}, max),
+	}
+}
+
+/*
+Método acquire aguarda uma vaga entre as mensagens em processamento.
+Retorna false se o contexto for cancelado antes.
+*/
+func (l *inFlightLimit) acquire(ctx context.Context) bool {
+	if l == nil {
+		return ctx.Err() == nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+/*
+Método release libera a vaga de uma mensagem confirmada.
+*/
+func (l *inFlightLimit) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+/*
+Cabeçalho do envelope que define a ordem de entrega: mensagens com o mesmo valor são entregues ao handler
+pelo mesmo worker, na ordem em que foram recebidas. A API publica nele o ID da sessão.
+*/
+const HeaderOrderingKey = "Session-Id"
+
+/*
+Estrutura deliveryPool entrega as mensagens de um consumo ao handler por Workers goroutines.
+Cada mensagem vai para o worker escolhido pelo cabeçalho HeaderOrderingKey, ou pelo ID se não houver o cabeçalho,
+para que as mensagens da mesma sessão cheguem ao handler na ordem em que foram recebidas.
+A entrega aguarda uma vaga no limite de mensagens em processamento do driver e o worker da mensagem livre,
+bloqueando o recebimento do driver enquanto o consumidor não dá conta das mensagens.
+*/
+type deliveryPool struct {
+	ctx     context.Context
+	limit   *inFlightLimit
+	workers []chan Message
+}
+
+/*
+Função newDeliveryPool inicia os workers do consumo, encerrados com o cancelamento do contexto.
+*/
+func newDeliveryPool(ctx context.Context, limits ConsumerLimits, limit *inFlightLimit, handler func(Message)) *deliveryPool {
+	pool := &deliveryPool{
+		ctx:     ctx,
+		limit:   limit,
+		workers: make([]chan Message, max(limits.Workers, 1)),
+	}
+
+	for i := range pool.workers {
+		messages := make(chan Message)
+		pool.workers[i] = messages
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg := <-messages:
+					handler(msg)
+				}
+			}
+		}()
+	}
+
+	return pool
+}
+
+/*
+Método worker retorna o canal do worker que entrega a mensagem.
+*/
+func (p *deliveryPool) worker(msg Message) chan Message {
+	key, ok := msg.Headers[HeaderOrderingKey]
+	if !ok {
+		key = msg.ID
+	}
+	return p.workers[PartitionOf(key, len(p.workers))]
+}
+
+/*
+Método deliver entrega a mensagem a um worker, liberando sua vaga no Ack, Nack, Reject ou Requeue.
+Retorna false se o contexto for cancelado antes da entrega; nesse caso a mensagem não foi confirmada
+e cabe ao driver devolvê-la ao servidor.
+*/
+func (p *deliveryPool) deliver(msg Message) bool {
+	if !p.limit.acquire(p.ctx) {
+		return false
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(p.limit.release)
+	}
+	ack, nack, reject, requeue := msg.Ack, msg.Nack, msg.Reject, msg.Requeue
+	msg.Ack = func() error {
+		defer release()
+		return ack()
+	}
+	msg.Nack = func() error {
+		defer release()
+		return nack()
+	}
+	msg.Reject = func(reason string) error {
+		defer release()
+		return reject(reason)
+	}
+	msg.Requeue = func() error {
+		defer release()
+		return requeue()
+	}
+
+	select {
+	case p.worker(msg) <- msg:
+		return true
+	case <-p.ctx.Done():
+		release()
+		return false
+	}
+}
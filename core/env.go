@@ -0,0 +1,39 @@
+package core
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+Função GetEnvDuration lê uma variável de ambiente no formato aceito por time.ParseDuration (ex: "10m", "30s").
+Parâmetros:
+- key: Nome da variável de ambiente.
+- fallback: Valor retornado quando a variável está vazia ou é inválida.
+Retorna:
+- A duração configurada ou o valor padrão.
+*/
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+/*
+Função GetEnvInt lê uma variável de ambiente numérica.
+Parâmetros:
+- key: Nome da variável de ambiente.
+- fallback: Valor retornado quando a variável está vazia ou é inválida.
+Retorna:
+- O inteiro configurado ou o valor padrão.
+*/
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,16 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+/*
+Função newRandomID gera um identificador aleatório de 128 bits em hexadecimal.
+Usado nos IDs das mensagens mortas e nos tokens dos bloqueios.
+*/
+func newRandomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
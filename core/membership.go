@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+/*
+Estrutura Membership mantém o registro desta instância em um grupo no Redis por meio de heartbeats
+e acompanha os demais membros ativos. Um membro que para de enviar heartbeats sai do grupo após TTL.
+Campos:
+- Redis: Cliente Redis usado no registro.
+- Key: Chave do grupo no Redis.
+- InstanceID: Identificador desta instância no grupo.
+- TTL: Tempo sem heartbeat após o qual um membro é considerado morto.
+- OnChange: Função chamada com a lista de membros sempre que ela muda.
+OnChange é chamada em uma goroutine própria, para que uma redistribuição demorada não atrase os heartbeats;
+se a lista mudar de novo durante a chamada, apenas a lista mais recente é entregue em seguida.
+*/
+type Membership struct {
+	Redis      *RedisClient
+	Key        string
+	InstanceID string
+	TTL        time.Duration
+	OnChange   func(members []string)
+
+	mu      sync.Mutex
+	members []string
+}
+
+/*
+Método Run envia heartbeats a cada TTL/3 e atualiza a lista de membros até o contexto ser cancelado.
+Ao encerrar, a instância sai do grupo para que as demais assumam sua parte imediatamente,
+e Run retorna depois que a chamada de OnChange em andamento terminar.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento.
+*/
+func (m *Membership) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.TTL / 3)
+	defer ticker.Stop()
+
+	changes := make(chan []string, 1)
+	done := make(chan struct{})
+	go m.notify(changes, done)
+	defer func() {
+		close(changes)
+		<-done
+	}()
+
+	for {
+		if members, changed := m.refresh(); changed {
+			/*
+			   Descarta a lista ainda não entregue, substituindo-a pela mais recente.
+			*/
+			select {
+			case <-changes:
+			default:
+			}
+			changes <- members
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := m.Redis.Leave(m.Key, m.InstanceID); err != nil {
+				log.Printf("Error leaving %s: %v", m.Key, err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Método Members retorna os membros ativos conhecidos, em ordem alfabética.
+*/
+func (m *Membership) Members() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.members
+}
+
+/*
+Método notify chama OnChange com cada lista recebida, até o canal ser fechado.
+*/
+func (m *Membership) notify(changes <-chan []string, done chan<- struct{}) {
+	defer close(done)
+
+	for members := range changes {
+		if m.OnChange != nil {
+			m.OnChange(members)
+		}
+	}
+}
+
+/*
+Método refresh envia o heartbeat e relê os membros.
+Em caso de falha no Redis, a última lista conhecida é mantida.
+Retorna:
+- A lista de membros e se ela mudou.
+*/
+func (m *Membership) refresh() ([]string, bool) {
+	if err := m.Redis.Heartbeat(m.Key, m.InstanceID, m.TTL); err != nil {
+		log.Printf("Error sending heartbeat to %s: %v", m.Key, err)
+		return nil, false
+	}
+
+	members, err := m.Redis.Members(m.Key)
+	if err != nil {
+		log.Printf("Error listing members of %s: %v", m.Key, err)
+		return nil, false
+	}
+
+	m.mu.Lock()
+	changed := !slices.Equal(m.members, members)
+	m.members = members
+	m.mu.Unlock()
+
+	if changed {
+		log.Printf("Members of %s changed: %v", m.Key, members)
+	}
+	return members, changed
+}
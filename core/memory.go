@@ -0,0 +1,498 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+/*
+Definição de variáveis de erro específicas para o driver de mensageria em memória.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrMemoryNotConnected = errors.New("memory.not_connected: messenger is not connected")
+)
+
+/*
+Estrutura memoryBroker guarda as filas do driver em memória.
+Todas as instâncias de MemoryMessenger do processo compartilham o mesmo broker,
+assim como as conexões dos outros drivers compartilham o mesmo servidor.
+*/
+type memoryBroker struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+}
+
+/*
+Estrutura memoryQueue representa uma fila do broker em memória.
+Campos:
+- pending: Mensagens aguardando entrega, em ordem de chegada.
+- deadLetters: Mensagens que esgotaram as tentativas ou foram rejeitadas.
+- ready: Sinaliza aos consumidores que há mensagens ou que uma conexão foi fechada.
+*/
+type memoryQueue struct {
+	pending     []memoryDelivery
+	deadLetters []memoryDeadLetter
+	ready       *sync.Cond
+}
+
+/*
+Estrutura memoryDelivery representa uma mensagem na fila com o número da sua próxima tentativa.
+redelivered indica que a mensagem voltou à fila sem confirmação, pelo fechamento da conexão que a recebeu.
+*/
+type memoryDelivery struct {
+	envelope    Envelope
+	attempt     int
+	redelivered bool
+}
+
+/*
+Estrutura memoryDeadLetter guarda uma mensagem morta com o envelope original, restaurado no reprocessamento.
+*/
+type memoryDeadLetter struct {
+	DeadLetter
+	envelope Envelope
+}
+
+var defaultMemoryBroker = &memoryBroker{
+	queues: map[string]*memoryQueue{},
+}
+
+/*
+Método queue retorna a fila com o nome informado, criando-a se ainda não existir.
+Deve ser chamado com mu bloqueado.
+*/
+func (b *memoryBroker) queue(queueName string) *memoryQueue {
+	q, ok := b.queues[queueName]
+	if !ok {
+		q = &memoryQueue{
+			ready: sync.NewCond(&b.mu),
+		}
+		b.queues[queueName] = q
+	}
+	return q
+}
+
+/*
+Método enqueue adiciona a mensagem ao fim da fila e acorda um consumidor.
+*/
+func (b *memoryBroker) enqueue(queueName string, delivery memoryDelivery) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q := b.queue(queueName)
+	q.pending = append(q.pending, delivery)
+	q.ready.Signal()
+}
+
+/*
+Estrutura MemoryMessenger implementa MessengerInterface sem um servidor de mensageria,
+entregando as mensagens dentro do próprio processo.
+É usada em testes e no modo de binário único, em que a API e o consumer rodam no mesmo processo.
+As confirmações seguem a semântica dos outros drivers:
+- Ack remove a mensagem da fila.
+- Nack a reentrega após a espera da política Retry ou, esgotadas as tentativas, a envia à fila de mensagens mortas.
+- Reject a envia diretamente à fila de mensagens mortas.
+- Mensagens sem confirmação quando a conexão é fechada voltam ao início da fila.
+As mensagens não sobrevivem ao fim do processo.
+As mensagens são entregues ao handler por Limits.Workers goroutines, até Limits.MaxInFlight não confirmadas.
+*/
+type MemoryMessenger struct {
+	Retry  RetryPolicy
+	Limits ConsumerLimits
+
+	broker    *memoryBroker
+	connected bool
+	inFlight  map[*memoryInFlight]struct{}
+	received  uint64
+	limit     *inFlightLimit
+}
+
+/*
+Estrutura memoryInFlight representa uma mensagem entregue e ainda não confirmada.
+seq é a ordem em que a mensagem foi recebida por esta conexão, usada para devolvê-la à fila na mesma ordem.
+*/
+type memoryInFlight struct {
+	queueName string
+	delivery  memoryDelivery
+	seq       uint64
+}
+
+/*
+Método Connect associa o driver ao broker do processo.
+Retorna um erro, se houver.
+*/
+func (client *MemoryMessenger) Connect() error {
+	if client.broker == nil {
+		client.broker = defaultMemoryBroker
+	}
+
+	client.broker.mu.Lock()
+	defer client.broker.mu.Unlock()
+
+	client.connected = true
+	client.inFlight = map[*memoryInFlight]struct{}{}
+	client.limit = newInFlightLimit(client.Limits.MaxInFlight)
+	return nil
+}
+
+/*
+Método Publish adiciona uma mensagem à fila especificada.
+O envelope é copiado, para que alterações do publicador não afetem a mensagem enfileirada.
+Retorna ErrMemoryNotConnected se o driver não estiver conectado.
+*/
+func (client *MemoryMessenger) Publish(ctx context.Context, queueName string, envelope Envelope) error {
+	if !client.isConnected() {
+		return ErrMemoryNotConnected
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	envelope = envelope.withDefaults()
+	envelope.Body = append([]byte(nil), envelope.Body...)
+	envelope.Headers = maps.Clone(envelope.Headers)
+
+	client.broker.enqueue(queueName, memoryDelivery{
+		envelope: envelope,
+		attempt:  1,
+	})
+	return nil
+}
+
+/*
+Método PublishAt adiciona a mensagem à fila especificada a partir de at.
+Mensagens agendadas não sobrevivem ao fim do processo.
+Retorna ErrMemoryNotConnected se o driver não estiver conectado.
+*/
+func (client *MemoryMessenger) PublishAt(ctx context.Context, queueName string, envelope Envelope, at time.Time) error {
+	if !client.isConnected() {
+		return ErrMemoryNotConnected
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	envelope = envelope.withDefaults()
+	envelope.Body = append([]byte(nil), envelope.Body...)
+	envelope.Headers = maps.Clone(envelope.Headers)
+
+	time.AfterFunc(time.Until(at), func() {
+		client.broker.enqueue(queueName, memoryDelivery{
+			envelope: envelope,
+			attempt:  1,
+		})
+	})
+	return nil
+}
+
+/*
+Método Consume consome mensagens da fila especificada em uma goroutine, até a conexão ser fechada
+ou o contexto ser cancelado.
+Vários consumidores da mesma fila dividem as mensagens entre si.
+Retorna ErrMemoryNotConnected se o driver não estiver conectado.
+*/
+func (client *MemoryMessenger) Consume(ctx context.Context, queueName string, handler func(Message)) error {
+	if !client.isConnected() {
+		return ErrMemoryNotConnected
+	}
+
+	pool := newDeliveryPool(ctx, client.Limits, client.limit, handler)
+
+	go func() {
+		stop := context.AfterFunc(ctx, func() {
+			client.broker.mu.Lock()
+			defer client.broker.mu.Unlock()
+			client.broker.queue(queueName).ready.Broadcast()
+		})
+		defer stop()
+
+		for {
+			inFlight, ok := client.next(ctx, queueName)
+			if !ok {
+				return
+			}
+			if !pool.deliver(client.message(inFlight)) {
+				client.requeue(inFlight)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+/*
+Método next aguarda a próxima mensagem da fila e a registra como não confirmada.
+Retorna false quando a conexão é fechada ou o contexto é cancelado.
+*/
+func (client *MemoryMessenger) next(ctx context.Context, queueName string) (*memoryInFlight, bool) {
+	client.broker.mu.Lock()
+	defer client.broker.mu.Unlock()
+
+	q := client.broker.queue(queueName)
+	for client.connected && ctx.Err() == nil && len(q.pending) == 0 {
+		q.ready.Wait()
+	}
+	if !client.connected || ctx.Err() != nil {
+		return nil, false
+	}
+
+	client.received++
+	inFlight := &memoryInFlight{
+		queueName: queueName,
+		delivery:  q.pending[0],
+		seq:       client.received,
+	}
+	q.pending = q.pending[1:]
+	client.inFlight[inFlight] = struct{}{}
+	return inFlight, true
+}
+
+/*
+Método settle remove a mensagem das não confirmadas.
+Retorna false se ela já tiver sido confirmada ou devolvida à fila pelo fechamento da conexão.
+*/
+func (client *MemoryMessenger) settle(inFlight *memoryInFlight) bool {
+	client.broker.mu.Lock()
+	defer client.broker.mu.Unlock()
+
+	if _, ok := client.inFlight[inFlight]; !ok {
+		return false
+	}
+	delete(client.inFlight, inFlight)
+	return true
+}
+
+/*
+Método requeue devolve ao início da fila uma mensagem recebida e não entregue ao handler.
+*/
+func (client *MemoryMessenger) requeue(inFlight *memoryInFlight) {
+	client.broker.mu.Lock()
+	defer client.broker.mu.Unlock()
+
+	if _, ok := client.inFlight[inFlight]; !ok {
+		return
+	}
+	delete(client.inFlight, inFlight)
+
+	q := client.broker.queue(inFlight.queueName)
+	q.pending = append([]memoryDelivery{inFlight.delivery}, q.pending...)
+	q.ready.Signal()
+}
+
+/*
+Método message cria a Message entregue ao handler, com as funções de confirmação.
+*/
+func (client *MemoryMessenger) message(inFlight *memoryInFlight) Message {
+	attempt := inFlight.delivery.attempt
+
+	return Message{
+		Envelope:    inFlight.delivery.envelope,
+		Attempt:     attempt,
+		MaxAttempts: client.Retry.MaxAttempts,
+		Redelivered: attempt > 1 || inFlight.delivery.redelivered,
+		Ack: func() error {
+			client.settle(inFlight)
+			return nil
+		},
+		Nack: func() error {
+			if !client.settle(inFlight) {
+				return nil
+			}
+			if !client.Retry.CanRetry(attempt) {
+				client.deadLetter(inFlight, DeadLetterReasonMaxAttempts)
+				return nil
+			}
+
+			client.retry(inFlight, attempt+1, client.Retry.Delay(attempt))
+			return nil
+		},
+		Reject: func(reason string) error {
+			if client.settle(inFlight) {
+				client.deadLetter(inFlight, reason)
+			}
+			return nil
+		},
+		Requeue: func() error {
+			if client.settle(inFlight) {
+				client.retry(inFlight, attempt, client.Retry.Delay(1))
+			}
+			return nil
+		},
+	}
+}
+
+/*
+Método retry devolve a mensagem ao fim da fila após a espera informada, com o número da tentativa informado.
+*/
+func (client *MemoryMessenger) retry(inFlight *memoryInFlight, attempt int, delay time.Duration) {
+	retry := memoryDelivery{
+		envelope:    inFlight.delivery.envelope,
+		attempt:     attempt,
+		redelivered: true,
+	}
+	time.AfterFunc(delay, func() {
+		client.broker.enqueue(inFlight.queueName, retry)
+	})
+}
+
+/*
+Método deadLetter guarda a mensagem na fila de mensagens mortas da sua fila de origem.
+*/
+func (client *MemoryMessenger) deadLetter(inFlight *memoryInFlight, reason string) {
+	client.broker.mu.Lock()
+	defer client.broker.mu.Unlock()
+
+	envelope := inFlight.delivery.envelope
+	q := client.broker.queue(inFlight.queueName)
+	q.deadLetters = append(q.deadLetters, memoryDeadLetter{
+		DeadLetter: DeadLetter{
+			ID:        newRandomID(),
+			Queue:     inFlight.queueName,
+			MessageID: envelope.ID,
+			Headers:   envelope.Headers,
+			Body:      envelope.Body,
+			Attempt:   inFlight.delivery.attempt,
+			Reason:    reason,
+			FailedAt:  time.Now().UTC(),
+		},
+		envelope: envelope,
+	})
+
+	log.Printf("Message moved to %s dead letters after %d attempts: %s", inFlight.queueName, inFlight.delivery.attempt, reason)
+}
+
+/*
+Método ListDeadLetters lista as mensagens mortas da fila sem removê-las.
+Parâmetros:
+- queueName: Fila de origem.
+- limit: Quantidade máxima de mensagens.
+Retorna:
+- As mensagens mortas e um erro, se houver.
+*/
+func (client *MemoryMessenger) ListDeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	if !client.isConnected() {
+		return nil, ErrMemoryNotConnected
+	}
+
+	client.broker.mu.Lock()
+	defer client.broker.mu.Unlock()
+
+	deadLetters := client.broker.queue(queueName).deadLetters
+	res := make([]DeadLetter, 0, min(limit, len(deadLetters)))
+	for _, entry := range deadLetters {
+		if len(res) >= limit {
+			break
+		}
+		res = append(res, entry.DeadLetter)
+	}
+
+	return res, nil
+}
+
+/*
+Método ReplayDeadLetter devolve a mensagem morta ao fim da fila de origem, reiniciando as tentativas.
+Parâmetros:
+- queueName: Fila de origem.
+- id: Identificador da mensagem morta.
+Retorna:
+- ErrDeadLetterNotFound se a mensagem não existir, ou outro erro, se houver.
+*/
+func (client *MemoryMessenger) ReplayDeadLetter(queueName string, id string) error {
+	if !client.isConnected() {
+		return ErrMemoryNotConnected
+	}
+
+	client.broker.mu.Lock()
+	defer client.broker.mu.Unlock()
+
+	q := client.broker.queue(queueName)
+	for i, entry := range q.deadLetters {
+		if entry.ID != id {
+			continue
+		}
+
+		q.deadLetters = append(q.deadLetters[:i], q.deadLetters[i+1:]...)
+		q.pending = append(q.pending, memoryDelivery{
+			envelope: entry.envelope,
+			attempt:  1,
+		})
+		q.ready.Signal()
+		return nil
+	}
+
+	return ErrDeadLetterNotFound
+}
+
+/*
+Método isConnected indica se o driver está conectado.
+*/
+func (client *MemoryMessenger) isConnected() bool {
+	if client.broker == nil {
+		return false
+	}
+
+	client.broker.mu.Lock()
+	defer client.broker.mu.Unlock()
+	return client.connected
+}
+
+/*
+Método Health informa se o driver está conectado.
+*/
+func (client *MemoryMessenger) Health() error {
+	if !client.isConnected() {
+		return ErrMemoryNotConnected
+	}
+	return nil
+}
+
+/*
+Método Close encerra os consumidores desta conexão e devolve ao início das filas
+as mensagens entregues e ainda não confirmadas, na ordem em que foram recebidas.
+Retorna um erro, se houver.
+*/
+func (client *MemoryMessenger) Close() error {
+	if client.broker == nil {
+		return nil
+	}
+
+	client.broker.mu.Lock()
+	defer client.broker.mu.Unlock()
+
+	if !client.connected {
+		return nil
+	}
+	client.connected = false
+
+	/*
+	   As mensagens são devolvidas da mais recente para a mais antiga,
+	   para que a mais antiga termine no início da fila.
+	*/
+	unacked := make([]*memoryInFlight, 0, len(client.inFlight))
+	for inFlight := range client.inFlight {
+		unacked = append(unacked, inFlight)
+	}
+	slices.SortFunc(unacked, func(a, b *memoryInFlight) int {
+		return cmp.Compare(b.seq, a.seq)
+	})
+	for _, inFlight := range unacked {
+		delivery := inFlight.delivery
+		delivery.redelivered = true
+
+		q := client.broker.queue(inFlight.queueName)
+		q.pending = append([]memoryDelivery{delivery}, q.pending...)
+	}
+	client.inFlight = nil
+
+	for _, q := range client.broker.queues {
+		q.ready.Broadcast()
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+/*
+Função newTestMemoryMessenger conecta um MemoryMessenger ao broker informado, com um único worker.
+*/
+func newTestMemoryMessenger(t *testing.T, broker *memoryBroker) *MemoryMessenger {
+	t.Helper()
+
+	client := &MemoryMessenger{
+		Retry:  RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		Limits: ConsumerLimits{Workers: 1},
+		broker: broker,
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+/*
+Função receive aguarda a próxima mensagem entregue ao handler.
+*/
+func receive(t *testing.T, messages <-chan Message) Message {
+	t.Helper()
+
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+		return Message{}
+	}
+}
+
+func TestMemoryMessengerNackRedeliversUntilDeadLetter(t *testing.T) {
+	broker := &memoryBroker{queues: map[string]*memoryQueue{}}
+	client := newTestMemoryMessenger(t, broker)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := client.Publish(ctx, "q", Envelope{ID: "m1", Body: []byte("1")}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	messages := make(chan Message, 1)
+	if err := client.Consume(ctx, "q", func(msg Message) { messages <- msg }); err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+
+	first := receive(t, messages)
+	if first.Attempt != 1 || first.Redelivered {
+		t.Fatalf("first delivery: attempt %d, redelivered %v", first.Attempt, first.Redelivered)
+	}
+	_ = first.Nack()
+
+	second := receive(t, messages)
+	if second.ID != "m1" || second.Attempt != 2 || !second.Redelivered {
+		t.Fatalf("second delivery: id %s, attempt %d, redelivered %v", second.ID, second.Attempt, second.Redelivered)
+	}
+	_ = second.Nack()
+
+	deadLetters, err := client.ListDeadLetters("q", 10)
+	if err != nil {
+		t.Fatalf("list dead letters: %v", err)
+	}
+	if len(deadLetters) != 1 || deadLetters[0].MessageID != "m1" || deadLetters[0].Reason != DeadLetterReasonMaxAttempts {
+		t.Fatalf("dead letters: %+v", deadLetters)
+	}
+}
+
+func TestMemoryMessengerCloseRedeliversUnackedInOrder(t *testing.T) {
+	broker := &memoryBroker{queues: map[string]*memoryQueue{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := newTestMemoryMessenger(t, broker)
+	for _, id := range []string{"m1", "m2", "m3", "m4"} {
+		if err := first.Publish(ctx, "q", Envelope{ID: id, Body: []byte(id)}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	received := make(chan Message, 4)
+	if err := first.Consume(ctx, "q", func(msg Message) { received <- msg }); err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if msg := receive(t, received); msg.ID == "m2" {
+			_ = msg.Ack()
+		}
+	}
+	_ = first.Close()
+
+	second := newTestMemoryMessenger(t, broker)
+	redelivered := make(chan Message, 3)
+	if err := second.Consume(ctx, "q", func(msg Message) { redelivered <- msg }); err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	for _, id := range []string{"m1", "m3", "m4"} {
+		msg := receive(t, redelivered)
+		if msg.ID != id || !msg.Redelivered || msg.Attempt != 1 {
+			t.Fatalf("expected %s redelivered at attempt 1, got %s (redelivered %v, attempt %d)", id, msg.ID, msg.Redelivered, msg.Attempt)
+		}
+	}
+}
@@ -1,31 +1,110 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"os"
+	"time"
 )
 
+/*
+Definição de variáveis de erro comuns aos drivers de mensageria.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrPublishNotConfirmed = errors.New("messenger.publish_not_confirmed: the broker did not confirm the message")
+)
+
+/*
+Estrutura Envelope representa uma mensagem publicada, com seus metadados.
+Os drivers transportam o envelope completo, inclusive nas novas tentativas e na fila de mensagens mortas.
+Campos:
+- ID: Identificador da mensagem; gerado na publicação, se vazio.
+- Headers: Cabeçalhos livres, como o trace ID e o tenant.
+- ContentType: Tipo do conteúdo, por exemplo application/json.
+- Timestamp: Momento da publicação; preenchido na publicação, se vazio.
+- Body: Conteúdo da mensagem.
+*/
+type Envelope struct {
+	ID          string
+	Headers     map[string]string
+	ContentType string
+	Timestamp   time.Time
+	Body        []byte
+}
+
+/*
+Método withDefaults preenche o ID e o Timestamp vazios, antes da publicação.
+*/
+func (e Envelope) withDefaults() Envelope {
+	if e.ID == "" {
+		e.ID = newRandomID()
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	return e
+}
+
+/*
+Estrutura Message representa uma mensagem recebida de uma fila.
+Campos:
+- Envelope: Conteúdo e metadados publicados.
+- Attempt: Número da tentativa de processamento, começando em 1.
+- MaxAttempts: Número máximo de tentativas antes de a mensagem ir para a fila de mensagens mortas.
+- Redelivered: Indica que a mensagem já foi entregue antes, por uma nova tentativa ou pela queda de um consumidor.
+- Ack: Confirma o processamento.
+- Nack: Agenda uma nova tentativa com espera exponencial ou, esgotadas as tentativas, envia à fila de mensagens mortas.
+- Reject: Envia a mensagem diretamente à fila de mensagens mortas com o motivo informado.
+- Requeue: Devolve a mensagem à fila após a espera inicial da política Retry, sem contar uma tentativa;
+usado quando o consumidor não pode processá-la no momento.
+*/
 type Message struct {
-	Body []byte
-	Ack  func() error
-	Nack func() error
+	Envelope
+	Attempt     int
+	MaxAttempts int
+	Redelivered bool
+	Ack         func() error
+	Nack        func() error
+	Reject      func(reason string) error
+	Requeue     func() error
 }
 
+/*
+Interface MessengerInterface é implementada pelos drivers de mensageria.
+- Publish publica o envelope na fila; o contexto limita a espera pela publicação.
+- Consume inicia o consumo da fila em segundo plano e retorna; o cancelamento do contexto interrompe o recebimento.
+As mensagens já entregues ainda podem ser confirmadas depois do cancelamento, até Close.
+- Close encerra a conexão; mensagens entregues e não confirmadas voltam à fila.
+*/
 type MessengerInterface interface {
 	Connect() error
-	Publish(queueName string, body []byte) error
-	Consume(queueName string, handler func(Message)) error
+	Publish(ctx context.Context, queueName string, envelope Envelope) error
+	Consume(ctx context.Context, queueName string, handler func(Message)) error
 	Close() error
 }
 
+/*
+Interface DelayedPublisher é implementada pelos drivers com entrega agendada nativa:
+Outbox, pela data de disponibilidade, RedisStreams, pelo sorted set de novas tentativas, e Memory.
+PublishAt publica o envelope para ser entregue aos consumidores a partir de at; datas passadas são entregues imediatamente.
+*/
+type DelayedPublisher interface {
+	PublishAt(ctx context.Context, queueName string, envelope Envelope, at time.Time) error
+}
+
 type DriverMessage int
 
 const (
 	RabbitMQ DriverMessage = iota
 	Nats
+	Memory
+	RedisStreams
+	Outbox
 )
 
 func (d DriverMessage) String() string {
-	return [...]string{"RabbitMQ", "Nats"}[d]
+	return [...]string{"RabbitMQ", "Nats", "Memory", "RedisStreams", "Outbox"}[d]
 }
 
 func ParseDriverMessage(s string) DriverMessage {
@@ -34,6 +113,12 @@ func ParseDriverMessage(s string) DriverMessage {
 		return RabbitMQ
 	case "Nats":
 		return Nats
+	case "Memory":
+		return Memory
+	case "RedisStreams":
+		return RedisStreams
+	case "Outbox":
+		return Outbox
 	default:
 		return RabbitMQ
 	}
@@ -43,19 +128,84 @@ type Messenger struct {
 	DriverMessage DriverMessage
 }
 
+/*
+Função NewMessenger cria o driver de mensageria escolhido.
+As novas tentativas das mensagens seguem MESSAGE_MAX_ATTEMPTS, MESSAGE_RETRY_DELAY e MESSAGE_RETRY_MAX_DELAY.
+Nos drivers RabbitMQ e NATS, as publicações aguardam a reconexão por no máximo MESSENGER_PUBLISH_TIMEOUT.
+No NATS, os streams seguem NATS_STREAM_RETENTION, NATS_STREAM_MAX_AGE e NATS_STREAM_REPLICAS,
+e o consumer durável NATS_DURABLE confirma as entregas no prazo NATS_ACK_WAIT.
+Em todos os drivers, as mensagens de cada consumo são entregues ao handler por MESSENGER_WORKERS goroutines,
+com no máximo MESSENGER_MAX_IN_FLIGHT mensagens não confirmadas; MESSENGER_PREFETCH define o Qos do RabbitMQ
+e o lote de leitura dos demais drivers.
+*/
 func NewMessenger(driverMessage DriverMessage) MessengerInterface {
+	redisStreamGroup := os.Getenv("REDIS_STREAM_GROUP")
+	if redisStreamGroup == "" {
+		redisStreamGroup = "gozap"
+	}
+
+	retry := RetryPolicy{
+		MaxAttempts: GetEnvInt("MESSAGE_MAX_ATTEMPTS", 5),
+		BaseDelay:   GetEnvDuration("MESSAGE_RETRY_DELAY", time.Second),
+		MaxDelay:    GetEnvDuration("MESSAGE_RETRY_MAX_DELAY", time.Minute),
+	}
+	publishTimeout := GetEnvDuration("MESSENGER_PUBLISH_TIMEOUT", defaultPublishTimeout)
+	limits := ConsumerLimits{
+		Workers:     GetEnvInt("MESSENGER_WORKERS", 4),
+		Prefetch:    GetEnvInt("MESSENGER_PREFETCH", 0),
+		MaxInFlight: GetEnvInt("MESSENGER_MAX_IN_FLIGHT", 100),
+	}
+
 	switch driverMessage {
 	case RabbitMQ:
 		return &RabbitMQMessenger{
-			URL: os.Getenv("RABBITMQ_DSN"),
+			URL:            os.Getenv("RABBITMQ_DSN"),
+			Retry:          retry,
+			PublishTimeout: publishTimeout,
+			Limits:         limits,
 		}
 	case Nats:
 		return &NatsMessenger{
-			URL: os.Getenv("NATS_DSN"),
+			URL:            os.Getenv("NATS_DSN"),
+			Retry:          retry,
+			PublishTimeout: publishTimeout,
+			Stream: NatsStreamConfig{
+				Retention: ParseNatsRetention(os.Getenv("NATS_STREAM_RETENTION")),
+				MaxAge:    GetEnvDuration("NATS_STREAM_MAX_AGE", 0),
+				Replicas:  GetEnvInt("NATS_STREAM_REPLICAS", 1),
+			},
+			Durable: os.Getenv("NATS_DURABLE"),
+			AckWait: GetEnvDuration("NATS_ACK_WAIT", natsDefaultAckWait),
+			Limits:  limits,
+		}
+	case Memory:
+		return &MemoryMessenger{
+			Retry:  retry,
+			Limits: limits,
+		}
+	case RedisStreams:
+		return &RedisStreamsMessenger{
+			URL:       os.Getenv("REDIS_DSN"),
+			Group:     redisStreamGroup,
+			Retry:     retry,
+			MaxLen:    int64(GetEnvInt("REDIS_STREAM_MAX_LEN", 100000)),
+			ClaimIdle: GetEnvDuration("REDIS_STREAM_CLAIM_IDLE", time.Minute),
+			Limits:    limits,
+		}
+	case Outbox:
+		return &OutboxMessenger{
+			DSN:               os.Getenv("POSTGRES_DSN"),
+			Retry:             retry,
+			VisibilityTimeout: GetEnvDuration("OUTBOX_VISIBILITY_TIMEOUT", 30*time.Second),
+			PollInterval:      GetEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			Limits:            limits,
 		}
 	default:
 		return &RabbitMQMessenger{
-			URL: os.Getenv("RABBITMQ_DSN"),
+			URL:            os.Getenv("RABBITMQ_DSN"),
+			Retry:          retry,
+			PublishTimeout: publishTimeout,
+			Limits:         limits,
 		}
 	}
 }
@@ -1,8 +1,15 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
 )
@@ -16,6 +23,7 @@ var (
 	ErrNatsPublish          = errors.New("nats.publish_failed: failed to publish a message")
 	ErrNatsSubscribe        = errors.New("nats.subscribe_failed: failed to subscribe to a subject")
 	ErrNatsCloseConnection  = errors.New("nats.close_connection_failed: failed to close connection")
+	ErrNatsStream           = errors.New("nats.stream_failed: failed to provision or read a stream")
 )
 
 type NatsInterface interface {
@@ -23,19 +31,203 @@ type NatsInterface interface {
 
 /*
 Estrutura NatsMessenger que mantém a configuração e conexão do NATS.
+Cada assunto é guardado em um stream próprio, criado ou atualizado conforme Stream na primeira publicação ou consumo,
+e consumido por um consumer durável de pull, Durable, compartilhado pelas réplicas e mantido entre reinícios.
+As mensagens em processamento têm o prazo AckWait renovado com InProgress até a confirmação;
+entregas não confirmadas no prazo são reentregues; uma mensagem cuja última tentativa expirou sem confirmação
+é enviada à fila de mensagens mortas na entrega seguinte, sem ser entregue ao handler.
+As mensagens são buscadas em lotes de Limits.Prefetch e entregues ao handler por Limits.Workers goroutines.
+A conexão é refeita pelo cliente do NATS sem limite de tentativas, com espera exponencial.
+Após a reconexão, os streams e consumers removidos pelo servidor são criados novamente.
+Durante a queda, as publicações aguardam a reconexão por no máximo PublishTimeout.
 */
 type NatsMessenger struct {
-	URL  string
-	conn *nats.Conn
-	js   nats.JetStreamContext
+	URL            string
+	Retry          RetryPolicy
+	PublishTimeout time.Duration
+	Stream         NatsStreamConfig
+	Durable        string
+	AckWait        time.Duration
+	Limits         ConsumerLimits
+
+	conn      *nats.Conn
+	js        nats.JetStreamContext
+	state     *connectionState
+	ctx       context.Context
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+	consumers []*natsConsumer
+	streams   map[string]struct{}
+	inFlight  map[*nats.Msg]struct{}
+	limit     *inFlightLimit
+}
+
+/*
+Estrutura NatsStreamConfig define a configuração dos streams criados pelo driver.
+Campos:
+- Retention: Política de retenção; com WorkQueuePolicy, as mensagens são removidas após a confirmação.
+- MaxAge: Idade máxima das mensagens no stream; zero mantém as mensagens sem limite de idade.
+- Replicas: Número de réplicas do stream no cluster.
+*/
+type NatsStreamConfig struct {
+	Retention nats.RetentionPolicy
+	MaxAge    time.Duration
+	Replicas  int
+}
+
+/*
+Estrutura natsConsumer guarda um consumo ativo e a assinatura de pull do consumer durável.
+*/
+type natsConsumer struct {
+	ctx     context.Context
+	subject string
+	pool    *deliveryPool
+	sub     *nats.Subscription
+}
+
+/*
+Valores usados quando a configuração não é informada e limites da busca de mensagens do consumer.
+*/
+const (
+	natsDefaultDurable = "gozap"
+	natsDefaultAckWait = 30 * time.Second
+	natsDefaultFetch   = 10
+	natsFetchWait      = 5 * time.Second
+)
+
+/*
+Função ParseNatsRetention converte a política de retenção configurada: "limits", "interest" ou "workqueue".
+Retorna nats.WorkQueuePolicy para valores vazios ou desconhecidos.
+*/
+func ParseNatsRetention(s string) nats.RetentionPolicy {
+	switch strings.ToLower(s) {
+	case "limits":
+		return nats.LimitsPolicy
+	case "interest":
+		return nats.InterestPolicy
+	default:
+		return nats.WorkQueuePolicy
+	}
+}
+
+/*
+Cabeçalhos usados para transportar os metadados do envelope e descrever as mensagens mortas.
+Cabeçalhos com os prefixos "Gozap-" e "Nats-" são reservados e não fazem parte dos Headers do envelope.
+*/
+const (
+	natsHeaderAttempt     = "Gozap-Attempt"
+	natsHeaderPrior       = "Gozap-Prior-Attempts"
+	natsHeaderReason      = "Gozap-Reason"
+	natsHeaderSubject     = "Gozap-Subject"
+	natsHeaderFailedAt    = "Gozap-Failed-At"
+	natsHeaderMessageID   = "Gozap-Message-Id"
+	natsHeaderTimestamp   = "Gozap-Timestamp"
+	natsHeaderContentType = "Content-Type"
+)
+
+/*
+Cabeçalhos de controle das mensagens mortas e das mensagens devolvidas à fila com Requeue,
+removidos quando a mensagem é reprocessada.
+*/
+var natsDeadLetterHeaders = []string{natsHeaderAttempt, natsHeaderReason, natsHeaderSubject, natsHeaderFailedAt, natsHeaderPrior}
+
+/*
+Função natsDeadLetterSubject retorna o assunto de mensagens mortas do assunto informado.
+O prefixo "dlq." evita que o assunto seja capturado pelo stream de origem.
+*/
+func natsDeadLetterSubject(subject string) string {
+	return "dlq." + subject
+}
+
+/*
+Função natsStreamName retorna o nome do stream do assunto informado.
+Nomes de stream e de consumer não aceitam ".", "*" e ">".
+*/
+func natsStreamName(subject string) string {
+	return strings.ToUpper(natsName(subject))
 }
 
 /*
-Método Connect estabelece uma conexão com o NATS.
-Retorna um ponteiro para o NatsMessenger e um erro, se houver.
+Função natsDeadLetterStream retorna o nome do stream de mensagens mortas do assunto informado.
+*/
+func natsDeadLetterStream(subject string) string {
+	return "DLQ_" + natsStreamName(subject)
+}
+
+/*
+Função natsName substitui os caracteres não aceitos em nomes de stream e de consumer.
+*/
+func natsName(name string) string {
+	return strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_").Replace(name)
+}
+
+/*
+Função natsMsg cria a mensagem do NATS com o conteúdo do envelope e seus metadados nos cabeçalhos.
+*/
+func natsMsg(subject string, envelope Envelope) *nats.Msg {
+	msg := nats.NewMsg(subject)
+	msg.Data = envelope.Body
+	for key, value := range envelope.Headers {
+		msg.Header.Set(key, value)
+	}
+	msg.Header.Set(natsHeaderMessageID, envelope.ID)
+	msg.Header.Set(natsHeaderTimestamp, envelope.Timestamp.UTC().Format(time.RFC3339Nano))
+	if envelope.ContentType != "" {
+		msg.Header.Set(natsHeaderContentType, envelope.ContentType)
+	}
+	return msg
+}
+
+/*
+Função natsEnvelopeHeaders retorna os cabeçalhos livres da mensagem, sem os cabeçalhos reservados.
+*/
+func natsEnvelopeHeaders(header nats.Header) map[string]string {
+	res := map[string]string{}
+	for key := range header {
+		if strings.HasPrefix(key, "Gozap-") || strings.HasPrefix(key, "Nats-") || key == natsHeaderContentType {
+			continue
+		}
+		res[key] = header.Get(key)
+	}
+	return res
+}
+
+/*
+Função natsEnvelope converte os cabeçalhos e o conteúdo de uma mensagem do NATS no envelope publicado.
+*/
+func natsEnvelope(header nats.Header, data []byte) Envelope {
+	res := Envelope{
+		ID:          header.Get(natsHeaderMessageID),
+		Headers:     natsEnvelopeHeaders(header),
+		ContentType: header.Get(natsHeaderContentType),
+		Body:        data,
+	}
+	res.Timestamp, _ = time.Parse(time.RFC3339Nano, header.Get(natsHeaderTimestamp))
+	return res
+}
+
+/*
+Método Connect estabelece uma conexão com o NATS e inicia a renovação do prazo das mensagens em processamento.
+Quedas posteriores são acompanhadas pelos handlers de desconexão e reconexão do cliente.
+Retorna um erro, se a primeira conexão falhar.
 */
 func (client *NatsMessenger) Connect() error {
-	nc, err := nats.Connect(client.URL)
+	client.state = newConnectionState()
+
+	nc, err := nats.Connect(
+		client.URL,
+		nats.MaxReconnects(-1),
+		nats.CustomReconnectDelay(reconnectPolicy.Delay),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			client.state.down(err)
+			log.Printf("NATS connection lost, reconnecting: %v", err)
+		}),
+		nats.ReconnectHandler(func(_ *nats.Conn) {
+			log.Println("NATS reconnected")
+			client.state.up()
+			go client.resubscribe()
+		}),
+	)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrNatsConnectionFailed, err)
 	}
@@ -45,15 +237,68 @@ func (client *NatsMessenger) Connect() error {
 		return fmt.Errorf("%w: %v", ErrNatsConnectionFailed, err)
 	}
 	client.js = js
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	client.streams = map[string]struct{}{}
+	client.inFlight = map[*nats.Msg]struct{}{}
+	client.limit = newInFlightLimit(client.Limits.MaxInFlight)
+	client.state.up()
+
+	go client.extend()
 	return nil
 }
 
 /*
-Método Publish publica uma mensagem no assunto especificado.
+Método resubscribe provisiona novamente, após a reconexão, os streams e consumers dos consumos ativos.
+Streams e consumers removidos durante a queda, por exemplo com o reinício do servidor sem persistência,
+são criados novamente; as assinaturas de pull continuam válidas e voltam a buscar mensagens.
+*/
+func (client *NatsMessenger) resubscribe() {
+	client.mu.Lock()
+	clear(client.streams)
+	consumers := slices.Clone(client.consumers)
+	client.mu.Unlock()
+
+	for _, consumer := range consumers {
+		if consumer.ctx.Err() != nil {
+			continue
+		}
+
+		err := client.provision(consumer.subject)
+		if err != nil {
+			log.Printf("Error provisioning consumer of %s: %v", consumer.subject, err)
+		}
+	}
+}
+
+/*
+Método Health informa se a conexão com o NATS está ativa.
+*/
+func (client *NatsMessenger) Health() error {
+	return client.state.health()
+}
+
+/*
+Método Publish publica uma mensagem no assunto especificado, criando o stream do assunto, se necessário.
+O ID, o Timestamp, o ContentType e os Headers do envelope são enviados nos cabeçalhos da mensagem.
+Durante uma queda da conexão, a publicação aguarda a reconexão,
+por no máximo PublishTimeout ou até o contexto expirar.
 Retorna um erro, se houver.
 */
-func (client *NatsMessenger) Publish(subject string, body []byte) error {
-	_, err := client.js.Publish(subject, body)
+func (client *NatsMessenger) Publish(ctx context.Context, subject string, envelope Envelope) error {
+	ctx, cancel := publishContext(ctx, client.PublishTimeout)
+	defer cancel()
+
+	err := client.state.wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = client.ensureStream(subject)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.js.PublishMsg(natsMsg(subject, envelope.withDefaults()), nats.Context(ctx))
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrNatsPublish, err)
 	}
@@ -61,30 +306,526 @@ func (client *NatsMessenger) Publish(subject string, body []byte) error {
 }
 
 /*
-Método Subscribe consome mensagens do assunto especificado.
-Retorna um canal de mensagens (<-chan *nats.Msg) e um erro, se houver.
+Método Consume consome mensagens do assunto especificado pelo consumer durável Durable.
+Mensagens com Nack são reentregues após a espera da política Retry.
+Esgotadas as tentativas, ou com Reject, a mensagem é publicada em "dlq.<assunto>",
+guardado em um stream próprio, e encerrada no stream de origem.
+Com o cancelamento do contexto, a busca de mensagens é encerrada; o consumer durável é mantido no servidor,
+e as mensagens não confirmadas são reentregues ao fim do AckWait.
+Retorna um erro, se houver.
+*/
+func (client *NatsMessenger) Consume(ctx context.Context, subject string, handler func(Message)) error {
+	consumer := &natsConsumer{
+		ctx:     ctx,
+		subject: subject,
+		pool:    newDeliveryPool(ctx, client.Limits, client.limit, handler),
+	}
+
+	client.mu.Lock()
+	client.consumers = append(client.consumers, consumer)
+	client.mu.Unlock()
+
+	err := client.subscribe(consumer)
+	if err != nil {
+		client.unsubscribe(consumer)
+		return err
+	}
+
+	context.AfterFunc(ctx, func() {
+		client.unsubscribe(consumer)
+	})
+	return nil
+}
+
+/*
+Método unsubscribe encerra a assinatura do consumo e o remove da lista de consumos provisionados na reconexão.
+O consumer durável não é removido do servidor.
+*/
+func (client *NatsMessenger) unsubscribe(consumer *natsConsumer) {
+	client.mu.Lock()
+	client.consumers = slices.DeleteFunc(client.consumers, func(c *natsConsumer) bool {
+		return c == consumer
+	})
+	sub := consumer.sub
+	client.mu.Unlock()
+
+	if sub != nil {
+		_ = sub.Unsubscribe()
+	}
+}
+
+/*
+Método subscribe provisiona o stream, o stream de mensagens mortas e o consumer durável do assunto,
+cria a assinatura de pull ligada ao consumer e inicia a busca de mensagens em segundo plano.
+*/
+func (client *NatsMessenger) subscribe(consumer *natsConsumer) error {
+	err := client.provision(consumer.subject)
+	if err != nil {
+		return err
+	}
+
+	durable := client.durable()
+	sub, err := client.js.PullSubscribe(consumer.subject, durable, nats.Bind(natsStreamName(consumer.subject), durable))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNatsSubscribe, err)
+	}
+
+	client.mu.Lock()
+	consumer.sub = sub
+	client.mu.Unlock()
+
+	go client.fetch(consumer, sub)
+	return nil
+}
+
+/*
+Método fetch busca as mensagens do consumer em lotes de Limits.Prefetch e as entrega ao handler,
+até o cancelamento do contexto do consumo ou o fechamento do driver.
+O lote inteiro é registrado entre as mensagens em processamento antes da entrega,
+para que o prazo das mensagens que aguardam uma vaga também seja renovado.
+Mensagens recebidas depois do cancelamento são devolvidas ao servidor com Nak.
+Se o consumer for removido do servidor, ele é provisionado novamente.
+*/
+func (client *NatsMessenger) fetch(consumer *natsConsumer, sub *nats.Subscription) {
+	for consumer.ctx.Err() == nil && client.ctx.Err() == nil {
+		msgs, err := sub.Fetch(client.Limits.prefetch(natsDefaultFetch), nats.MaxWait(natsFetchWait))
+		if errors.Is(err, nats.ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			if consumer.ctx.Err() != nil || client.ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, nats.ErrConsumerNotFound) || errors.Is(err, nats.ErrConsumerDeleted) {
+				if err := client.provision(consumer.subject); err != nil {
+					log.Printf("Error provisioning consumer of %s: %v", consumer.subject, err)
+				}
+			} else {
+				log.Printf("Error fetching messages from %s: %v", consumer.subject, err)
+			}
+
+			select {
+			case <-time.After(reconnectPolicy.BaseDelay):
+			case <-consumer.ctx.Done():
+			case <-client.ctx.Done():
+			}
+			continue
+		}
+
+		client.mu.Lock()
+		for _, msg := range msgs {
+			client.inFlight[msg] = struct{}{}
+		}
+		client.mu.Unlock()
+
+		for _, msg := range msgs {
+			if consumer.ctx.Err() != nil {
+				client.settle(msg)
+				_ = msg.Nak()
+				continue
+			}
+
+			message := client.message(consumer.subject, msg)
+			if client.Retry.MaxAttempts > 0 && message.Attempt > client.Retry.MaxAttempts {
+				if err := message.Reject(DeadLetterReasonMaxAttempts); err != nil {
+					log.Printf("Error moving expired message of %s to dead letters: %v", consumer.subject, err)
+				}
+				continue
+			}
+			if !consumer.pool.deliver(message) {
+				client.settle(msg)
+				_ = msg.Nak()
+			}
+		}
+	}
+}
+
+/*
+Método message converte a mensagem recebida, que permanece entre as mensagens em processamento até Ack, Nack, Reject ou Requeue.
+A tentativa é o número de entregas da mensagem somado às tentativas das cópias anteriores, devolvidas com Requeue.
+*/
+func (client *NatsMessenger) message(subject string, msg *nats.Msg) Message {
+	attempt := 1
+	if meta, err := msg.Metadata(); err == nil {
+		attempt = int(meta.NumDelivered)
+	}
+	if prior, err := strconv.Atoi(msg.Header.Get(natsHeaderPrior)); err == nil {
+		attempt += prior
+	}
+
+	return Message{
+		Envelope:    natsEnvelope(msg.Header, msg.Data),
+		Attempt:     attempt,
+		MaxAttempts: client.Retry.MaxAttempts,
+		Redelivered: attempt > 1,
+		Ack: func() error {
+			client.settle(msg)
+			return msg.Ack()
+		},
+		Nack: func() error {
+			client.settle(msg)
+			if !client.Retry.CanRetry(attempt) {
+				return client.deadLetter(subject, msg, attempt, DeadLetterReasonMaxAttempts)
+			}
+			return msg.NakWithDelay(client.Retry.Delay(attempt))
+		},
+		Reject: func(reason string) error {
+			client.settle(msg)
+			return client.deadLetter(subject, msg, attempt, reason)
+		},
+		Requeue: func() error {
+			client.requeue(subject, msg, attempt)
+			return nil
+		},
+	}
+}
+
+/*
+Método requeue devolve a mensagem à fila após a espera inicial da política Retry, sem contar uma tentativa.
+O Nak do NATS conta uma entrega; por isso, ao fim da espera, uma cópia é publicada no fim do stream,
+com as tentativas já feitas em "Gozap-Prior-Attempts", e a mensagem atual é confirmada.
+Durante a espera, a mensagem continua entre as mensagens em processamento e tem seu prazo renovado.
+Se a publicação falhar, a mensagem é devolvida com Nak.
 */
-func (client *NatsMessenger) Consume(subject string, handler func(Message)) error {
-	_, err := client.js.Subscribe(subject, func(msg *nats.Msg) {
-		message := Message{
-			Body: msg.Data,
-			Ack: func() error {
-				return msg.Ack()
-			},
-			Nack: func() error {
-				return msg.Nak()
-			},
+func (client *NatsMessenger) requeue(subject string, msg *nats.Msg, attempt int) {
+	time.AfterFunc(client.Retry.Delay(1), func() {
+		if client.ctx.Err() != nil {
+			return
+		}
+		client.settle(msg)
+
+		retry := nats.NewMsg(subject)
+		retry.Data = msg.Data
+		for key, values := range msg.Header {
+			retry.Header[key] = values
+		}
+		retry.Header.Set(natsHeaderPrior, strconv.Itoa(attempt-1))
+
+		_, err := client.js.PublishMsg(retry)
+		if err != nil {
+			log.Printf("Error requeueing message of %s: %v", subject, err)
+			_ = msg.NakWithDelay(client.Retry.Delay(1))
+			return
+		}
+
+		err = msg.Ack()
+		if err != nil {
+			log.Printf("Error acknowledging requeued message of %s: %v", subject, err)
 		}
-		handler(message)
 	})
-	return err
 }
 
 /*
-Método Close fecha a conexão do NATS.
+Método settle remove a mensagem das mensagens em processamento.
+*/
+func (client *NatsMessenger) settle(msg *nats.Msg) {
+	client.mu.Lock()
+	delete(client.inFlight, msg)
+	client.mu.Unlock()
+}
+
+/*
+Método extend renova o prazo das mensagens em processamento com InProgress a cada AckWait/3,
+para que envios demorados, ou mensagens aguardando o worker da sessão, não sejam reentregues a outras réplicas.
+*/
+func (client *NatsMessenger) extend() {
+	ticker := time.NewTicker(client.ackWait() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		client.mu.Lock()
+		msgs := make([]*nats.Msg, 0, len(client.inFlight))
+		for msg := range client.inFlight {
+			msgs = append(msgs, msg)
+		}
+		client.mu.Unlock()
+
+		for _, msg := range msgs {
+			err := msg.InProgress()
+			if err != nil && client.ctx.Err() == nil {
+				log.Printf("Error extending message of %s: %v", msg.Subject, err)
+			}
+		}
+	}
+}
+
+/*
+Método durable retorna o nome do consumer durável, ou natsDefaultDurable se não informado.
+*/
+func (client *NatsMessenger) durable() string {
+	if client.Durable == "" {
+		return natsDefaultDurable
+	}
+	return natsName(client.Durable)
+}
+
+/*
+Método ackWait retorna o prazo de confirmação das entregas, ou natsDefaultAckWait se não informado.
+*/
+func (client *NatsMessenger) ackWait() time.Duration {
+	if client.AckWait <= 0 {
+		return natsDefaultAckWait
+	}
+	return client.AckWait
+}
+
+/*
+Método provision cria ou atualiza o stream, o stream de mensagens mortas e o consumer durável do assunto.
+*/
+func (client *NatsMessenger) provision(subject string) error {
+	err := client.ensureStream(subject)
+	if err != nil {
+		return err
+	}
+
+	err = client.ensureDeadLetterStream(subject)
+	if err != nil {
+		return err
+	}
+
+	return client.ensureConsumer(subject)
+}
+
+/*
+Método ensureStream cria o stream do assunto conforme Stream, se ainda não existir,
+ou atualiza a idade máxima e as réplicas de um stream existente.
+A política de retenção de um stream não pode ser alterada depois de criado.
+Os streams já verificados são guardados até a próxima reconexão.
+*/
+func (client *NatsMessenger) ensureStream(subject string) error {
+	client.mu.Lock()
+	_, ok := client.streams[subject]
+	client.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	name := natsStreamName(subject)
+	replicas := max(client.Stream.Replicas, 1)
+
+	info, err := client.js.StreamInfo(name)
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		_, err = client.js.AddStream(&nats.StreamConfig{
+			Name:      name,
+			Subjects:  []string{subject},
+			Retention: client.Stream.Retention,
+			MaxAge:    client.Stream.MaxAge,
+			Replicas:  replicas,
+			Storage:   nats.FileStorage,
+		})
+	case err == nil && (info.Config.MaxAge != client.Stream.MaxAge || info.Config.Replicas != replicas):
+		config := info.Config
+		config.MaxAge = client.Stream.MaxAge
+		config.Replicas = replicas
+		_, err = client.js.UpdateStream(&config)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNatsStream, err)
+	}
+
+	client.mu.Lock()
+	client.streams[subject] = struct{}{}
+	client.mu.Unlock()
+	return nil
+}
+
+/*
+Método ensureConsumer cria o consumer durável do assunto, com confirmação explícita, AckWait e,
+como número máximo de entregas, Retry.MaxAttempts mais uma: a entrega extra, feita quando a última tentativa
+expira sem confirmação, envia a mensagem à fila de mensagens mortas.
+Um consumer existente com outro prazo ou limite é atualizado.
+*/
+func (client *NatsMessenger) ensureConsumer(subject string) error {
+	stream := natsStreamName(subject)
+
+	maxDeliver := client.Retry.MaxAttempts + 1
+	if client.Retry.MaxAttempts <= 0 {
+		maxDeliver = -1
+	}
+
+	config := &nats.ConsumerConfig{
+		Durable:       client.durable(),
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       client.ackWait(),
+		MaxDeliver:    maxDeliver,
+		FilterSubject: subject,
+	}
+
+	info, err := client.js.ConsumerInfo(stream, config.Durable)
+	switch {
+	case errors.Is(err, nats.ErrConsumerNotFound):
+		_, err = client.js.AddConsumer(stream, config)
+	case err == nil && (info.Config.AckWait != config.AckWait || info.Config.MaxDeliver != config.MaxDeliver):
+		_, err = client.js.UpdateConsumer(stream, config)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNatsSubscribe, err)
+	}
+
+	return nil
+}
+
+/*
+Método ensureDeadLetterStream cria o stream de mensagens mortas do assunto, se ainda não existir.
+*/
+func (client *NatsMessenger) ensureDeadLetterStream(subject string) error {
+	name := natsDeadLetterStream(subject)
+
+	_, err := client.js.StreamInfo(name)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = client.js.AddStream(&nats.StreamConfig{
+			Name:     name,
+			Subjects: []string{natsDeadLetterSubject(subject)},
+			Storage:  nats.FileStorage,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNatsStream, err)
+	}
+
+	return nil
+}
+
+/*
+Método deadLetter publica a mensagem no assunto de mensagens mortas, com os cabeçalhos originais,
+e a encerra no stream de origem.
+Se a publicação falhar, a mensagem é reentregue após a espera máxima.
+*/
+func (client *NatsMessenger) deadLetter(subject string, msg *nats.Msg, attempt int, reason string) error {
+	dead := nats.NewMsg(natsDeadLetterSubject(subject))
+	dead.Data = msg.Data
+	for key, values := range msg.Header {
+		dead.Header[key] = values
+	}
+	dead.Header.Set(natsHeaderAttempt, strconv.Itoa(attempt))
+	dead.Header.Set(natsHeaderReason, reason)
+	dead.Header.Set(natsHeaderSubject, subject)
+	dead.Header.Set(natsHeaderFailedAt, time.Now().UTC().Format(time.RFC3339))
+
+	_, err := client.js.PublishMsg(dead)
+	if err != nil {
+		_ = msg.NakWithDelay(client.Retry.Delay(attempt))
+		return fmt.Errorf("%w: %v", ErrNatsPublish, err)
+	}
+
+	log.Printf("Message moved to %s after %d attempts: %s", dead.Subject, attempt, reason)
+	return msg.Term()
+}
+
+/*
+Método ListDeadLetters lista as mensagens do stream de mensagens mortas sem removê-las.
+O ID de cada entrada é sua sequência no stream.
+Parâmetros:
+- subject: Assunto de origem.
+- limit: Quantidade máxima de mensagens.
+Retorna:
+- As mensagens mortas e um erro, se houver.
+*/
+func (client *NatsMessenger) ListDeadLetters(subject string, limit int) ([]DeadLetter, error) {
+	name := natsDeadLetterStream(subject)
+
+	info, err := client.js.StreamInfo(name)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		return []DeadLetter{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNatsStream, err)
+	}
+
+	res := []DeadLetter{}
+	for seq := info.State.FirstSeq; seq > 0 && seq <= info.State.LastSeq && len(res) < limit; seq++ {
+		raw, err := client.js.GetMsg(name, seq)
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNatsStream, err)
+		}
+		res = append(res, natsDeadLetter(subject, raw))
+	}
+
+	return res, nil
+}
+
+/*
+Método ReplayDeadLetter republica a mensagem morta no assunto de origem, com o envelope original,
+e a remove do stream de mensagens mortas.
+A nova publicação reinicia a contagem de tentativas.
+Parâmetros:
+- subject: Assunto de origem.
+- id: Sequência da mensagem no stream de mensagens mortas.
+Retorna:
+- ErrDeadLetterNotFound se a mensagem não existir, ou outro erro, se houver.
+*/
+func (client *NatsMessenger) ReplayDeadLetter(subject string, id string) error {
+	name := natsDeadLetterStream(subject)
+
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return ErrDeadLetterNotFound
+	}
+
+	raw, err := client.js.GetMsg(name, seq)
+	if errors.Is(err, nats.ErrMsgNotFound) || errors.Is(err, nats.ErrStreamNotFound) {
+		return ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNatsStream, err)
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = raw.Data
+	for key, values := range raw.Header {
+		msg.Header[key] = values
+	}
+	for _, key := range natsDeadLetterHeaders {
+		msg.Header.Del(key)
+	}
+
+	if _, err = client.js.PublishMsg(msg); err != nil {
+		return fmt.Errorf("%w: %v", ErrNatsPublish, err)
+	}
+
+	if err = client.js.DeleteMsg(name, seq); err != nil {
+		return fmt.Errorf("%w: %v", ErrNatsStream, err)
+	}
+
+	return nil
+}
+
+/*
+Função natsDeadLetter converte uma mensagem do stream de mensagens mortas.
+*/
+func natsDeadLetter(subject string, raw *nats.RawStreamMsg) DeadLetter {
+	res := DeadLetter{
+		ID:        strconv.FormatUint(raw.Sequence, 10),
+		Queue:     subject,
+		MessageID: raw.Header.Get(natsHeaderMessageID),
+		Headers:   natsEnvelopeHeaders(raw.Header),
+		Body:      raw.Data,
+		Reason:    raw.Header.Get(natsHeaderReason),
+	}
+	res.Attempt, _ = strconv.Atoi(raw.Header.Get(natsHeaderAttempt))
+	res.FailedAt, _ = time.Parse(time.RFC3339, raw.Header.Get(natsHeaderFailedAt))
+	return res
+}
+
+/*
+Método Close encerra a busca de mensagens e fecha a conexão do NATS.
+Mensagens entregues e não confirmadas são reentregues pelo servidor ao fim do AckWait.
 Retorna um erro se houver falha ao fechar a conexão.
 */
 func (client *NatsMessenger) Close() error {
+	if client.cancel != nil {
+		client.cancel()
+	}
 	if client.conn != nil {
 		client.conn.Close()
 	}
@@ -0,0 +1,624 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+/*
+Definição de variáveis de erro específicas para o driver de mensageria sobre o Postgres.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrOutboxConnectionFailed = errors.New("outbox.connection_failed: failed to connect to the database")
+	ErrOutboxPublish          = errors.New("outbox.publish_failed: failed to publish a message")
+	ErrOutboxConsume          = errors.New("outbox.consume_failed: failed to consume messages")
+	ErrOutboxAck              = errors.New("outbox.ack_failed: failed to acknowledge a message")
+)
+
+/*
+Canal do LISTEN/NOTIFY usado para acordar os consumidores quando uma mensagem é publicada.
+O conteúdo da notificação é o nome da fila.
+*/
+const outboxNotifyChannel = "gozap_outbox"
+
+/*
+Quantidade de mensagens reservadas por consulta quando Limits.Prefetch não é informado.
+*/
+const outboxDefaultPrefetch = 10
+
+/*
+Interface TxPublisher é implementada pelos drivers que publicam mensagens dentro de uma transação do banco de dados.
+A mensagem só fica visível aos consumidores se a transação for confirmada,
+tornando a publicação atômica com as demais escritas da transação.
+*/
+type TxPublisher interface {
+	PublishTx(ctx context.Context, tx *sql.Tx, queueName string, envelope Envelope) error
+}
+
+/*
+Interface outboxExecutor é satisfeita por *sql.DB e *sql.Tx.
+*/
+type outboxExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+/*
+Estrutura OutboxMessenger implementa MessengerInterface sobre a tabela messenger_outbox do Postgres.
+Pode ser usada como fila, consumida diretamente pelo consumer, ou como outbox transacional,
+com OutboxRelay encaminhando as mensagens ao RabbitMQ ou ao NATS.
+As mensagens são reservadas com FOR UPDATE SKIP LOCKED por VisibilityTimeout, renovado enquanto estão em processamento;
+mensagens de réplicas que caíram voltam a ficar visíveis ao fim do prazo.
+Os consumidores são acordados por LISTEN/NOTIFY a cada publicação e consultam a tabela a cada PollInterval,
+para as novas tentativas agendadas e as notificações perdidas.
+As mensagens são reservadas em lotes de Limits.Prefetch e entregues ao handler por Limits.Workers goroutines.
+- Ack remove a mensagem.
+- Nack agenda uma nova tentativa com a espera da política Retry ou, esgotadas as tentativas, marca a mensagem como morta.
+- Reject marca a mensagem como morta.
+*/
+type OutboxMessenger struct {
+	DSN               string
+	Retry             RetryPolicy
+	VisibilityTimeout time.Duration
+	PollInterval      time.Duration
+	Limits            ConsumerLimits
+
+	db       *sql.DB
+	consumer string
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	listenOnce sync.Once
+	listener   *pq.Listener
+
+	mu       sync.Mutex
+	wakeups  map[string]chan struct{}
+	inFlight map[int64]struct{}
+	limit    *inFlightLimit
+}
+
+/*
+Método Connect estabelece uma conexão com o Postgres.
+Retorna um erro, se houver.
+*/
+func (client *OutboxMessenger) Connect() error {
+	db, err := sql.Open("postgres", client.DSN)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOutboxConnectionFailed, err)
+	}
+	if err = db.Ping(); err != nil {
+		return fmt.Errorf("%w: %v", ErrOutboxConnectionFailed, err)
+	}
+
+	client.db = db
+	client.consumer = newRandomID()
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	client.wakeups = map[string]chan struct{}{}
+	client.inFlight = map[int64]struct{}{}
+	client.limit = newInFlightLimit(client.Limits.MaxInFlight)
+	return nil
+}
+
+/*
+Método Publish insere uma mensagem na fila especificada e notifica os consumidores.
+Retorna um erro, se houver.
+*/
+func (client *OutboxMessenger) Publish(ctx context.Context, queueName string, envelope Envelope) error {
+	return client.publish(ctx, client.db, queueName, envelope, time.Time{})
+}
+
+/*
+Método PublishAt insere uma mensagem que só fica disponível aos consumidores a partir de at.
+Retorna um erro, se houver.
+*/
+func (client *OutboxMessenger) PublishAt(ctx context.Context, queueName string, envelope Envelope, at time.Time) error {
+	return client.publish(ctx, client.db, queueName, envelope, at)
+}
+
+/*
+Método PublishTx insere uma mensagem na fila especificada dentro da transação informada.
+A mensagem e a notificação só são visíveis aos consumidores após a confirmação da transação.
+Retorna um erro, se houver.
+*/
+func (client *OutboxMessenger) PublishTx(ctx context.Context, tx *sql.Tx, queueName string, envelope Envelope) error {
+	return client.publish(ctx, tx, queueName, envelope, time.Time{})
+}
+
+/*
+Método publish insere a mensagem e emite a notificação na mesma instrução.
+O Timestamp do envelope é gravado em created_at; availableAt zero disponibiliza a mensagem imediatamente.
+*/
+func (client *OutboxMessenger) publish(ctx context.Context, exec outboxExecutor, queueName string, envelope Envelope, availableAt time.Time) error {
+	envelope = envelope.withDefaults()
+	headers, err := json.Marshal(envelope.Headers)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOutboxPublish, err)
+	}
+
+	var available *time.Time
+	if !availableAt.IsZero() {
+		available = &availableAt
+	}
+
+	query := `
+		WITH inserted AS (
+			INSERT INTO messenger_outbox (queue, body, message_id, headers, content_type, created_at, available_at)
+			VALUES ($1, $2, $4, $5, $6, $7, COALESCE($8, NOW())) RETURNING id
+		)
+		SELECT pg_notify($3, $1) FROM inserted
+		`
+	_, err = exec.ExecContext(ctx, query, queueName, envelope.Body, outboxNotifyChannel,
+		envelope.ID, headers, envelope.ContentType, envelope.Timestamp, available)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOutboxPublish, err)
+	}
+	return nil
+}
+
+/*
+Método Consume consome mensagens da fila especificada em uma goroutine, até a conexão ser fechada
+ou o contexto ser cancelado.
+No primeiro consumo são iniciados o LISTEN das publicações e a renovação das mensagens em processamento.
+Retorna um erro, se houver.
+*/
+func (client *OutboxMessenger) Consume(ctx context.Context, queueName string, handler func(Message)) error {
+	var err error
+	client.listenOnce.Do(func() {
+		client.listener = pq.NewListener(client.DSN, time.Second, time.Minute, nil)
+		if err = client.listener.Listen(outboxNotifyChannel); err != nil {
+			return
+		}
+		go client.notify()
+		go client.extend()
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOutboxConsume, err)
+	}
+
+	client.mu.Lock()
+	wakeup, ok := client.wakeups[queueName]
+	if !ok {
+		wakeup = make(chan struct{}, 1)
+		client.wakeups[queueName] = wakeup
+	}
+	client.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	context.AfterFunc(client.ctx, cancel)
+
+	go client.consume(ctx, queueName, wakeup, newDeliveryPool(ctx, client.Limits, client.limit, handler))
+	return nil
+}
+
+/*
+Método notify repassa as notificações do LISTEN aos consumidores da fila notificada.
+Uma notificação nula indica que a conexão do LISTEN foi restabelecida; nesse caso todos os consumidores são acordados.
+*/
+func (client *OutboxMessenger) notify() {
+	for {
+		select {
+		case <-client.ctx.Done():
+			return
+		case notification := <-client.listener.NotificationChannel():
+			client.mu.Lock()
+			for queueName, wakeup := range client.wakeups {
+				if notification != nil && notification.Extra != queueName {
+					continue
+				}
+				select {
+				case wakeup <- struct{}{}:
+				default:
+				}
+			}
+			client.mu.Unlock()
+		}
+	}
+}
+
+/*
+Método consume reserva e entrega as mensagens da fila, aguardando uma notificação ou PollInterval
+quando não há mensagens disponíveis.
+Mensagens reservadas e não entregues até o cancelamento voltam a ficar visíveis ao fim do VisibilityTimeout
+após Close.
+*/
+func (client *OutboxMessenger) consume(ctx context.Context, queueName string, wakeup <-chan struct{}, pool *deliveryPool) {
+	batchSize := client.Limits.prefetch(outboxDefaultPrefetch)
+
+	for ctx.Err() == nil {
+		messages, err := client.reserve(ctx, queueName, batchSize)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error reading %s: %v", queueName, err)
+		}
+
+		for _, message := range messages {
+			if !pool.deliver(message) {
+				return
+			}
+		}
+		if len(messages) == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-wakeup:
+		case <-time.After(client.PollInterval):
+		}
+	}
+}
+
+/*
+Método reserve reserva até limit mensagens disponíveis da fila, em ordem de publicação.
+Mensagens reservadas por outras réplicas são ignoradas com SKIP LOCKED.
+*/
+func (client *OutboxMessenger) reserve(ctx context.Context, queueName string, limit int) ([]Message, error) {
+	query := `
+		UPDATE messenger_outbox SET locked_by = $2, locked_until = NOW() + $3::bigint * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM messenger_outbox
+			WHERE queue = $1 AND dead_at IS NULL AND available_at <= NOW()
+				AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, body, message_id, headers, content_type, created_at, attempt
+		`
+	rows, err := client.db.QueryContext(ctx, query, queueName, client.consumer, client.VisibilityTimeout.Milliseconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOutboxConsume, err)
+	}
+	defer rows.Close()
+
+	type reserved struct {
+		id       int64
+		envelope Envelope
+		attempt  int
+	}
+
+	var entries []reserved
+	for rows.Next() {
+		var entry reserved
+		var headers []byte
+		err := rows.Scan(&entry.id, &entry.envelope.Body, &entry.envelope.ID, &headers,
+			&entry.envelope.ContentType, &entry.envelope.Timestamp, &entry.attempt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrOutboxConsume, err)
+		}
+		_ = json.Unmarshal(headers, &entry.envelope.Headers)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOutboxConsume, err)
+	}
+
+	/*
+	   RETURNING não garante a ordem, então as mensagens são reordenadas pelo id.
+	*/
+	slices.SortFunc(entries, func(a, b reserved) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	messages := make([]Message, 0, len(entries))
+	client.mu.Lock()
+	for _, entry := range entries {
+		client.inFlight[entry.id] = struct{}{}
+		messages = append(messages, client.message(entry.id, entry.envelope, entry.attempt))
+	}
+	client.mu.Unlock()
+
+	return messages, nil
+}
+
+/*
+Método extend renova a reserva das mensagens em processamento a cada VisibilityTimeout/3,
+para que elas não fiquem visíveis a outras réplicas enquanto aguardam o worker da sessão.
+*/
+func (client *OutboxMessenger) extend() {
+	ticker := time.NewTicker(client.VisibilityTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		client.mu.Lock()
+		ids := make([]int64, 0, len(client.inFlight))
+		for id := range client.inFlight {
+			ids = append(ids, id)
+		}
+		client.mu.Unlock()
+
+		if len(ids) == 0 {
+			continue
+		}
+
+		query := `
+			UPDATE messenger_outbox SET locked_until = NOW() + $1::bigint * INTERVAL '1 millisecond'
+			WHERE id = ANY($2) AND locked_by = $3
+			`
+		_, err := client.db.ExecContext(client.ctx, query, client.VisibilityTimeout.Milliseconds(), pq.Array(ids), client.consumer)
+		if err != nil && client.ctx.Err() == nil {
+			log.Printf("Error extending outbox reservations: %v", err)
+		}
+	}
+}
+
+/*
+Método settle remove a mensagem das mensagens em processamento.
+Retorna false se ela já tiver sido confirmada.
+*/
+func (client *OutboxMessenger) settle(id int64) bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if _, ok := client.inFlight[id]; !ok {
+		return false
+	}
+	delete(client.inFlight, id)
+	return true
+}
+
+/*
+Método message cria a Message entregue ao handler, com as funções de confirmação.
+As confirmações só têm efeito enquanto a reserva pertencer a esta conexão.
+*/
+func (client *OutboxMessenger) message(id int64, envelope Envelope, attempt int) Message {
+	return Message{
+		Envelope:    envelope,
+		Attempt:     attempt,
+		MaxAttempts: client.Retry.MaxAttempts,
+		Redelivered: attempt > 1,
+		Ack: func() error {
+			if !client.settle(id) {
+				return nil
+			}
+			return client.exec(`DELETE FROM messenger_outbox WHERE id = $1 AND locked_by = $2`, id, client.consumer)
+		},
+		Nack: func() error {
+			if !client.settle(id) {
+				return nil
+			}
+			if !client.Retry.CanRetry(attempt) {
+				return client.deadLetter(id, attempt, DeadLetterReasonMaxAttempts)
+			}
+			return client.retry(id, attempt+1, client.Retry.Delay(attempt))
+		},
+		Reject: func(reason string) error {
+			if !client.settle(id) {
+				return nil
+			}
+			return client.deadLetter(id, attempt, reason)
+		},
+		Requeue: func() error {
+			if !client.settle(id) {
+				return nil
+			}
+			return client.retry(id, attempt, client.Retry.Delay(1))
+		},
+	}
+}
+
+/*
+Método retry libera a reserva e devolve a mensagem à fila após a espera informada, com o número da tentativa informado.
+*/
+func (client *OutboxMessenger) retry(id int64, attempt int, delay time.Duration) error {
+	query := `
+		UPDATE messenger_outbox
+		SET attempt = $3, available_at = NOW() + $4::bigint * INTERVAL '1 millisecond',
+			locked_by = NULL, locked_until = NULL
+		WHERE id = $1 AND locked_by = $2
+		`
+	return client.exec(query, id, client.consumer, attempt, delay.Milliseconds())
+}
+
+/*
+Método deadLetter marca a mensagem como morta, mantendo-a na tabela para inspeção e reprocessamento.
+*/
+func (client *OutboxMessenger) deadLetter(id int64, attempt int, reason string) error {
+	query := `
+		UPDATE messenger_outbox
+		SET dead_at = NOW(), reason = $3, locked_by = NULL, locked_until = NULL
+		WHERE id = $1 AND locked_by = $2
+		`
+	err := client.exec(query, id, client.consumer, reason)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Outbox message %d moved to dead letters after %d attempts: %s", id, attempt, reason)
+	return nil
+}
+
+/*
+Método exec executa uma confirmação, envolvendo o erro em ErrOutboxAck.
+*/
+func (client *OutboxMessenger) exec(query string, args ...interface{}) error {
+	_, err := client.db.ExecContext(client.ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOutboxAck, err)
+	}
+	return nil
+}
+
+/*
+Método ListDeadLetters lista as mensagens mortas da fila sem removê-las.
+O ID de cada entrada é seu id na tabela messenger_outbox.
+Parâmetros:
+- queueName: Fila de origem.
+- limit: Quantidade máxima de mensagens.
+Retorna:
+- As mensagens mortas e um erro, se houver.
+*/
+func (client *OutboxMessenger) ListDeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	query := `
+		SELECT id, body, message_id, headers, attempt, COALESCE(reason, ''), dead_at
+		FROM messenger_outbox
+		WHERE queue = $1 AND dead_at IS NOT NULL
+		ORDER BY id
+		LIMIT $2
+		`
+	rows, err := client.db.QueryContext(client.ctx, query, queueName, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []DeadLetter{}
+	for rows.Next() {
+		var id int64
+		var headers []byte
+		entry := DeadLetter{Queue: queueName}
+		err := rows.Scan(&id, &entry.Body, &entry.MessageID, &headers, &entry.Attempt, &entry.Reason, &entry.FailedAt)
+		if err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal(headers, &entry.Headers)
+		entry.ID = strconv.FormatInt(id, 10)
+		res = append(res, entry)
+	}
+
+	return res, rows.Err()
+}
+
+/*
+Método ReplayDeadLetter devolve a mensagem morta à fila de origem, reiniciando as tentativas.
+Parâmetros:
+- queueName: Fila de origem.
+- id: Identificador da mensagem morta.
+Retorna:
+- ErrDeadLetterNotFound se a mensagem não existir, ou outro erro, se houver.
+*/
+func (client *OutboxMessenger) ReplayDeadLetter(queueName string, id string) error {
+	outboxID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return ErrDeadLetterNotFound
+	}
+
+	query := `
+		UPDATE messenger_outbox
+		SET dead_at = NULL, reason = NULL, attempt = 1, available_at = NOW()
+		WHERE id = $1 AND queue = $2 AND dead_at IS NOT NULL
+		`
+	result, err := client.db.ExecContext(client.ctx, query, outboxID, queueName)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	_, err = client.db.ExecContext(client.ctx, `SELECT pg_notify($1, $2)`, outboxNotifyChannel, queueName)
+	return err
+}
+
+/*
+Método Health verifica a conexão com o banco de dados do outbox.
+*/
+func (client *OutboxMessenger) Health() error {
+	if client.db == nil {
+		return ErrMessengerDisconnected
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := client.db.PingContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMessengerDisconnected, err)
+	}
+	return nil
+}
+
+/*
+Método Close encerra os consumidores, o LISTEN e a conexão com o banco de dados.
+Mensagens reservadas e não confirmadas voltam a ficar visíveis ao fim do VisibilityTimeout.
+Retorna um erro, se houver.
+*/
+func (client *OutboxMessenger) Close() error {
+	if client.cancel != nil {
+		client.cancel()
+	}
+	if client.listener != nil {
+		_ = client.listener.Close()
+	}
+	if client.db != nil {
+		return client.db.Close()
+	}
+	return nil
+}
+
+/*
+Estrutura OutboxRelay encaminha as mensagens do outbox transacional para o driver de mensageria Target.
+Cada mensagem é publicada no Target com o envelope original, inclusive o ID e os Headers,
+e removida do outbox somente após a publicação.
+As mensagens de cada fila são encaminhadas uma de cada vez, em ordem de publicação:
+uma publicação que falha é repetida no lugar com a espera de Retry, sem deixar as mensagens seguintes passarem à frente,
+e vai para as mensagens mortas do outbox quando as tentativas se esgotam.
+O Outbox deve ser consumido por um único worker (Limits.Workers igual a 1) para manter a ordem.
+*/
+type OutboxRelay struct {
+	Outbox MessengerInterface
+	Target MessengerInterface
+	Queues []string
+	Retry  RetryPolicy
+}
+
+/*
+Método Run inicia o encaminhamento das filas Queues.
+O encaminhamento continua em segundo plano até o contexto ser cancelado ou o Outbox ser fechado.
+Retorna um erro, se houver.
+*/
+func (r OutboxRelay) Run(ctx context.Context) error {
+	for _, queueName := range r.Queues {
+		queueName := queueName
+		err := r.Outbox.Consume(ctx, queueName, func(msg Message) {
+			r.relay(ctx, queueName, msg)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+Método relay publica a mensagem no Target, repetindo as falhas até a última tentativa da mensagem.
+Com o cancelamento do contexto, a mensagem volta ao outbox com Requeue, sem contar uma tentativa.
+*/
+func (r OutboxRelay) relay(ctx context.Context, queueName string, msg Message) {
+	for attempt := msg.Attempt; ; attempt++ {
+		err := r.Target.Publish(ctx, queueName, msg.Envelope)
+		if err == nil {
+			_ = msg.Ack()
+			return
+		}
+
+		log.Printf("Error relaying outbox message to %s (attempt %d/%d): %v", queueName, attempt, msg.MaxAttempts, err)
+		if attempt >= msg.MaxAttempts {
+			_ = msg.Reject(DeadLetterReasonMaxAttempts)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = msg.Requeue()
+			return
+		case <-time.After(r.Retry.Delay(attempt)):
+		}
+	}
+}
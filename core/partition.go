@@ -0,0 +1,273 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+Função PartitionOf calcula a partição de uma chave, como o ID da sessão.
+Parâmetros:
+- key: Chave a ser particionada.
+- partitions: Quantidade de partições.
+Retorna:
+- O número da partição, entre 0 e partitions-1.
+*/
+func PartitionOf(key string, partitions int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(partitions))
+}
+
+/*
+Função PartitionQueue retorna o nome da fila de uma partição.
+*/
+func PartitionQueue(queueName string, partition int) string {
+	return fmt.Sprintf("%s.p%d", queueName, partition)
+}
+
+/*
+Função PartitionQueues retorna os nomes das filas de todas as partições.
+Sem partições, retorna apenas a fila informada.
+*/
+func PartitionQueues(queueName string, partitions int) []string {
+	if partitions <= 0 {
+		return []string{queueName}
+	}
+
+	queues := make([]string, partitions)
+	for i := range queues {
+		queues[i] = PartitionQueue(queueName, i)
+	}
+	return queues
+}
+
+/*
+Função PartitionOwner escolhe o membro dono da partição por rendezvous hashing.
+Quando um membro entra ou sai, apenas as partições ganhas ou perdidas por ele mudam de dono.
+Parâmetros:
+- partition: Número da partição.
+- members: Membros ativos.
+Retorna:
+- O membro dono da partição, ou vazio se não houver membros.
+*/
+func PartitionOwner(partition int, members []string) string {
+	var (
+		owner string
+		best  uint64
+	)
+
+	for _, member := range members {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(member))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(strconv.Itoa(partition)))
+		score := h.Sum64()
+		if owner == "" || score > best || (score == best && member < owner) {
+			owner = member
+			best = score
+		}
+	}
+
+	return owner
+}
+
+/*
+Função OwnedPartitions retorna as partições cujo dono é a instância informada.
+*/
+func OwnedPartitions(instanceID string, members []string, partitions int) []int {
+	owned := []int{}
+	for partition := 0; partition < partitions; partition++ {
+		if PartitionOwner(partition, members) == instanceID {
+			owned = append(owned, partition)
+		}
+	}
+	return owned
+}
+
+/*
+Estrutura PartitionConsumer consome as filas das partições atribuídas a esta instância.
+Cada partição é consumida por um Messenger próprio, fechado quando a partição deixa de ser atribuída;
+as mensagens ainda não confirmadas voltam à fila e são entregues ao novo dono.
+Partições que falham ao conectar são tentadas novamente com espera exponencial enquanto continuarem atribuídas.
+Revoke, se informado, é chamado com as partições que deixaram de ser atribuídas antes de seus Messengers serem fechados,
+para que as mensagens já recebidas dessas partições não sejam processadas depois de voltarem à fila.
+As conexões e os fechamentos dos Messengers são feitos fora do bloqueio,
+para que uma rede lenta não trave Partitions nem as tentativas das demais partições.
+O cancelamento de Context interrompe o recebimento em todas as partições sem fechar seus Messengers,
+para que as mensagens em processamento ainda possam ser confirmadas antes de Close.
+*/
+type PartitionConsumer struct {
+	Context      context.Context
+	NewMessenger func() MessengerInterface
+	Queue        string
+	Handler      func(Message)
+	Revoke       func(partitions []int)
+
+	mu       sync.Mutex
+	wanted   map[int]bool
+	active   map[int]MessengerInterface
+	starting map[int]bool
+}
+
+/*
+Método Assign passa a consumir as partições informadas e deixa de consumir as demais.
+Parâmetros:
+- partitions: Partições atribuídas a esta instância.
+*/
+func (c *PartitionConsumer) Assign(partitions []int) {
+	c.assign(partitions, c.Revoke)
+}
+
+/*
+Método assign troca as partições consumidas, chamando revoke com as partições revogadas, se informado.
+Partições que já estão conectando, inclusive nas tentativas de retry, não são conectadas de novo.
+*/
+func (c *PartitionConsumer) assign(partitions []int, revoke func(partitions []int)) {
+	c.mu.Lock()
+	if c.active == nil {
+		c.active = make(map[int]MessengerInterface)
+		c.starting = make(map[int]bool)
+	}
+
+	c.wanted = make(map[int]bool, len(partitions))
+	for _, partition := range partitions {
+		c.wanted[partition] = true
+	}
+
+	var revoked []int
+	closing := make(map[int]MessengerInterface)
+	for partition, messenger := range c.active {
+		if !c.wanted[partition] {
+			revoked = append(revoked, partition)
+			closing[partition] = messenger
+			delete(c.active, partition)
+		}
+	}
+	sort.Ints(revoked)
+
+	var started []int
+	for _, partition := range partitions {
+		if _, ok := c.active[partition]; ok || c.starting[partition] {
+			continue
+		}
+		c.starting[partition] = true
+		started = append(started, partition)
+	}
+	c.mu.Unlock()
+
+	if len(revoked) > 0 && revoke != nil {
+		revoke(revoked)
+	}
+
+	for _, partition := range revoked {
+		if err := closing[partition].Close(); err != nil {
+			log.Printf("Error closing partition %d: %v", partition, err)
+		}
+	}
+
+	for _, partition := range started {
+		if err := c.start(partition); err != nil {
+			log.Printf("Error consuming partition %d, retrying: %v", partition, err)
+			go c.retry(partition)
+		}
+	}
+
+	log.Printf("Consuming partitions %v of %s", c.Partitions(), c.Queue)
+}
+
+/*
+Método start conecta um Messenger e consome a fila da partição, que deve estar marcada em starting.
+Em caso de sucesso a partição passa a ativa; se ela deixou de ser atribuída durante a conexão, o Messenger é fechado.
+Em caso de erro a partição continua em starting, para que apenas quem chamou tente novamente.
+*/
+func (c *PartitionConsumer) start(partition int) error {
+	if err := c.Context.Err(); err != nil {
+		return err
+	}
+
+	messenger := c.NewMessenger()
+	if err := messenger.Connect(); err != nil {
+		return err
+	}
+	if err := messenger.Consume(c.Context, PartitionQueue(c.Queue, partition), c.Handler); err != nil {
+		_ = messenger.Close()
+		return err
+	}
+
+	c.mu.Lock()
+	delete(c.starting, partition)
+	wanted := c.wanted[partition]
+	if wanted {
+		c.active[partition] = messenger
+	}
+	c.mu.Unlock()
+
+	if !wanted {
+		if err := messenger.Close(); err != nil {
+			log.Printf("Error closing partition %d: %v", partition, err)
+		}
+	}
+	return nil
+}
+
+/*
+Método retry tenta novamente consumir a partição até obter sucesso ou ela deixar de ser atribuída.
+*/
+func (c *PartitionConsumer) retry(partition int) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}
+
+	for attempt := 1; ; attempt++ {
+		time.Sleep(policy.Delay(attempt))
+
+		c.mu.Lock()
+		if !c.wanted[partition] || c.Context.Err() != nil {
+			delete(c.starting, partition)
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		err := c.start(partition)
+		if err == nil {
+			log.Printf("Consuming partition %d of %s", partition, c.Queue)
+			return
+		}
+		log.Printf("Error consuming partition %d, retrying: %v", partition, err)
+	}
+}
+
+/*
+Método Partitions retorna as partições consumidas no momento, em ordem crescente.
+*/
+func (c *PartitionConsumer) Partitions() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.partitions()
+}
+
+/*
+Método partitions retorna as partições consumidas. Deve ser chamado com mu bloqueado.
+*/
+func (c *PartitionConsumer) partitions() []int {
+	partitions := make([]int, 0, len(c.active))
+	for partition := range c.active {
+		partitions = append(partitions, partition)
+	}
+	sort.Ints(partitions)
+	return partitions
+}
+
+/*
+Método Close deixa de consumir todas as partições, sem chamar Revoke:
+no encerramento, as mensagens recebidas já foram concluídas ou abandonadas pelo consumer.
+*/
+func (c *PartitionConsumer) Close() {
+	c.assign(nil, nil)
+}
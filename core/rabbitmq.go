@@ -1,9 +1,14 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
@@ -20,6 +25,7 @@ var (
 	ErrRabbitMQConsume          = errors.New("rabbitmq.consume_failed: failed to consume messages")
 	ErrRabbitMQCloseChannel     = errors.New("rabbitmq.close_channel_failed: failed to close channel")
 	ErrRabbitMQCloseConnection  = errors.New("rabbitmq.close_connection_failed: failed to close connection")
+	ErrRabbitMQConnectionLost   = errors.New("rabbitmq.connection_lost: connection to RabbitMQ was closed")
 )
 
 type RabbitMQMessagerInterface interface {
@@ -27,75 +33,474 @@ type RabbitMQMessagerInterface interface {
 
 /*
 Estrutura RabbitMQMessenger que mantém a configuração, conexão e canal do RabbitMQ.
+Quando a conexão ou o canal são fechados, o driver reconecta com espera exponencial,
+declara novamente as filas e refaz os consumos ativos.
+Durante a queda, as publicações aguardam a reconexão por no máximo PublishTimeout.
+As publicações usam o modo de confirmação do RabbitMQ e só são concluídas após a confirmação do servidor.
+O canal recebe no máximo Limits.Prefetch mensagens não confirmadas (Qos), entregues ao handler por Limits.Workers goroutines.
 */
 type RabbitMQMessenger struct {
-	URL  string
-	Conn *amqp.Connection
-	Ch   *amqp.Channel
+	URL            string
+	Retry          RetryPolicy
+	PublishTimeout time.Duration
+	Limits         ConsumerLimits
+
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	ch        *amqp.Channel
+	confirms  *rabbitMQConfirms
+	state     *connectionState
+	consumers []*rabbitMQConsumer
+	limit     *inFlightLimit
+	done      chan struct{}
+}
+
+/*
+Estrutura rabbitMQConsumer guarda um consumo ativo, refeito a cada reconexão até o cancelamento do contexto.
+*/
+type rabbitMQConsumer struct {
+	ctx       context.Context
+	queueName string
+	pool      *deliveryPool
+}
+
+/*
+Quantidade de mensagens não confirmadas por canal quando Limits.Prefetch não é informado.
+*/
+const rabbitMQDefaultPrefetch = 20
+
+/*
+Cabeçalhos usados para controlar as novas tentativas e descrever as mensagens mortas.
+Na fila de mensagens mortas, o MessageId identifica a entrada e o ID original da mensagem fica em rabbitMQHeaderMessageID.
+Cabeçalhos com o prefixo "x-" são reservados ao driver e ao RabbitMQ, como o x-death das filas de espera,
+e não fazem parte dos Headers do envelope.
+*/
+const (
+	rabbitMQHeaderAttempt   = "x-gozap-attempt"
+	rabbitMQHeaderReason    = "x-gozap-reason"
+	rabbitMQHeaderQueue     = "x-gozap-queue"
+	rabbitMQHeaderFailedAt  = "x-gozap-failed-at"
+	rabbitMQHeaderMessageID = "x-gozap-message-id"
+)
+
+/*
+Função rabbitMQDeadLetterQueue retorna o nome da fila de mensagens mortas da fila informada.
+*/
+func rabbitMQDeadLetterQueue(queueName string) string {
+	return queueName + ".dlq"
+}
+
+/*
+Função rabbitMQRetryQueue retorna o nome da fila de espera usada nas novas tentativas com o intervalo informado.
+Há uma fila por intervalo, para que mensagens com esperas diferentes não bloqueiem umas às outras.
+*/
+func rabbitMQRetryQueue(queueName string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%d", queueName, delay.Milliseconds())
+}
+
+/*
+Função rabbitMQHeaderInt lê um cabeçalho numérico, retornando zero quando ausente.
+*/
+func rabbitMQHeaderInt(headers amqp.Table, key string) int {
+	switch value := headers[key].(type) {
+	case int:
+		return value
+	case int16:
+		return int(value)
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	default:
+		return 0
+	}
+}
+
+/*
+Função rabbitMQHeaders copia os cabeçalhos da mensagem, removendo os cabeçalhos reservados,
+de controle do driver e do RabbitMQ.
+*/
+func rabbitMQHeaders(headers amqp.Table) amqp.Table {
+	res := amqp.Table{}
+	for key, value := range headers {
+		if strings.HasPrefix(strings.ToLower(key), "x-") {
+			continue
+		}
+		res[key] = value
+	}
+	return res
+}
+
+/*
+Função rabbitMQTable converte os cabeçalhos do envelope para a tabela de cabeçalhos do AMQP.
+*/
+func rabbitMQTable(headers map[string]string) amqp.Table {
+	res := amqp.Table{}
+	for key, value := range headers {
+		res[key] = value
+	}
+	return res
+}
+
+/*
+Função rabbitMQEnvelopeHeaders converte a tabela de cabeçalhos do AMQP para os cabeçalhos do envelope,
+removendo os cabeçalhos reservados.
+*/
+func rabbitMQEnvelopeHeaders(headers amqp.Table) map[string]string {
+	res := map[string]string{}
+	for key, value := range rabbitMQHeaders(headers) {
+		res[key] = fmt.Sprint(value)
+	}
+	return res
+}
+
+/*
+Função rabbitMQEnvelope converte uma entrega do RabbitMQ no envelope publicado.
+*/
+func rabbitMQEnvelope(d amqp.Delivery) Envelope {
+	return Envelope{
+		ID:          d.MessageId,
+		Headers:     rabbitMQEnvelopeHeaders(d.Headers),
+		ContentType: d.ContentType,
+		Timestamp:   d.Timestamp,
+		Body:        d.Body,
+	}
 }
 
 /*
 Método Connect estabelece uma conexão com o RabbitMQ e abre um canal.
-Retorna um ponteiro para o RabbitMQMessenger e um erro, se houver.
+A conexão passa a ser monitorada e é refeita automaticamente até Close.
+Retorna um erro, se a primeira conexão falhar.
 */
 func (client *RabbitMQMessenger) Connect() error {
+	client.state = newConnectionState()
+	client.done = make(chan struct{})
+	client.limit = newInFlightLimit(client.Limits.MaxInFlight)
+
+	err := client.dial()
+	if err != nil {
+		return err
+	}
+
+	go client.watch()
+	return nil
+}
+
+/*
+Método dial abre uma nova conexão e um novo canal, substituindo os anteriores.
+*/
+func (client *RabbitMQMessenger) dial() error {
 	conn, err := amqp.Dial(client.URL)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrRabbitMQConnectionFailed, err)
 	}
-	client.Conn = conn
 
-	ch, err := client.Conn.Channel()
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("%w: %v", ErrRabbitMQChannelFailed, err)
+	}
+
+	err = ch.Qos(client.Limits.prefetch(rabbitMQDefaultPrefetch), 0, false)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("%w: %v", ErrRabbitMQChannelFailed, err)
+	}
+
+	confirms, err := newRabbitMQConfirms(ch)
 	if err != nil {
+		_ = conn.Close()
 		return fmt.Errorf("%w: %v", ErrRabbitMQChannelFailed, err)
 	}
-	client.Ch = ch
 
+	client.mu.Lock()
+	select {
+	case <-client.done:
+		client.mu.Unlock()
+		_ = conn.Close()
+		return ErrRabbitMQConnectionLost
+	default:
+	}
+	client.conn = conn
+	client.ch = ch
+	client.confirms = confirms
+	client.mu.Unlock()
+
+	client.state.up()
+	return nil
+}
+
+/*
+Método watch aguarda o fechamento da conexão ou do canal e reconecta, até Close.
+*/
+func (client *RabbitMQMessenger) watch() {
+	for {
+		conn, ch := client.connection(), client.channel()
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+		var reason *amqp.Error
+		select {
+		case <-client.done:
+			return
+		case reason = <-connClosed:
+		case reason = <-chClosed:
+		}
+
+		select {
+		case <-client.done:
+			return
+		default:
+		}
+
+		err := ErrRabbitMQConnectionLost
+		if reason != nil {
+			err = fmt.Errorf("%w: %v", ErrRabbitMQConnectionLost, reason)
+		}
+		client.state.down(err)
+		log.Printf("RabbitMQ connection lost, reconnecting: %v", err)
+		_ = conn.Close()
+
+		if !client.reconnect() {
+			return
+		}
+	}
+}
+
+/*
+Método reconnect tenta reconectar com espera exponencial e refaz os consumos ativos.
+Se algum consumo não puder ser refeito, a nova conexão é fechada e a reconexão continua com a mesma espera,
+para que nenhum consumo fique parado com a conexão ativa.
+Retorna false se o driver for fechado antes da reconexão.
+*/
+func (client *RabbitMQMessenger) reconnect() bool {
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-client.done:
+			return false
+		case <-time.After(reconnectPolicy.Delay(attempt)):
+		}
+
+		err := client.dial()
+		if err != nil {
+			client.state.down(err)
+			log.Printf("Error reconnecting to RabbitMQ (attempt %d): %v", attempt, err)
+			continue
+		}
+
+		err = client.resubscribe()
+		if err != nil {
+			client.state.down(err)
+			log.Printf("Error resubscribing to RabbitMQ (attempt %d), reconnecting: %v", attempt, err)
+			_ = client.connection().Close()
+			continue
+		}
+
+		log.Println("RabbitMQ reconnected")
+		return true
+	}
+}
+
+/*
+Método resubscribe refaz, no novo canal, os consumos cujos contextos ainda não foram cancelados.
+Retorna o erro do primeiro consumo que não puder ser refeito.
+*/
+func (client *RabbitMQMessenger) resubscribe() error {
+	client.mu.Lock()
+	consumers := slices.Clone(client.consumers)
+	client.mu.Unlock()
+
+	for _, consumer := range consumers {
+		if consumer.ctx.Err() != nil {
+			continue
+		}
+		err := client.subscribe(consumer)
+		if err != nil {
+			return fmt.Errorf("%s: %w", consumer.queueName, err)
+		}
+	}
 	return nil
 }
 
+/*
+Método connection retorna a conexão atual.
+*/
+func (client *RabbitMQMessenger) connection() *amqp.Connection {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.conn
+}
+
+/*
+Método channel retorna o canal atual.
+*/
+func (client *RabbitMQMessenger) channel() *amqp.Channel {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.ch
+}
+
+/*
+Método publisher retorna o canal atual em modo de confirmação, usado nas publicações.
+*/
+func (client *RabbitMQMessenger) publisher() *rabbitMQConfirms {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.confirms
+}
+
+/*
+Método lost marca a conexão como perdida se o canal que falhou ainda for o atual,
+para que as publicações aguardem a reconexão feita por watch.
+*/
+func (client *RabbitMQMessenger) lost(ch *amqp.Channel, err error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.ch == ch {
+		client.state.down(err)
+	}
+}
+
+/*
+Método Health informa se a conexão com o RabbitMQ está ativa.
+*/
+func (client *RabbitMQMessenger) Health() error {
+	return client.state.health()
+}
+
 /*
 Método Publish publica uma mensagem na fila especificada.
-Retorna um ponteiro para o RabbitMQMessenger e um erro, se houver.
+O ID, o Timestamp e o ContentType do envelope são enviados nas propriedades da mensagem AMQP
+e os Headers, nos seus cabeçalhos.
+A mensagem é persistente e a publicação só é concluída após a confirmação do servidor,
+que no caso de mensagens persistentes ocorre após a gravação em disco.
+Durante uma queda da conexão, a publicação aguarda a reconexão e é refeita,
+por no máximo PublishTimeout ou até o contexto expirar.
+Se o canal fechar depois da publicação e antes da confirmação, a mensagem pode ter sido entregue
+e a nova publicação gera uma cópia com o mesmo ID; cabe ao consumidor descartar as cópias já processadas.
+Retorna:
+- ErrPublishNotConfirmed se o servidor rejeitar a mensagem, devolvê-la por não haver fila de destino
+ou não confirmá-la dentro do prazo.
+- Outro erro, se houver.
 */
-func (client *RabbitMQMessenger) Publish(queueName string, body []byte) error {
-	q, err := client.Ch.QueueDeclare(
-		queueName,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrRabbitMQQueueDeclare, err)
+func (client *RabbitMQMessenger) Publish(ctx context.Context, queueName string, envelope Envelope) error {
+	ctx, cancel := publishContext(ctx, client.PublishTimeout)
+	defer cancel()
+
+	envelope = envelope.withDefaults()
+	contentType := envelope.ContentType
+	if contentType == "" {
+		contentType = "text/plain"
 	}
 
-	err = client.Ch.Publish(
-		"",
-		q.Name,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        []byte(body),
-		},
-	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %v", ErrRabbitMQPublish, err)
+		}
+
+		err := client.state.wait(ctx)
+		if err != nil {
+			return err
+		}
+
+		confirms := client.publisher()
+		ch := confirms.ch
+		q, err := ch.QueueDeclare(
+			queueName,
+			true,
+			false,
+			false,
+			false,
+			nil,
+		)
+		if errors.Is(err, amqp.ErrClosed) {
+			client.lost(ch, err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRabbitMQQueueDeclare, err)
+		}
+
+		err = confirms.wait(ctx, q.Name, amqp.Publishing{
+			Headers:      rabbitMQTable(envelope.Headers),
+			ContentType:  contentType,
+			MessageId:    envelope.ID,
+			Timestamp:    envelope.Timestamp,
+			DeliveryMode: amqp.Persistent,
+			Body:         envelope.Body,
+		})
+		if errors.Is(err, amqp.ErrClosed) {
+			client.lost(ch, err)
+			continue
+		}
+		if errors.Is(err, ErrPublishNotConfirmed) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRabbitMQPublish, err)
+		}
+
+		log.Printf("Message %s sent: %s", envelope.ID, envelope.Body)
+		return nil
+	}
+}
+
+/*
+Método Consume consome mensagens da fila especificada.
+Mensagens com Nack são reenviadas após a espera da política Retry, por meio de filas de espera
+com TTL que devolvem a mensagem à fila original. Esgotadas as tentativas, ou com Reject,
+a mensagem é enviada à fila "<fila>.dlq".
+Com o cancelamento do contexto, o consumo é cancelado no servidor e as mensagens recebidas e ainda não entregues
+ao handler são devolvidas à fila.
+O consumo é refeito a cada reconexão até o cancelamento do contexto.
+Retorna um erro, se houver.
+*/
+func (client *RabbitMQMessenger) Consume(ctx context.Context, queueName string, handler func(Message)) error {
+	consumer := &rabbitMQConsumer{
+		ctx:       ctx,
+		queueName: queueName,
+		pool:      newDeliveryPool(ctx, client.Limits, client.limit, handler),
+	}
+
+	client.mu.Lock()
+	client.consumers = append(client.consumers, consumer)
+	client.mu.Unlock()
+
+	err := client.subscribe(consumer)
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrRabbitMQPublish, err)
+		client.unsubscribe(consumer)
+		return err
 	}
 
-	log.Printf("Message sent: %s", body)
+	context.AfterFunc(ctx, func() {
+		client.unsubscribe(consumer)
+	})
 	return nil
 }
 
 /*
-Método Consume consome mensagens da fila especificada.
-Retorna um canal de mensagens (<-chan amqp.Delivery) e um erro, se houver.
+Método unsubscribe remove o consumo da lista de consumos refeitos na reconexão.
 */
-func (client *RabbitMQMessenger) Consume(queueName string, handler func(Message)) error {
-	q, err := client.Ch.QueueDeclare(
-		queueName,
+func (client *RabbitMQMessenger) unsubscribe(consumer *rabbitMQConsumer) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	client.consumers = slices.DeleteFunc(client.consumers, func(c *rabbitMQConsumer) bool {
+		return c == consumer
+	})
+}
+
+/*
+Método subscribe declara a fila e a fila de mensagens mortas e inicia o consumo no canal atual.
+O consumo termina com o cancelamento do contexto ou com o fechamento do canal.
+*/
+func (client *RabbitMQMessenger) subscribe(consumer *rabbitMQConsumer) error {
+	ctx := consumer.ctx
+	ch := client.channel()
+
+	q, err := ch.QueueDeclare(
+		consumer.queueName,
 		true,
 		false,
 		false,
@@ -106,9 +511,15 @@ func (client *RabbitMQMessenger) Consume(queueName string, handler func(Message)
 		return fmt.Errorf("%w: %v", ErrRabbitMQQueueDeclare, err)
 	}
 
-	msgs, err := client.Ch.Consume(
+	_, err = ch.QueueDeclare(rabbitMQDeadLetterQueue(q.Name), true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRabbitMQQueueDeclare, err)
+	}
+
+	tag := "gozap-" + newRandomID()
+	msgs, err := ch.Consume(
 		q.Name,
-		"",
+		tag,
 		false, // autoAck set to false for manual acknowledgment
 		false,
 		false,
@@ -119,18 +530,49 @@ func (client *RabbitMQMessenger) Consume(queueName string, handler func(Message)
 		return fmt.Errorf("%w: %v", ErrRabbitMQConsume, err)
 	}
 
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = ch.Cancel(tag, false)
+		case <-done:
+		}
+	}()
+
 	go func() {
+		defer close(done)
 		for d := range msgs {
+			d := d
+			if ctx.Err() != nil {
+				_ = d.Reject(true)
+				continue
+			}
+
+			attempt := max(rabbitMQHeaderInt(d.Headers, rabbitMQHeaderAttempt), 1)
 			msg := Message{
-				Body: d.Body,
+				Envelope:    rabbitMQEnvelope(d),
+				Attempt:     attempt,
+				MaxAttempts: client.Retry.MaxAttempts,
+				Redelivered: d.Redelivered || attempt > 1,
 				Ack: func() error {
 					return d.Ack(false)
 				},
 				Nack: func() error {
-					return d.Nack(false, true)
+					if !client.Retry.CanRetry(attempt) {
+						return client.deadLetter(q.Name, d, attempt, DeadLetterReasonMaxAttempts)
+					}
+					return client.retry(q.Name, d, attempt+1, client.Retry.Delay(attempt))
+				},
+				Reject: func(reason string) error {
+					return client.deadLetter(q.Name, d, attempt, reason)
+				},
+				Requeue: func() error {
+					return client.retry(q.Name, d, attempt, client.Retry.Delay(1))
 				},
 			}
-			handler(msg)
+			if !consumer.pool.deliver(msg) {
+				_ = d.Reject(true)
+			}
 		}
 	}()
 
@@ -138,16 +580,229 @@ func (client *RabbitMQMessenger) Consume(queueName string, handler func(Message)
 }
 
 /*
-Método Close fecha o canal e a conexão do RabbitMQ.
+Método retry publica a mensagem na fila de espera correspondente ao intervalo informado.
+Ao expirar, a mensagem volta à fila original com o número da tentativa informado.
+A entrega atual só é confirmada após a confirmação da publicação; se a publicação falhar,
+a mensagem é devolvida à fila original.
+*/
+func (client *RabbitMQMessenger) retry(queueName string, d amqp.Delivery, attempt int, delay time.Duration) error {
+	retryQueue := rabbitMQRetryQueue(queueName, delay)
+
+	confirms := client.publisher()
+	_, err := confirms.ch.QueueDeclare(retryQueue, true, false, false, false, amqp.Table{
+		"x-message-ttl":             delay.Milliseconds(),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": queueName,
+	})
+	if err != nil {
+		_ = d.Nack(false, true)
+		return fmt.Errorf("%w: %v", ErrRabbitMQQueueDeclare, err)
+	}
+
+	headers := rabbitMQHeaders(d.Headers)
+	headers[rabbitMQHeaderAttempt] = int32(attempt)
+
+	ctx, cancel := publishContext(context.Background(), client.PublishTimeout)
+	defer cancel()
+
+	err = confirms.wait(ctx, retryQueue, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		MessageId:    d.MessageId,
+		Timestamp:    d.Timestamp,
+		DeliveryMode: amqp.Persistent,
+		Body:         d.Body,
+	})
+	if err != nil {
+		_ = d.Nack(false, true)
+		return fmt.Errorf("%w: %v", ErrRabbitMQPublish, err)
+	}
+
+	return d.Ack(false)
+}
+
+/*
+Método deadLetter publica a mensagem na fila de mensagens mortas com o motivo e o número de tentativas.
+A entrega atual só é confirmada após a confirmação da publicação; se a publicação falhar,
+a mensagem é devolvida à fila original.
+*/
+func (client *RabbitMQMessenger) deadLetter(queueName string, d amqp.Delivery, attempt int, reason string) error {
+	headers := rabbitMQHeaders(d.Headers)
+	headers[rabbitMQHeaderAttempt] = int32(attempt)
+	headers[rabbitMQHeaderReason] = reason
+	headers[rabbitMQHeaderQueue] = queueName
+	headers[rabbitMQHeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	headers[rabbitMQHeaderMessageID] = d.MessageId
+
+	ctx, cancel := publishContext(context.Background(), client.PublishTimeout)
+	defer cancel()
+
+	err := client.publisher().wait(ctx, rabbitMQDeadLetterQueue(queueName), amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		MessageId:    newRandomID(),
+		Timestamp:    d.Timestamp,
+		DeliveryMode: amqp.Persistent,
+		Body:         d.Body,
+	})
+	if err != nil {
+		_ = d.Nack(false, true)
+		return fmt.Errorf("%w: %v", ErrRabbitMQPublish, err)
+	}
+
+	log.Printf("Message moved to %s after %d attempts: %s", rabbitMQDeadLetterQueue(queueName), attempt, reason)
+	return d.Ack(false)
+}
+
+/*
+Método ListDeadLetters lista as mensagens da fila de mensagens mortas sem removê-las.
+As mensagens são lidas em um canal próprio e devolvidas à fila quando o canal é fechado.
+Parâmetros:
+- queueName: Fila de origem.
+- limit: Quantidade máxima de mensagens.
+Retorna:
+- As mensagens mortas e um erro, se houver.
+*/
+func (client *RabbitMQMessenger) ListDeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	ch, err := client.connection().Channel()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRabbitMQChannelFailed, err)
+	}
+	defer ch.Close()
+
+	res := []DeadLetter{}
+	for len(res) < limit {
+		d, ok, err := ch.Get(rabbitMQDeadLetterQueue(queueName), false)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRabbitMQConsume, err)
+		}
+		if !ok {
+			break
+		}
+		res = append(res, rabbitMQDeadLetter(queueName, d))
+	}
+
+	return res, nil
+}
+
+/*
+Quantidade máxima de mensagens mortas lidas por ReplayDeadLetter na busca de uma mensagem.
+As mensagens lidas ficam sem confirmação no canal até o fim da busca, então a busca é limitada;
+o limite cobre a maior página de ListDeadLetters, para que toda mensagem listada possa ser reprocessada.
+*/
+const rabbitMQReplayScanLimit = 1000
+
+/*
+Método ReplayDeadLetter devolve a mensagem morta à fila de origem com o ID original, reiniciando as tentativas.
+As demais mensagens lidas durante a busca são devolvidas à fila de mensagens mortas.
+A busca lê no máximo rabbitMQReplayScanLimit mensagens, a partir do início da fila de mensagens mortas.
+A mensagem morta só é removida após a confirmação da nova publicação pelo servidor.
+Parâmetros:
+- queueName: Fila de origem.
+- id: Identificador da mensagem morta.
+Retorna:
+- ErrDeadLetterNotFound se a mensagem não existir, ErrDeadLetterScanLimit se ela não estiver
+entre as primeiras rabbitMQReplayScanLimit mensagens, ou outro erro, se houver.
+*/
+func (client *RabbitMQMessenger) ReplayDeadLetter(queueName string, id string) error {
+	ch, err := client.connection().Channel()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRabbitMQChannelFailed, err)
+	}
+	defer ch.Close()
+
+	err = ch.Confirm(false)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRabbitMQChannelFailed, err)
+	}
+	confirmations := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+	for scanned := 0; ; scanned++ {
+		if scanned >= rabbitMQReplayScanLimit {
+			return ErrDeadLetterScanLimit
+		}
+
+		d, ok, err := ch.Get(rabbitMQDeadLetterQueue(queueName), false)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRabbitMQConsume, err)
+		}
+		if !ok {
+			return ErrDeadLetterNotFound
+		}
+		if d.MessageId != id {
+			continue
+		}
+
+		messageID, _ := d.Headers[rabbitMQHeaderMessageID].(string)
+		err = ch.Publish("", queueName, false, false, amqp.Publishing{
+			Headers:      rabbitMQHeaders(d.Headers),
+			ContentType:  d.ContentType,
+			MessageId:    messageID,
+			Timestamp:    d.Timestamp,
+			DeliveryMode: amqp.Persistent,
+			Body:         d.Body,
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRabbitMQPublish, err)
+		}
+		if confirmation := <-confirmations; !confirmation.Ack {
+			return ErrPublishNotConfirmed
+		}
+
+		return d.Ack(false)
+	}
+}
+
+/*
+Função rabbitMQDeadLetter converte uma mensagem da fila de mensagens mortas.
+*/
+func rabbitMQDeadLetter(queueName string, d amqp.Delivery) DeadLetter {
+	res := DeadLetter{
+		ID:      d.MessageId,
+		Queue:   queueName,
+		Headers: rabbitMQEnvelopeHeaders(d.Headers),
+		Body:    d.Body,
+		Attempt: rabbitMQHeaderInt(d.Headers, rabbitMQHeaderAttempt),
+	}
+	res.MessageID, _ = d.Headers[rabbitMQHeaderMessageID].(string)
+	if reason, ok := d.Headers[rabbitMQHeaderReason].(string); ok {
+		res.Reason = reason
+	}
+	if failedAt, ok := d.Headers[rabbitMQHeaderFailedAt].(string); ok {
+		res.FailedAt, _ = time.Parse(time.RFC3339, failedAt)
+	}
+	return res
+}
+
+/*
+Método Close interrompe a reconexão e fecha o canal e a conexão do RabbitMQ.
 Retorna um erro se houver falha ao fechar o canal ou a conexão.
 */
 func (client *RabbitMQMessenger) Close() error {
+	if client.done == nil {
+		return nil
+	}
+
+	client.mu.Lock()
+	select {
+	case <-client.done:
+		client.mu.Unlock()
+		return nil
+	default:
+		close(client.done)
+	}
+	conn, ch := client.conn, client.ch
+	client.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+
 	var errStrings []string
 
-	if err := client.Ch.Close(); err != nil {
+	if err := ch.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
 		errStrings = append(errStrings, fmt.Sprintf("%v: %v", ErrRabbitMQCloseChannel, err))
 	}
-	if err := client.Conn.Close(); err != nil {
+	if err := conn.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
 		errStrings = append(errStrings, fmt.Sprintf("%v: %v", ErrRabbitMQCloseConnection, err))
 	}
 
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+/*
+Estrutura rabbitMQConfirms publica no canal em modo de confirmação (publisher confirms) e acompanha
+a confirmação de cada publicação.
+As publicações são feitas com a flag mandatory: mensagens sem fila de destino são devolvidas pelo servidor
+com basic.return, sempre antes da confirmação da mesma publicação, e são associadas a ela pelo MessageId.
+Campos:
+- ch: Canal em modo de confirmação.
+- tag: Número da última publicação, igual ao delivery tag atribuído pelo servidor.
+- pending: Publicações aguardando confirmação, por delivery tag.
+- returned: Mensagens devolvidas como não roteáveis, por MessageId, até a confirmação correspondente.
+*/
+type rabbitMQConfirms struct {
+	ch *amqp.Channel
+
+	mu      sync.Mutex
+	tag     uint64
+	pending map[uint64]rabbitMQPending
+
+	returnedMu sync.Mutex
+	returned   map[string]amqp.Return
+}
+
+/*
+Estrutura rabbitMQPending representa uma publicação aguardando a confirmação do servidor.
+*/
+type rabbitMQPending struct {
+	messageID string
+	done      chan error
+}
+
+/*
+Função newRabbitMQConfirms coloca o canal em modo de confirmação e inicia o acompanhamento das confirmações.
+O canal de devoluções não tem buffer, para que cada devolução seja registrada antes de a confirmação
+seguinte ser entregue.
+Retorna um erro, se o canal não aceitar o modo de confirmação.
+*/
+func newRabbitMQConfirms(ch *amqp.Channel) (*rabbitMQConfirms, error) {
+	err := ch.Confirm(false)
+	if err != nil {
+		return nil, err
+	}
+
+	confirms := &rabbitMQConfirms{
+		ch:       ch,
+		pending:  map[uint64]rabbitMQPending{},
+		returned: map[string]amqp.Return{},
+	}
+
+	acks := ch.NotifyPublish(make(chan amqp.Confirmation, 64))
+	returns := ch.NotifyReturn(make(chan amqp.Return))
+	go confirms.run(acks, returns)
+
+	return confirms, nil
+}
+
+/*
+Método publish publica a mensagem e registra a publicação para aguardar sua confirmação.
+As publicações do canal são serializadas, para que o delivery tag de cada uma seja conhecido.
+Retorna o canal que recebe o resultado da confirmação e um erro, se a publicação falhar.
+*/
+func (c *rabbitMQConfirms) publish(queueName string, msg amqp.Publishing) (<-chan error, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.ch.Publish("", queueName, true, false, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	c.tag++
+	done := make(chan error, 1)
+	c.pending[c.tag] = rabbitMQPending{
+		messageID: msg.MessageId,
+		done:      done,
+	}
+	return done, nil
+}
+
+/*
+Método wait publica a mensagem e aguarda a confirmação do servidor ou o fim do contexto.
+Retorna:
+- ErrPublishNotConfirmed se o servidor rejeitar ou devolver a mensagem, ou se o contexto expirar antes da confirmação.
+- amqp.ErrClosed se o canal for fechado antes da confirmação; nesse caso a mensagem pode ter sido entregue.
+*/
+func (c *rabbitMQConfirms) wait(ctx context.Context, queueName string, msg amqp.Publishing) error {
+	done, err := c.publish(queueName, msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrPublishNotConfirmed, ctx.Err())
+	}
+}
+
+/*
+Método run registra as devoluções e resolve as publicações conforme as confirmações chegam.
+Quando o canal é fechado, as publicações pendentes recebem amqp.ErrClosed.
+*/
+func (c *rabbitMQConfirms) run(acks <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			c.returnedMu.Lock()
+			c.returned[ret.MessageId] = ret
+			c.returnedMu.Unlock()
+		case confirmation, ok := <-acks:
+			if !ok {
+				c.fail()
+				return
+			}
+			c.resolve(confirmation)
+		}
+	}
+}
+
+/*
+Método resolve entrega o resultado da confirmação à publicação correspondente.
+*/
+func (c *rabbitMQConfirms) resolve(confirmation amqp.Confirmation) {
+	c.mu.Lock()
+	pending, ok := c.pending[confirmation.DeliveryTag]
+	delete(c.pending, confirmation.DeliveryTag)
+	c.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	c.returnedMu.Lock()
+	ret, returned := c.returned[pending.messageID]
+	delete(c.returned, pending.messageID)
+	c.returnedMu.Unlock()
+
+	switch {
+	case returned:
+		pending.done <- fmt.Errorf("%w: message returned as unroutable: %d %s", ErrPublishNotConfirmed, ret.ReplyCode, ret.ReplyText)
+	case !confirmation.Ack:
+		pending.done <- fmt.Errorf("%w: message rejected by RabbitMQ", ErrPublishNotConfirmed)
+	default:
+		pending.done <- nil
+	}
+}
+
+/*
+Método fail encerra as publicações pendentes com amqp.ErrClosed, após o fechamento do canal.
+*/
+func (c *rabbitMQConfirms) fail() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for tag, pending := range c.pending {
+		pending.done <- amqp.ErrClosed
+		delete(c.pending, tag)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -21,6 +23,25 @@ var (
 	ErrRedisGetValue    = errors.New("redis.get_value_failed: erro ao obter valor do Redis")
 	ErrRedisKeyNotFound = errors.New("redis.key_not_found: chave não encontrada")
 	ErrRedisLockFailed  = errors.New("redis.lock_failed: erro ao adquirir o bloqueio no Redis")
+	ErrRedisLockHeld    = errors.New("redis.lock_held: o bloqueio pertence a outro processo")
+	ErrRedisLockLost    = errors.New("redis.lock_lost: o bloqueio expirou ou pertence a outro processo")
+)
+
+/*
+Scripts Lua que alteram o bloqueio somente se ele ainda pertencer ao token informado.
+A comparação e a alteração são atômicas, evitando liberar ou renovar o bloqueio de outro processo.
+*/
+var (
+	redisRefreshLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	redisReleaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
 )
 
 
@@ -105,27 +126,131 @@ func (r *RedisClient) Close() error {
 	return r.client.Close()
 }
 
+/*
+Implementação do método Ping para verificar a conexão.
+Retorna um erro, se o Redis não responder.
+*/
+func (r *RedisClient) Ping(ctx context.Context) error {
+	err := r.client.Ping(ctx).Err()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisConnection, err)
+	}
+	return nil
+}
+
 /*
 Implementação do método AcquireLock para adquirir um bloqueio.
-Tenta definir um valor no Redis com uma chave específica e um tempo de expiração.
-Retorna um erro, se houver.
+Define a chave com um token aleatório, somente se ela ainda não existir, com o tempo de expiração informado.
+O token identifica o dono do bloqueio e é exigido para renová-lo e liberá-lo.
+Retorna o token e ErrRedisLockHeld se o bloqueio pertencer a outro processo, ou outro erro, se houver.
 */
-func (r *RedisClient) AcquireLock(key string, expiration time.Duration) error {
-	set, err := r.client.SetNX(r.ctx, key, "true", expiration).Result()
+func (r *RedisClient) AcquireLock(key string, expiration time.Duration) (string, error) {
+	token := newRandomID()
+
+	set, err := r.client.SetNX(r.ctx, key, token, expiration).Result()
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrRedisLockFailed, err)
+		return "", fmt.Errorf("%w: %v", ErrRedisLockFailed, err)
 	}
 	if !set {
-		return ErrRedisLockFailed
+		return "", ErrRedisLockHeld
+	}
+	return token, nil
+}
+
+/*
+Implementação do método RefreshLock para renovar um bloqueio.
+Redefine o tempo de expiração somente se o bloqueio ainda pertencer ao token.
+Retorna ErrRedisLockLost se o bloqueio tiver expirado ou pertencer a outro processo, ou outro erro, se houver.
+*/
+func (r *RedisClient) RefreshLock(key string, token string, expiration time.Duration) error {
+	refreshed, err := redisRefreshLockScript.Run(r.ctx, r.client, []string{key}, token, expiration.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisLockFailed, err)
+	}
+	if refreshed == 0 {
+		return ErrRedisLockLost
+	}
+	return nil
+}
+
+/*
+Implementação do método CheckLock para verificar se o bloqueio ainda pertence ao token.
+Retorna ErrRedisLockLost se o bloqueio tiver expirado ou pertencer a outro processo, ou outro erro, se houver.
+*/
+func (r *RedisClient) CheckLock(key string, token string) error {
+	val, err := r.client.Get(r.ctx, key).Result()
+	if err == redis.Nil {
+		return ErrRedisLockLost
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisGetValue, err)
+	}
+	if val != token {
+		return ErrRedisLockLost
 	}
 	return nil
 }
 
 /*
 Implementação do método ReleaseLock para liberar um bloqueio.
-Remove um valor do Redis com uma chave específica.
+Remove a chave somente se o bloqueio ainda pertencer ao token (compare-and-delete).
+Retorna ErrRedisLockLost se o bloqueio já tiver expirado ou pertencer a outro processo, ou outro erro, se houver.
+*/
+func (r *RedisClient) ReleaseLock(key string, token string) error {
+	released, err := redisReleaseLockScript.Run(r.ctx, r.client, []string{key}, token).Int()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisDeleteKey, err)
+	}
+	if released == 0 {
+		return ErrRedisLockLost
+	}
+	return nil
+}
+
+/*
+Implementação do método Heartbeat para registrar um membro ativo em um grupo.
+O membro é guardado em um sorted set com a data de expiração como score.
+Retorna um erro, se houver.
+*/
+func (r *RedisClient) Heartbeat(key string, member string, ttl time.Duration) error {
+	expiresAt := float64(time.Now().Add(ttl).UnixMilli())
+	err := r.client.ZAdd(r.ctx, key, &redis.Z{Score: expiresAt, Member: member}).Err()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisSetValue, err)
+	}
+	return nil
+}
+
+/*
+Implementação do método Members para listar os membros ativos de um grupo.
+Membros cujo último heartbeat expirou são removidos antes da leitura.
+Retorna os membros em ordem alfabética e um erro, se houver.
+*/
+func (r *RedisClient) Members(key string) ([]string, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	err := r.client.ZRemRangeByScore(r.ctx, key, "-inf", "("+now).Err()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRedisDeleteKey, err)
+	}
+
+	members, err := r.client.ZRangeByScore(r.ctx, key, &redis.ZRangeBy{Min: now, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRedisGetValue, err)
+	}
+
+	sort.Strings(members)
+	return members, nil
+}
+
+/*
+Implementação do método Leave para remover um membro de um grupo.
 Retorna um erro, se houver.
 */
-func (r *RedisClient) ReleaseLock(key string) error {
-	return r.Del(key)
+func (r *RedisClient) Leave(key string, member string) error {
+	err := r.client.ZRem(r.ctx, key, member).Err()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisDeleteKey, err)
+	}
+	return nil
 }
@@ -0,0 +1,749 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/*
+Definição de variáveis de erro específicas para operações com o Redis Streams.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrRedisStreamsConnectionFailed = errors.New("redisstreams.connection_failed: failed to connect to Redis")
+	ErrRedisStreamsPublish          = errors.New("redisstreams.publish_failed: failed to publish a message")
+	ErrRedisStreamsConsume          = errors.New("redisstreams.consume_failed: failed to consume messages")
+	ErrRedisStreamsAck              = errors.New("redisstreams.ack_failed: failed to acknowledge a message")
+	ErrRedisStreamsDeadLetter       = errors.New("redisstreams.dead_letter_failed: failed to read or write the dead-letter stream")
+)
+
+/*
+Campos das entradas dos streams.
+*/
+const (
+	redisStreamFieldBody     = "body"
+	redisStreamFieldMeta     = "meta"
+	redisStreamFieldAttempt  = "attempt"
+	redisStreamFieldReason   = "reason"
+	redisStreamFieldQueue    = "queue"
+	redisStreamFieldFailedAt = "failed_at"
+)
+
+/*
+Quantidade de entradas lidas por XREADGROUP quando Limits.Prefetch não é informado.
+*/
+const redisStreamDefaultPrefetch = 10
+
+/*
+Script Lua que move as mensagens cuja espera terminou do sorted set de novas tentativas para o stream.
+Cada membro tem o formato "<tentativa>|<id>|<metadados>\n<conteúdo>"; o id evita que mensagens iguais se sobreponham.
+Os metadados são JSON e nunca contêm uma quebra de linha; membros sem metadados, de versões anteriores, também são aceitos.
+A leitura, a publicação e a remoção são atômicas, para que duas réplicas não publiquem a mesma mensagem.
+*/
+var redisStreamPromoteScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, 100)
+for _, member in ipairs(due) do
+	local a = string.find(member, "|", 1, true)
+	local b = string.find(member, "|", a + 1, true)
+	local c = string.find(member, "\n", b + 1, true)
+	local attempt = string.sub(member, 1, a - 1)
+	local meta, body = "", string.sub(member, b + 1)
+	if c then
+		meta = string.sub(member, b + 1, c - 1)
+		body = string.sub(member, c + 1)
+	end
+	if tonumber(ARGV[2]) > 0 then
+		redis.call("XADD", KEYS[2], "MAXLEN", "~", ARGV[2], "*", "body", body, "meta", meta, "attempt", attempt)
+	else
+		redis.call("XADD", KEYS[2], "*", "body", body, "meta", meta, "attempt", attempt)
+	end
+	redis.call("ZREM", KEYS[1], member)
+end
+return #due`)
+
+/*
+Script Lua que zera o tempo ocioso das entradas informadas somente se elas ainda pertencerem ao consumer informado.
+A verificação e o XCLAIM são atômicos, para que uma réplica nunca tome de volta uma entrada
+já reivindicada por outra com XAUTOCLAIM. Retorna a quantidade de entradas renovadas.
+*/
+var redisStreamTouchScript = redis.NewScript(`
+local touched = 0
+for i = 3, #ARGV do
+	if #redis.call("XPENDING", KEYS[1], ARGV[1], ARGV[i], ARGV[i], 1, ARGV[2]) > 0 then
+		redis.call("XCLAIM", KEYS[1], ARGV[1], ARGV[2], 0, ARGV[i], "JUSTID")
+		touched = touched + 1
+	end
+end
+return touched`)
+
+/*
+Script Lua que remove do grupo os consumers sem entregas pendentes e sem atividade há mais de ARGV[3] milissegundos,
+deixados pelas réplicas que caíram depois que suas entregas foram reivindicadas.
+O consumer informado em ARGV[2], desta réplica, nunca é removido. Retorna a quantidade de consumers removidos.
+*/
+var redisStreamPruneScript = redis.NewScript(`
+local removed = 0
+for _, consumer in ipairs(redis.call("XINFO", "CONSUMERS", KEYS[1], ARGV[1])) do
+	local info = {}
+	for i = 1, #consumer, 2 do
+		info[consumer[i]] = consumer[i + 1]
+	end
+	if info["name"] ~= ARGV[2] and info["pending"] == 0 and info["idle"] > tonumber(ARGV[3]) then
+		redis.call("XGROUP", "DELCONSUMER", KEYS[1], ARGV[1], info["name"])
+		removed = removed + 1
+	end
+end
+return removed`)
+
+/*
+Estrutura redisStreamMeta guarda os metadados do envelope no campo "meta" das entradas, em JSON.
+*/
+type redisStreamMeta struct {
+	ID          string            `json:"id"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+/*
+Função redisStreamEncodeMeta codifica os metadados do envelope para o campo "meta".
+*/
+func redisStreamEncodeMeta(envelope Envelope) string {
+	meta, _ := json.Marshal(redisStreamMeta{
+		ID:          envelope.ID,
+		Headers:     envelope.Headers,
+		ContentType: envelope.ContentType,
+		Timestamp:   envelope.Timestamp,
+	})
+	return string(meta)
+}
+
+/*
+Função redisStreamEnvelope converte uma entrada do stream no envelope publicado.
+Entradas sem metadados, de versões anteriores, resultam em um envelope apenas com o conteúdo.
+*/
+func redisStreamEnvelope(entry redis.XMessage) Envelope {
+	var meta redisStreamMeta
+	_ = json.Unmarshal([]byte(redisStreamValue(entry, redisStreamFieldMeta)), &meta)
+
+	return Envelope{
+		ID:          meta.ID,
+		Headers:     meta.Headers,
+		ContentType: meta.ContentType,
+		Timestamp:   meta.Timestamp,
+		Body:        []byte(redisStreamValue(entry, redisStreamFieldBody)),
+	}
+}
+
+/*
+Função redisStreamDeadLetterStream retorna o nome do stream de mensagens mortas da fila informada.
+*/
+func redisStreamDeadLetterStream(queueName string) string {
+	return queueName + ".dlq"
+}
+
+/*
+Função redisStreamRetryKey retorna o nome do sorted set com as mensagens aguardando uma nova tentativa.
+*/
+func redisStreamRetryKey(queueName string) string {
+	return queueName + ".retry"
+}
+
+/*
+Estrutura RedisStreamsMessenger implementa MessengerInterface com Redis Streams e consumer groups.
+Cada fila é um stream, consumido pelo grupo Group; cada réplica é um consumer do grupo.
+- Ack confirma a entrega com XACK e remove a entrada do stream.
+- Nack agenda uma nova tentativa no sorted set "<fila>.retry" ou, esgotadas as tentativas, envia a mensagem ao stream "<fila>.dlq".
+- Reject envia a mensagem diretamente ao stream "<fila>.dlq".
+Entregas não confirmadas por ClaimIdle, de réplicas que caíram, são reivindicadas com XAUTOCLAIM,
+e os consumers dessas réplicas são removidos do grupo depois que ficam sem entregas pendentes.
+Enquanto uma réplica processa uma mensagem, sua posse é renovada para que ela não seja reivindicada.
+Entradas confirmadas são removidas do stream. Como proteção, os streams são limitados a aproximadamente
+MaxLen entradas, descartando as mais antigas mesmo que ainda não tenham sido consumidas; zero não limita.
+As entradas são lidas em lotes de Limits.Prefetch e entregues ao handler por Limits.Workers goroutines.
+*/
+type RedisStreamsMessenger struct {
+	URL       string
+	Group     string
+	Retry     RetryPolicy
+	MaxLen    int64
+	ClaimIdle time.Duration
+	Limits    ConsumerLimits
+
+	client   *redis.Client
+	consumer string
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu       sync.Mutex
+	inFlight map[string]map[string]struct{}
+	limit    *inFlightLimit
+}
+
+/*
+Método Connect estabelece uma conexão com o Redis.
+O nome do consumer no grupo é o hostname seguido de um sufixo aleatório, único por conexão.
+Retorna um erro, se houver.
+*/
+func (client *RedisStreamsMessenger) Connect() error {
+	options, err := redis.ParseURL(client.URL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisStreamsConnectionFailed, err)
+	}
+
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	client.client = redis.NewClient(options)
+
+	err = client.client.Ping(client.ctx).Err()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisStreamsConnectionFailed, err)
+	}
+
+	hostname, _ := os.Hostname()
+	client.consumer = hostname + "-" + newRandomID()[:8]
+	client.inFlight = map[string]map[string]struct{}{}
+	client.limit = newInFlightLimit(client.Limits.MaxInFlight)
+	return nil
+}
+
+/*
+Método Publish adiciona uma mensagem ao stream da fila especificada.
+Retorna um erro, se houver.
+*/
+func (client *RedisStreamsMessenger) Publish(ctx context.Context, queueName string, envelope Envelope) error {
+	envelope = envelope.withDefaults()
+	err := client.add(ctx, queueName, map[string]interface{}{
+		redisStreamFieldBody:    envelope.Body,
+		redisStreamFieldMeta:    redisStreamEncodeMeta(envelope),
+		redisStreamFieldAttempt: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisStreamsPublish, err)
+	}
+	return nil
+}
+
+/*
+Método PublishAt agenda a mensagem no sorted set "<fila>.retry", de onde é movida ao stream a partir de at
+pelos consumidores da fila.
+Retorna um erro, se houver.
+*/
+func (client *RedisStreamsMessenger) PublishAt(ctx context.Context, queueName string, envelope Envelope, at time.Time) error {
+	envelope = envelope.withDefaults()
+	member := "1|" + newRandomID() + "|" + redisStreamEncodeMeta(envelope) + "\n" + string(envelope.Body)
+
+	err := client.client.ZAdd(ctx, redisStreamRetryKey(queueName), &redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: member,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisStreamsPublish, err)
+	}
+	return nil
+}
+
+/*
+Método add adiciona uma entrada ao stream, aplicando o limite MaxLen aproximado.
+*/
+func (client *RedisStreamsMessenger) add(ctx context.Context, stream string, values map[string]interface{}) error {
+	return client.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: client.MaxLen,
+		Approx: client.MaxLen > 0,
+		Values: values,
+	}).Err()
+}
+
+/*
+Método Consume cria o grupo de consumo do stream, se ainda não existir, e consome suas mensagens
+em uma goroutine até a conexão ser fechada ou o contexto ser cancelado.
+Retorna um erro, se houver.
+*/
+func (client *RedisStreamsMessenger) Consume(ctx context.Context, queueName string, handler func(Message)) error {
+	err := client.client.XGroupCreateMkStream(ctx, queueName, client.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("%w: %v", ErrRedisStreamsConsume, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	context.AfterFunc(client.ctx, cancel)
+
+	go client.consume(ctx, queueName, newDeliveryPool(ctx, client.Limits, client.limit, handler))
+	return nil
+}
+
+/*
+Método consume lê o stream com XREADGROUP e, a cada ciclo, publica as novas tentativas cuja espera terminou
+e reivindica as entregas abandonadas por outras réplicas.
+A posse das mensagens em processamento é renovada em segundo plano até a conexão ser fechada,
+inclusive enquanto a leitura aguarda vagas no limite de mensagens em processamento e após o cancelamento,
+para que elas possam ser confirmadas.
+*/
+func (client *RedisStreamsMessenger) consume(ctx context.Context, queueName string, pool *deliveryPool) {
+	var lastClaim time.Time
+
+	go func() {
+		ticker := time.NewTicker(client.ClaimIdle / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-client.ctx.Done():
+				return
+			case <-ticker.C:
+				client.touch(queueName)
+			}
+		}
+	}()
+
+	for ctx.Err() == nil {
+		err := redisStreamPromoteScript.Run(ctx, client.client,
+			[]string{redisStreamRetryKey(queueName), queueName},
+			time.Now().UnixMilli(), client.MaxLen,
+		).Err()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error scheduling retries of %s: %v", queueName, err)
+		}
+
+		if time.Since(lastClaim) >= client.ClaimIdle/2 {
+			client.claim(ctx, queueName, pool)
+			lastClaim = time.Now()
+		}
+
+		streams, err := client.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    client.Group,
+			Consumer: client.consumer,
+			Streams:  []string{queueName, ">"},
+			Count:    int64(client.Limits.prefetch(redisStreamDefaultPrefetch)),
+			Block:    time.Second,
+		}).Result()
+		if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error reading %s: %v", queueName, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, entry := range stream.Messages {
+				client.deliver(queueName, pool, entry, false)
+			}
+		}
+	}
+}
+
+/*
+Método deliver entrega a entrada ao handler.
+Se o consumo for cancelado antes da entrega, a entrada deixa de ter a posse renovada
+e é reivindicada por outra réplica após ClaimIdle.
+*/
+func (client *RedisStreamsMessenger) deliver(queueName string, pool *deliveryPool, entry redis.XMessage, claimed bool) {
+	if !pool.deliver(client.message(queueName, entry, claimed)) {
+		client.track(queueName, entry.ID, false)
+	}
+}
+
+/*
+Método claim reivindica as entregas sem confirmação há mais de ClaimIdle, de réplicas que caíram,
+e as entrega ao handler com a mesma tentativa.
+*/
+func (client *RedisStreamsMessenger) claim(ctx context.Context, queueName string, pool *deliveryPool) {
+	start := "0-0"
+	for {
+		next, entries, err := client.autoClaim(ctx, queueName, start)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error claiming pending messages of %s: %v", queueName, err)
+			}
+			return
+		}
+
+		for _, entry := range entries {
+			log.Printf("Claimed pending message %s of %s", entry.ID, queueName)
+			client.deliver(queueName, pool, entry, true)
+		}
+
+		if next == "0-0" || next == "" {
+			break
+		}
+		start = next
+	}
+
+	client.prune(ctx, queueName)
+}
+
+/*
+Método prune remove do grupo os consumers das réplicas que caíram, depois que suas entregas foram reivindicadas.
+Os consumers que ficam sem atividade por mais de ClaimIdle e sem entregas pendentes são considerados mortos;
+uma réplica ativa lê o stream a cada segundo e recria seu consumer na próxima leitura, se necessário.
+*/
+func (client *RedisStreamsMessenger) prune(ctx context.Context, queueName string) {
+	removed, err := redisStreamPruneScript.Run(ctx, client.client, []string{queueName},
+		client.Group, client.consumer, client.ClaimIdle.Milliseconds(),
+	).Int()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Error removing dead consumers of %s: %v", queueName, err)
+		}
+		return
+	}
+	if removed > 0 {
+		log.Printf("Removed %d dead consumers of %s", removed, queueName)
+	}
+}
+
+/*
+Método autoClaim executa XAUTOCLAIM a partir do cursor informado.
+O comando é enviado diretamente porque o XAutoClaim do go-redis v8 não reconhece
+a resposta com três elementos do Redis 7.
+Entradas já removidas do stream são confirmadas e descartadas.
+Retorna o próximo cursor, as entradas reivindicadas e um erro, se houver.
+*/
+func (client *RedisStreamsMessenger) autoClaim(ctx context.Context, queueName string, start string) (string, []redis.XMessage, error) {
+	reply, err := client.client.Do(ctx, "XAUTOCLAIM", queueName, client.Group, client.consumer,
+		client.ClaimIdle.Milliseconds(), start, "COUNT", 100).Slice()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(reply) < 2 {
+		return "", nil, fmt.Errorf("%w: unexpected XAUTOCLAIM reply", ErrRedisStreamsConsume)
+	}
+
+	next, _ := reply[0].(string)
+	raw, _ := reply[1].([]interface{})
+
+	entries := make([]redis.XMessage, 0, len(raw))
+	for _, item := range raw {
+		fields, ok := item.([]interface{})
+		if !ok || len(fields) != 2 {
+			continue
+		}
+
+		id, _ := fields[0].(string)
+		values, ok := fields[1].([]interface{})
+		if !ok {
+			_ = client.client.XAck(client.ctx, queueName, client.Group, id).Err()
+			continue
+		}
+
+		entry := redis.XMessage{
+			ID:     id,
+			Values: map[string]interface{}{},
+		}
+		for i := 0; i+1 < len(values); i += 2 {
+			key, _ := values[i].(string)
+			entry.Values[key] = values[i+1]
+		}
+		entries = append(entries, entry)
+	}
+
+	return next, entries, nil
+}
+
+/*
+Método touch zera o tempo ocioso das mensagens em processamento por esta réplica,
+para que elas não sejam reivindicadas por outra réplica enquanto aguardam o worker da sessão.
+Mensagens que já foram reivindicadas por outra réplica, por exemplo após uma pausa maior que ClaimIdle,
+continuam com a outra réplica.
+*/
+func (client *RedisStreamsMessenger) touch(queueName string) {
+	client.mu.Lock()
+	ids := make([]string, 0, len(client.inFlight[queueName]))
+	for id := range client.inFlight[queueName] {
+		ids = append(ids, id)
+	}
+	client.mu.Unlock()
+
+	if len(ids) == 0 {
+		return
+	}
+
+	args := make([]interface{}, 0, len(ids)+2)
+	args = append(args, client.Group, client.consumer)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	touched, err := redisStreamTouchScript.Run(client.ctx, client.client, []string{queueName}, args...).Int()
+	if err != nil {
+		if client.ctx.Err() == nil {
+			log.Printf("Error renewing pending messages of %s: %v", queueName, err)
+		}
+		return
+	}
+	if touched < len(ids) {
+		log.Printf("%d pending messages of %s are no longer owned by this consumer", len(ids)-touched, queueName)
+	}
+}
+
+/*
+Método track registra ou remove uma mensagem em processamento.
+Retorna false ao remover uma mensagem já confirmada.
+*/
+func (client *RedisStreamsMessenger) track(queueName string, id string, add bool) bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	ids, ok := client.inFlight[queueName]
+	if !ok {
+		ids = map[string]struct{}{}
+		client.inFlight[queueName] = ids
+	}
+
+	if add {
+		ids[id] = struct{}{}
+		return true
+	}
+
+	if _, ok := ids[id]; !ok {
+		return false
+	}
+	delete(ids, id)
+	return true
+}
+
+/*
+Método message cria a Message entregue ao handler, com as funções de confirmação.
+*/
+func (client *RedisStreamsMessenger) message(queueName string, entry redis.XMessage, claimed bool) Message {
+	envelope := redisStreamEnvelope(entry)
+	attempt, _ := strconv.Atoi(redisStreamValue(entry, redisStreamFieldAttempt))
+	attempt = max(attempt, 1)
+
+	client.track(queueName, entry.ID, true)
+
+	return Message{
+		Envelope:    envelope,
+		Attempt:     attempt,
+		MaxAttempts: client.Retry.MaxAttempts,
+		Redelivered: claimed || attempt > 1,
+		Ack: func() error {
+			if !client.track(queueName, entry.ID, false) {
+				return nil
+			}
+			return client.ack(queueName, entry.ID, nil)
+		},
+		Nack: func() error {
+			if !client.track(queueName, entry.ID, false) {
+				return nil
+			}
+			if !client.Retry.CanRetry(attempt) {
+				return client.deadLetter(queueName, entry.ID, envelope, attempt, DeadLetterReasonMaxAttempts)
+			}
+			return client.retry(queueName, entry.ID, envelope, attempt+1, client.Retry.Delay(attempt))
+		},
+		Reject: func(reason string) error {
+			if !client.track(queueName, entry.ID, false) {
+				return nil
+			}
+			return client.deadLetter(queueName, entry.ID, envelope, attempt, reason)
+		},
+		Requeue: func() error {
+			if !client.track(queueName, entry.ID, false) {
+				return nil
+			}
+			return client.retry(queueName, entry.ID, envelope, attempt, client.Retry.Delay(1))
+		},
+	}
+}
+
+/*
+Método ack confirma a entrega e remove a entrada do stream na mesma transação em que
+a mensagem é agendada para nova tentativa ou enviada à fila de mensagens mortas, quando informado.
+*/
+func (client *RedisStreamsMessenger) ack(queueName string, id string, before func(redis.Pipeliner)) error {
+	_, err := client.client.TxPipelined(client.ctx, func(pipe redis.Pipeliner) error {
+		if before != nil {
+			before(pipe)
+		}
+		pipe.XAck(client.ctx, queueName, client.Group, id)
+		pipe.XDel(client.ctx, queueName, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisStreamsAck, err)
+	}
+	return nil
+}
+
+/*
+Método retry agenda uma nova entrega da mensagem após a espera informada, com o número da tentativa informado,
+e confirma a entrega atual.
+*/
+func (client *RedisStreamsMessenger) retry(queueName string, id string, envelope Envelope, attempt int, delay time.Duration) error {
+	dueAt := time.Now().Add(delay)
+	member := strconv.Itoa(attempt) + "|" + newRandomID() + "|" + redisStreamEncodeMeta(envelope) + "\n" + string(envelope.Body)
+
+	return client.ack(queueName, id, func(pipe redis.Pipeliner) {
+		pipe.ZAdd(client.ctx, redisStreamRetryKey(queueName), &redis.Z{
+			Score:  float64(dueAt.UnixMilli()),
+			Member: member,
+		})
+	})
+}
+
+/*
+Método deadLetter envia a mensagem ao stream de mensagens mortas e confirma a entrega atual.
+*/
+func (client *RedisStreamsMessenger) deadLetter(queueName string, id string, envelope Envelope, attempt int, reason string) error {
+	err := client.ack(queueName, id, func(pipe redis.Pipeliner) {
+		pipe.XAdd(client.ctx, &redis.XAddArgs{
+			Stream: redisStreamDeadLetterStream(queueName),
+			MaxLen: client.MaxLen,
+			Approx: client.MaxLen > 0,
+			Values: map[string]interface{}{
+				redisStreamFieldBody:     envelope.Body,
+				redisStreamFieldMeta:     redisStreamEncodeMeta(envelope),
+				redisStreamFieldAttempt:  attempt,
+				redisStreamFieldReason:   reason,
+				redisStreamFieldQueue:    queueName,
+				redisStreamFieldFailedAt: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Message moved to %s after %d attempts: %s", redisStreamDeadLetterStream(queueName), attempt, reason)
+	return nil
+}
+
+/*
+Método ListDeadLetters lista as mensagens do stream de mensagens mortas sem removê-las.
+O ID de cada entrada é seu ID no stream.
+Parâmetros:
+- queueName: Fila de origem.
+- limit: Quantidade máxima de mensagens.
+Retorna:
+- As mensagens mortas e um erro, se houver.
+*/
+func (client *RedisStreamsMessenger) ListDeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	entries, err := client.client.XRangeN(client.ctx, redisStreamDeadLetterStream(queueName), "-", "+", int64(limit)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRedisStreamsDeadLetter, err)
+	}
+
+	res := make([]DeadLetter, 0, len(entries))
+	for _, entry := range entries {
+		res = append(res, redisStreamDeadLetter(queueName, entry))
+	}
+
+	return res, nil
+}
+
+/*
+Método ReplayDeadLetter republica a mensagem morta na fila de origem e a remove do stream de mensagens mortas.
+A nova publicação reinicia a contagem de tentativas.
+Parâmetros:
+- queueName: Fila de origem.
+- id: ID da mensagem no stream de mensagens mortas.
+Retorna:
+- ErrDeadLetterNotFound se a mensagem não existir, ou outro erro, se houver.
+*/
+func (client *RedisStreamsMessenger) ReplayDeadLetter(queueName string, id string) error {
+	stream := redisStreamDeadLetterStream(queueName)
+
+	entries, err := client.client.XRangeN(client.ctx, stream, id, id, 1).Result()
+	if err != nil {
+		if strings.Contains(err.Error(), "Invalid stream ID") {
+			return ErrDeadLetterNotFound
+		}
+		return fmt.Errorf("%w: %v", ErrRedisStreamsDeadLetter, err)
+	}
+	if len(entries) == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	_, err = client.client.TxPipelined(client.ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(client.ctx, &redis.XAddArgs{
+			Stream: queueName,
+			MaxLen: client.MaxLen,
+			Approx: client.MaxLen > 0,
+			Values: map[string]interface{}{
+				redisStreamFieldBody:    redisStreamValue(entries[0], redisStreamFieldBody),
+				redisStreamFieldMeta:    redisStreamValue(entries[0], redisStreamFieldMeta),
+				redisStreamFieldAttempt: 1,
+			},
+		})
+		pipe.XDel(client.ctx, stream, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisStreamsDeadLetter, err)
+	}
+
+	return nil
+}
+
+/*
+Função redisStreamDeadLetter converte uma entrada do stream de mensagens mortas.
+*/
+func redisStreamDeadLetter(queueName string, entry redis.XMessage) DeadLetter {
+	envelope := redisStreamEnvelope(entry)
+	res := DeadLetter{
+		ID:        entry.ID,
+		Queue:     queueName,
+		MessageID: envelope.ID,
+		Headers:   envelope.Headers,
+		Body:      envelope.Body,
+		Reason:    redisStreamValue(entry, redisStreamFieldReason),
+	}
+	res.Attempt, _ = strconv.Atoi(redisStreamValue(entry, redisStreamFieldAttempt))
+	res.FailedAt, _ = time.Parse(time.RFC3339, redisStreamValue(entry, redisStreamFieldFailedAt))
+	return res
+}
+
+/*
+Função redisStreamValue lê um campo da entrada do stream, retornando vazio quando ausente.
+*/
+func redisStreamValue(entry redis.XMessage, field string) string {
+	value, _ := entry.Values[field].(string)
+	return value
+}
+
+/*
+Método Health verifica a conexão com o Redis.
+O cliente do Redis reconecta a cada comando, então a verificação reflete a disponibilidade atual do servidor.
+*/
+func (client *RedisStreamsMessenger) Health() error {
+	if client.client == nil {
+		return ErrMessengerDisconnected
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := client.client.Ping(ctx).Err()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMessengerDisconnected, err)
+	}
+	return nil
+}
+
+/*
+Método Close encerra os consumidores e a conexão com o Redis.
+Mensagens entregues e não confirmadas permanecem pendentes no grupo e são reivindicadas por outra réplica
+após ClaimIdle.
+Retorna um erro, se houver.
+*/
+func (client *RedisStreamsMessenger) Close() error {
+	if client.cancel != nil {
+		client.cancel()
+	}
+	if client.client != nil {
+		return client.client.Close()
+	}
+	return nil
+}
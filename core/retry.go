@@ -0,0 +1,47 @@
+package core
+
+import (
+	"time"
+)
+
+/*
+Estrutura RetryPolicy define quantas tentativas são feitas e o intervalo entre elas.
+O intervalo cresce exponencialmente a partir de BaseDelay, limitado a MaxDelay.
+*/
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+/*
+Método Delay calcula o intervalo de espera antes da próxima tentativa.
+Parâmetros:
+- attempt: Número da tentativa que falhou, começando em 1.
+Retorna:
+- BaseDelay * 2^(attempt-1), limitado a MaxDelay.
+*/
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+
+	return delay
+}
+
+/*
+Método CanRetry indica se ainda é possível fazer uma nova tentativa após a tentativa informada.
+Parâmetros:
+- attempt: Número da tentativa que falhou, começando em 1.
+*/
+func (p RetryPolicy) CanRetry(attempt int) bool {
+	return attempt < p.MaxAttempts
+}
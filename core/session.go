@@ -1,35 +1,227 @@
 package core
 
 import (
+	"context"
+	"slices"
 	"sync"
+	"time"
 )
 
-// SessionManager controla o processamento por sessionId
-type SessionManager struct {
+/*
+Tempo máximo que uma tarefa recusada aguarda sua volta antes de deixar de reservar seu lugar na ordem da sessão,
+por exemplo quando a mensagem recusada foi entregue a outra réplica.
+*/
+const sessionDeferTimeout = 5 * time.Minute
+
+/*
+Estrutura SessionDispatcher executa as tarefas de cada sessão em ordem de chegada (FIFO).
+Tarefas da mesma sessão são executadas uma de cada vez; sessões diferentes são executadas em paralelo.
+Cada sessão com tarefas pendentes tem um worker próprio, encerrado quando sua fila esvazia.
+Com MaxPending, uma sessão lenta acumula no máximo MaxPending tarefas aguardando;
+as tarefas seguintes dessa sessão são recusadas por Dispatch, sem bloquear quem as envia,
+e aceitas depois na ordem em que foram recusadas.
+As tarefas recebem um contexto derivado de Context, cancelado também quando a sessão é revogada,
+para que as esperas entre as tentativas sejam interrompidas. Context nil equivale a context.Background().
+*/
+type SessionDispatcher struct {
+	MaxPending int
+	Context    context.Context
+
 	mu       sync.Mutex
-	sessions map[string]bool
+	queues   map[string]*sessionQueue
+	deferred map[string][]deferredTask
+	wg       sync.WaitGroup
+}
+
+/*
+Estrutura sessionQueue representa a fila de uma sessão com worker ativo.
+Campos:
+- tasks: Tarefas aguardando, em ordem de chegada.
+- ctx: Contexto repassado às tarefas, cancelado quando a sessão é revogada.
+- cancel: Cancela ctx.
+- done: Fechado quando o worker da sessão termina.
+*/
+type sessionQueue struct {
+	tasks  []func(ctx context.Context)
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
-func NewSessionManager() *SessionManager {
-	return &SessionManager{
-		sessions: make(map[string]bool),
+/*
+Estrutura deferredTask identifica uma tarefa recusada, que reserva seu lugar na ordem da sessão.
+Campos:
+- id: Identificador da tarefa, informado de novo quando ela volta.
+- seenAt: Momento em que a tarefa foi recusada pela última vez.
+*/
+type deferredTask struct {
+	id     string
+	seenAt time.Time
+}
+
+/*
+Função NewSessionDispatcher cria uma nova instância do SessionDispatcher.
+Retorna um ponteiro para a estrutura SessionDispatcher.
+*/
+func NewSessionDispatcher() *SessionDispatcher {
+	return &SessionDispatcher{
+		queues:   make(map[string]*sessionQueue),
+		deferred: make(map[string][]deferredTask),
 	}
 }
 
-func (sm *SessionManager) StartSession(sessionId string) bool {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+/*
+Método Dispatch enfileira a tarefa na fila da sessão, iniciando o worker da sessão se necessário.
+Se a sessão já tiver MaxPending tarefas aguardando, a tarefa é recusada e guarda seu lugar na ordem da sessão;
+enquanto houver tarefas recusadas, a sessão só aceita a mais antiga delas, quando sua fila tiver vaga.
+Quem envia deve devolver as tarefas recusadas e reenviá-las com o mesmo id.
+Parâmetros:
+- sessionId: Identificador da sessão.
+- id: Identificador da tarefa, por exemplo o ID da mensagem.
+- task: Tarefa a ser executada.
+Retorna:
+- true se a tarefa foi enfileirada, ou false se foi recusada.
+*/
+func (d *SessionDispatcher) Dispatch(sessionId string, id string, task func(ctx context.Context)) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	if sm.sessions[sessionId] {
+	now := time.Now()
+	deferred := slices.DeleteFunc(d.deferred[sessionId], func(t deferredTask) bool {
+		return now.Sub(t.seenAt) > sessionDeferTimeout
+	})
+	queue, running := d.queues[sessionId]
+	full := running && d.MaxPending > 0 && len(queue.tasks) >= d.MaxPending
+
+	i := slices.IndexFunc(deferred, func(t deferredTask) bool {
+		return t.id == id
+	})
+	if full || i > 0 || (i < 0 && len(deferred) > 0) {
+		if i < 0 {
+			deferred = append(deferred, deferredTask{id: id, seenAt: now})
+		} else {
+			deferred[i].seenAt = now
+		}
+		d.deferred[sessionId] = deferred
 		return false
 	}
 
-	sm.sessions[sessionId] = true
+	if i == 0 {
+		deferred = deferred[1:]
+	}
+	if len(deferred) == 0 {
+		delete(d.deferred, sessionId)
+	} else {
+		d.deferred[sessionId] = deferred
+	}
+
+	if running {
+		queue.tasks = append(queue.tasks, task)
+		return true
+	}
+
+	parent := d.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	queue = &sessionQueue{
+		tasks: []func(ctx context.Context){task},
+		done:  make(chan struct{}),
+	}
+	queue.ctx, queue.cancel = context.WithCancel(parent)
+	d.queues[sessionId] = queue
+	d.wg.Add(1)
+	go d.run(sessionId, queue)
 	return true
 }
 
-func (sm *SessionManager) EndSession(sessionId string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	delete(sm.sessions, sessionId)
+/*
+Método Revoke descarta as tarefas aguardando das sessões revogadas, cancela o contexto das tarefas em execução
+dessas sessões e aguarda elas terminarem, ou o contexto expirar. Usado quando as sessões passam a pertencer a outra réplica:
+as mensagens descartadas não são confirmadas e voltam à fila quando o Messenger é fechado.
+Parâmetros:
+- ctx: Contexto que limita a espera.
+- revoked: Função que indica se a sessão foi revogada.
+Retorna:
+- O erro do contexto, se o prazo expirar antes de as tarefas em execução terminarem.
+*/
+func (d *SessionDispatcher) Revoke(ctx context.Context, revoked func(sessionId string) bool) error {
+	var running []chan struct{}
+
+	d.mu.Lock()
+	for sessionId, queue := range d.queues {
+		if revoked(sessionId) {
+			clear(queue.tasks)
+			queue.tasks = nil
+			queue.cancel()
+			running = append(running, queue.done)
+		}
+	}
+	for sessionId := range d.deferred {
+		if revoked(sessionId) {
+			delete(d.deferred, sessionId)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, done := range running {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+/*
+Método Wait aguarda até que todas as tarefas enfileiradas sejam executadas.
+*/
+func (d *SessionDispatcher) Wait() {
+	d.wg.Wait()
+}
+
+/*
+Método Drain aguarda até que todas as tarefas enfileiradas sejam executadas ou o contexto expire.
+Usado no encerramento, para concluir as mensagens já recebidas dentro de um prazo.
+Retorna:
+- O erro do contexto, se o prazo expirar antes de as tarefas terminarem.
+*/
+func (d *SessionDispatcher) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+Método run executa as tarefas da sessão até a fila esvaziar.
+*/
+func (d *SessionDispatcher) run(sessionId string, queue *sessionQueue) {
+	defer d.wg.Done()
+	defer close(queue.done)
+	defer queue.cancel()
+
+	for {
+		d.mu.Lock()
+		if len(queue.tasks) == 0 {
+			delete(d.queues, sessionId)
+			d.mu.Unlock()
+			return
+		}
+		task := queue.tasks[0]
+		queue.tasks[0] = nil
+		queue.tasks = queue.tasks[1:]
+		d.mu.Unlock()
+
+		task(queue.ctx)
+	}
 }
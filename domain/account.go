@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+Definição de variáveis de erro específicas para operações com contas.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrAccountNotFound      = errors.New("account.not_found: account not found")
+	ErrAccountAlreadyExists = errors.New("account.already_exists: an account with this origin and externalId already exists")
+	ErrAccountInvalid       = errors.New("account.invalid: name, origin and externalId are required")
+	ErrAccountHasSessions   = errors.New("account.has_sessions: account still has sessions")
+	ErrAccountWebhookURL    = errors.New("account.invalid_webhook_url: webhookUrl must be an absolute http or https URL")
+	ErrAccountRotationGrace = errors.New("account.rotation_in_grace: the previous webhook secret is still valid, rotate again after it expires")
+)
+
+/*
+Estrutura Account representa um cliente da plataforma.
+Cada conta é identificada externamente pelo par Origin/ExternalId e agrupa as sessões WhatsApp do cliente.
+*/
+type Account struct {
+	ID         string
+	Name       string
+	Origin     string
+	ExternalId string
+	WebhookURL string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+
+	WebhookSecret                  string
+	WebhookPreviousSecret          string
+	WebhookPreviousSecretExpiresAt *time.Time
+}
+
+/*
+Método Validate verifica se os campos obrigatórios da conta estão preenchidos
+e se a URL de webhook, quando informada, é uma URL http ou https absoluta.
+Retorna:
+- ErrAccountInvalid se algum campo obrigatório estiver vazio.
+- ErrAccountWebhookURL se a URL de webhook for inválida.
+*/
+func (a *Account) Validate() error {
+	if strings.TrimSpace(a.Name) == "" || strings.TrimSpace(a.Origin) == "" || strings.TrimSpace(a.ExternalId) == "" {
+		return ErrAccountInvalid
+	}
+
+	if a.WebhookURL != "" {
+		webhookURL, err := url.ParseRequestURI(a.WebhookURL)
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+			return ErrAccountWebhookURL
+		}
+	}
+
+	return nil
+}
+
+/*
+Método WebhookSecrets retorna os segredos ativos usados para assinar os webhooks da conta.
+O segredo anterior continua ativo até expirar, permitindo que o receptor troque de segredo sem perder eventos.
+Parâmetros:
+- now: Momento de referência para a expiração do segredo anterior.
+*/
+func (a *Account) WebhookSecrets(now time.Time) []string {
+	secrets := []string{}
+	if a.WebhookSecret != "" {
+		secrets = append(secrets, a.WebhookSecret)
+	}
+	if a.WebhookPreviousSecret != "" && a.WebhookPreviousSecretExpiresAt != nil && now.Before(*a.WebhookPreviousSecretExpiresAt) {
+		secrets = append(secrets, a.WebhookPreviousSecret)
+	}
+	return secrets
+}
+
+/*
+Método ToResponse converte a conta na estrutura de resposta da API.
+Os segredos de webhook não são incluídos; eles só são exibidos na criação e na rotação.
+*/
+func (a *Account) ToResponse() GetAccountByIDResponse {
+	return GetAccountByIDResponse{
+		ID:         a.ID,
+		Name:       a.Name,
+		Origin:     a.Origin,
+		ExternalId: a.ExternalId,
+		WebhookURL: a.WebhookURL,
+		CreatedAt:  a.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:  a.UpdatedAt.Format(time.RFC3339),
+	}
+}
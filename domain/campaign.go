@@ -0,0 +1,429 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Definição de variáveis de erro específicas para as campanhas.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrCampaignNotFound          = errors.New("campaign.not_found: campaign not found")
+	ErrCampaignInvalid           = errors.New("campaign.invalid: sessionId, a template and at least one recipient are required")
+	ErrCampaignTooLarge          = errors.New("campaign.too_large: recipient list exceeds the size limit")
+	ErrCampaignInvalidTransition = errors.New("campaign.invalid_transition: campaign status does not allow this change")
+)
+
+/*
+Status possíveis de uma campanha.
+O ciclo de vida segue: running ⇄ paused → completed, ou cancelled.
+Uma campanha completed já enfileirou todos os destinatários; o envio de cada um segue no status das mensagens.
+*/
+const (
+	CampaignStatusRunning   = "running"
+	CampaignStatusPaused    = "paused"
+	CampaignStatusCompleted = "completed"
+	CampaignStatusCancelled = "cancelled"
+)
+
+/*
+Mapa campaignTransitions define, para cada status de destino, os status de origem permitidos.
+Os status completed e cancelled são finais.
+*/
+var campaignTransitions = map[string][]string{
+	CampaignStatusRunning:   {CampaignStatusPaused},
+	CampaignStatusPaused:    {CampaignStatusRunning},
+	CampaignStatusCompleted: {CampaignStatusRunning},
+	CampaignStatusCancelled: {CampaignStatusRunning, CampaignStatusPaused},
+}
+
+/*
+Status possíveis de um destinatário da campanha.
+Depois de enfileirado, o status informado é o da mensagem enviada: queued, sent, delivered, read ou failed.
+*/
+const (
+	CampaignRecipientPending   = "pending"
+	CampaignRecipientQueued    = "queued"
+	CampaignRecipientSent      = "sent"
+	CampaignRecipientDelivered = "delivered"
+	CampaignRecipientRead      = "read"
+	CampaignRecipientFailed    = "failed"
+	CampaignRecipientCancelled = "cancelled"
+)
+
+/*
+Limites das campanhas: quantidade de destinatários e tamanho da requisição de criação, incluindo o CSV.
+*/
+const (
+	MaxCampaignRecipients = 100000
+	MaxCampaignUploadSize = 32 << 20
+)
+
+/*
+Expressão campaignVariable encontra as variáveis {{nome}} do modelo da campanha.
+*/
+var campaignVariable = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+/*
+Estrutura CampaignTemplate representa a mensagem enviada a todos os destinatários da campanha.
+Message e Caption aceitam variáveis {{nome}}, substituídas pelas variáveis de cada destinatário;
+variáveis sem valor são substituídas por texto vazio.
+Campos:
+- Type: Tipo da mensagem (text, image, video, audio, ptt, document, sticker); vazio equivale a text.
+- Message: Conteúdo das mensagens de texto.
+- Caption: Legenda das imagens, vídeos e documentos.
+- Media: Mídia enviada antes em POST /media, informada por mediaId; url e base64 não são aceitos.
+*/
+type CampaignTemplate struct {
+	Type    string        `json:"type,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Caption string        `json:"caption,omitempty"`
+	Media   *MediaPayload `json:"media,omitempty"`
+}
+
+/*
+Método SendRequest monta a solicitação de envio da mensagem a um destinatário da campanha.
+Parâmetros:
+- sessionID: Identificador da sessão que envia a campanha.
+- recipient: Destinatário, com suas variáveis.
+*/
+func (t CampaignTemplate) SendRequest(sessionID string, recipient CampaignRecipient) SendRequest {
+	render := func(text string) string {
+		return campaignVariable.ReplaceAllStringFunc(text, func(match string) string {
+			return recipient.Variables[campaignVariable.FindStringSubmatch(match)[1]]
+		})
+	}
+
+	return SendRequest{
+		SessionId: sessionID,
+		To:        recipient.To,
+		Message:   render(t.Message),
+		Type:      t.Type,
+		Caption:   render(t.Caption),
+		Media:     t.Media,
+	}
+}
+
+/*
+Estrutura Campaign representa o envio de um mesmo modelo de mensagem a uma lista de destinatários.
+*/
+type Campaign struct {
+	ID          string
+	SessionID   string
+	Name        string
+	Template    CampaignTemplate
+	Status      string
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+/*
+Método ToResponse converte a campanha na estrutura de resposta da API.
+Parâmetros:
+- progress: Quantidade de destinatários por status.
+*/
+func (c *Campaign) ToResponse(progress map[string]int) GetCampaignByIDResponse {
+	res := GetCampaignByIDResponse{
+		ID:        c.ID,
+		SessionID: c.SessionID,
+		Name:      c.Name,
+		Template:  c.Template,
+		Status:    c.Status,
+		Progress:  newCampaignProgress(progress),
+		CreatedAt: c.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: c.UpdatedAt.Format(time.RFC3339),
+	}
+	if c.CompletedAt != nil {
+		completedAt := c.CompletedAt.Format(time.RFC3339)
+		res.CompletedAt = &completedAt
+	}
+
+	return res
+}
+
+/*
+Função newCampaignProgress monta o progresso da campanha a partir da quantidade de destinatários por status.
+*/
+func newCampaignProgress(progress map[string]int) CampaignProgressResponse {
+	res := CampaignProgressResponse{
+		Pending:   progress[CampaignRecipientPending],
+		Queued:    progress[CampaignRecipientQueued],
+		Sent:      progress[CampaignRecipientSent],
+		Delivered: progress[CampaignRecipientDelivered],
+		Read:      progress[CampaignRecipientRead],
+		Failed:    progress[CampaignRecipientFailed],
+		Cancelled: progress[CampaignRecipientCancelled],
+	}
+	for _, count := range progress {
+		res.Total += count
+	}
+
+	return res
+}
+
+/*
+Estrutura CampaignRecipient representa um destinatário da campanha.
+Status é o status do destinatário ou, depois de enfileirado, o da mensagem enviada.
+*/
+type CampaignRecipient struct {
+	ID         int64
+	CampaignID string
+	Position   int
+	To         string
+	Variables  map[string]string
+	Status     string
+	MessageID  *string
+	Error      *string
+	UpdatedAt  time.Time
+}
+
+/*
+Método ToResponse converte o destinatário na estrutura de resposta da API.
+*/
+func (r *CampaignRecipient) ToResponse() CampaignRecipientResponse {
+	return CampaignRecipientResponse{
+		Position:  r.Position,
+		To:        r.To,
+		Variables: r.Variables,
+		Status:    r.Status,
+		MessageID: r.MessageID,
+		Error:     r.Error,
+		UpdatedAt: r.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+/*
+Função ParseCampaignRecipientsCSV lê a lista de destinatários de um arquivo CSV.
+A primeira linha é o cabeçalho: a coluna "to" contém o número do destinatário
+e as demais colunas são as variáveis do modelo, pelo nome do cabeçalho.
+Parâmetros:
+- r: Conteúdo do arquivo CSV.
+Retorna:
+- Um slice com os destinatários e um erro, se houver.
+*/
+func ParseCampaignRecipientsCSV(r io.Reader) ([]CampaignRecipientRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: csv header: %v", ErrCampaignInvalid, err)
+	}
+
+	to := -1
+	for i, column := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if strings.EqualFold(header[i], "to") {
+			to = i
+		}
+	}
+	if to < 0 {
+		return nil, fmt.Errorf("%w: csv header must have a \"to\" column", ErrCampaignInvalid)
+	}
+
+	recipients := []CampaignRecipientRequest{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return recipients, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: csv: %v", ErrCampaignInvalid, err)
+		}
+		if len(recipients) == MaxCampaignRecipients {
+			return nil, ErrCampaignTooLarge
+		}
+
+		recipient := CampaignRecipientRequest{
+			To:        strings.TrimSpace(record[to]),
+			Variables: make(map[string]string, len(record)-1),
+		}
+		for i, value := range record {
+			if i != to {
+				recipient.Variables[header[i]] = value
+			}
+		}
+		recipients = append(recipients, recipient)
+	}
+}
+
+/*
+Quantidade padrão de destinatários enfileirados por consulta, duração da reserva de cada um
+e tempo máximo do envio de um destinatário.
+Um lote só envia os destinatários que ainda podem terminar dentro da reserva; os demais são liberados.
+*/
+const (
+	campaignDefaultBatch   = 100
+	campaignRecipientLease = time.Minute
+	campaignSendTimeout    = 10 * time.Second
+)
+
+/*
+Estrutura CampaignRunner envia as mensagens das campanhas em andamento, um lote de destinatários por vez.
+Cada destinatário é enviado com WhatsAppService.Send, que registra a mensagem e a publica no Messenger;
+o envio ao WhatsApp segue pelo consumer, como nas mensagens enviadas em POST /send.
+O ID da mensagem é derivado do destinatário e gravado nele antes da publicação, para que um destinatário
+reenviado após o fim da reserva reaproveite a mesma mensagem, cujas cópias o consumer descarta depois do envio.
+As réplicas da API dividem os destinatários com FOR UPDATE SKIP LOCKED, e destinatários de campanhas pausadas
+ou canceladas deixam de ser enviados assim que a mudança de status é gravada.
+*/
+type CampaignRunner struct {
+	CampaignRepository CampaignRepository
+	WhatsAppService    WhatsAppService
+	Interval           time.Duration
+	BatchSize          int
+}
+
+/*
+Método Run envia os destinatários pendentes a cada Interval, até o contexto ser cancelado.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento.
+*/
+func (c CampaignRunner) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Método dispatch envia os destinatários pendentes em lotes e conclui as campanhas sem destinatários pendentes.
+*/
+func (c CampaignRunner) dispatch(ctx context.Context) {
+	batch := c.BatchSize
+	if batch <= 0 {
+		batch = campaignDefaultBatch
+	}
+
+	for ctx.Err() == nil {
+		expiresAt := time.Now().Add(campaignRecipientLease)
+		recipients, err := c.CampaignRepository.ReservePendingRecipients(ctx, batch, campaignRecipientLease)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error reading campaign recipients: %v", err)
+			}
+			return
+		}
+
+		campaigns := map[string]*Campaign{}
+		for i, recipient := range recipients {
+			if time.Until(expiresAt) < campaignSendTimeout || ctx.Err() != nil {
+				c.release(recipients[i:])
+				break
+			}
+
+			campaign, ok := campaigns[recipient.CampaignID]
+			if !ok {
+				campaign, err = c.CampaignRepository.FindCampaignByID(ctx, recipient.CampaignID)
+				if err != nil {
+					log.Printf("Error reading campaign %s: %v", recipient.CampaignID, err)
+					continue
+				}
+				campaigns[recipient.CampaignID] = campaign
+			}
+
+			c.send(ctx, campaign, recipient)
+		}
+
+		if len(recipients) < batch {
+			break
+		}
+	}
+
+	err := c.CampaignRepository.CompleteCampaigns(ctx)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Error completing campaigns: %v", err)
+	}
+}
+
+/*
+Método release libera a reserva dos destinatários que não serão enviados neste lote.
+*/
+func (c CampaignRunner) release(recipients []CampaignRecipient) {
+	ids := make([]int64, len(recipients))
+	for i, recipient := range recipients {
+		ids[i] = recipient.ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), campaignSendTimeout)
+	defer cancel()
+
+	err := c.CampaignRepository.ReleaseRecipients(ctx, ids)
+	if err != nil {
+		log.Printf("Error releasing campaign recipients, they will be sent when the lease expires: %v", err)
+	}
+}
+
+/*
+Método send envia a mensagem da campanha a um destinatário, por no máximo campaignSendTimeout.
+Mensagens recusadas na validação marcam o destinatário como failed; falhas temporárias,
+como o Messenger indisponível, mantêm o destinatário pendente até o fim da reserva.
+*/
+func (c CampaignRunner) send(ctx context.Context, campaign *Campaign, recipient CampaignRecipient) {
+	ctx, cancel := context.WithTimeout(ctx, campaignSendTimeout)
+	defer cancel()
+
+	id := campaignMessageID(recipient)
+	res, err := c.WhatsAppService.send(ctx, campaign.Template.SendRequest(campaign.SessionID, recipient), id, func(ctx context.Context) error {
+		return c.CampaignRepository.AssignRecipientMessage(ctx, recipient.ID, id)
+	})
+	/*
+	   A campanha foi pausada ou cancelada depois da reserva: a mensagem registrada não é publicada e fica failed;
+	   se a campanha for retomada, o destinatário reaproveita a mesma mensagem.
+	*/
+	if errors.Is(err, ErrCampaignInvalidTransition) {
+		reason := "campaign is not running"
+		err = c.WhatsAppService.MessageRepository.UpdateMessageStatus(ctx, id, MessageStatusFailed, time.Now(), &reason)
+		if err != nil {
+			log.Printf("Error marking campaign %s message %s as failed: %v", campaign.ID, id, err)
+		}
+		return
+	}
+	if err != nil {
+		if !IsPermanentSendError(err) {
+			log.Printf("Error sending campaign %s to %s, it will be retried: %v", campaign.ID, recipient.To, err)
+			return
+		}
+
+		err = c.CampaignRepository.MarkRecipientFailed(ctx, recipient.ID, err.Error())
+		if err != nil {
+			log.Printf("Error marking campaign %s recipient %s as failed: %v", campaign.ID, recipient.To, err)
+		}
+		return
+	}
+
+	err = c.CampaignRepository.MarkRecipientQueued(ctx, recipient.ID, res.ID)
+	if err != nil {
+		log.Printf("Error marking campaign %s recipient %s as queued: %v", campaign.ID, recipient.To, err)
+	}
+}
+
+/*
+Função campaignMessageID deriva o ID da mensagem do destinatário, a partir da campanha e da posição na lista,
+no mesmo formato dos IDs gerados por newID.
+*/
+func campaignMessageID(recipient CampaignRecipient) string {
+	sum := sha256.Sum256([]byte(recipient.CampaignID + ":" + strconv.Itoa(recipient.Position)))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,543 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+/*
+Definição de variáveis de erro específicas para o pool de clientes WhatsApp.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrDeviceNotFound     = errors.New("whatsapp.device_not_found: device not found for session")
+	ErrDeviceUnavailable  = errors.New("whatsapp.device_unavailable: could not load the session device, try again later")
+	ErrClientPoolClosed   = errors.New("client_pool.closed: client pool is closed")
+	ErrClientLoginTimeout = errors.New("client_pool.login_timeout: timed out waiting for the session to log in")
+)
+
+const (
+	clientLoginTimeout      = 30 * time.Second
+	clientReconnectMaxDelay = time.Minute
+)
+
+/*
+Estrutura ClientPool mantém um cliente whatsmeow de longa duração por sessão.
+Os clientes são criados e conectados sob demanda, reconectados automaticamente quando
+o servidor encerra a conexão e desconectados após ficarem ociosos por IdleTimeout,
+exceto os marcados com Keep, que permanecem conectados para receber mensagens.
+Com Leases definido, o pool só conecta as sessões cuja posse foi adquirida por esta réplica
+e desconecta as sessões cuja posse for perdida.
+Com Owns definido, o pool também recusa as sessões atribuídas a outra réplica pela divisão das partições,
+mesmo que sua posse ainda não tenha sido adquirida pela nova dona.
+*/
+type ClientPool struct {
+	WhatsAppRepository WhatsAppRepository
+	IdleTimeout        time.Duration
+	LoginTimeout       time.Duration
+	Leases             *SessionLeases
+	Owns               func(sessionID string) bool
+
+	mu       sync.Mutex
+	clients  map[string]*pooledClient
+	handlers []SessionEventHandler
+	closed   bool
+	done     chan struct{}
+}
+
+/*
+Estrutura pooledClient representa um cliente mantido pelo pool.
+connectMu serializa as tentativas de conexão do mesmo cliente.
+*/
+type pooledClient struct {
+	sessionID string
+	client    *whatsmeow.Client
+	lease     *SessionLease
+	lastUsed  time.Time
+	kept      bool
+	connectMu sync.Mutex
+}
+
+/*
+Método close desconecta o cliente e, em seguida, libera a posse da sessão.
+*/
+func (e *pooledClient) close() {
+	e.client.Disconnect()
+	if e.lease != nil {
+		e.lease.Release()
+	}
+}
+
+/*
+Função NewClientPool cria uma nova instância do ClientPool.
+Parâmetros:
+- repository: Repositório usado para localizar o dispositivo de cada sessão.
+- idleTimeout: Tempo máximo sem uso antes de o cliente ser desconectado. Zero desativa a remoção.
+Retorna:
+- Um ponteiro para a estrutura ClientPool.
+*/
+func NewClientPool(repository WhatsAppRepository, idleTimeout time.Duration) *ClientPool {
+	pool := &ClientPool{
+		WhatsAppRepository: repository,
+		IdleTimeout:        idleTimeout,
+		LoginTimeout:       clientLoginTimeout,
+		clients:            make(map[string]*pooledClient),
+		done:               make(chan struct{}),
+	}
+
+	if idleTimeout > 0 {
+		go pool.evictIdle()
+	}
+
+	return pool
+}
+
+/*
+Método Get retorna o cliente conectado e autenticado da sessão.
+Se ainda não existir um cliente para a sessão, ele é criado a partir do dispositivo salvo.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- sessionID: Identificador da sessão.
+Retorna:
+- Um ponteiro para o cliente whatsmeow e um erro, se houver.
+*/
+func (p *ClientPool) Get(ctx context.Context, sessionID string) (*whatsmeow.Client, error) {
+	entry, err := p.entry(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.connect(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return entry.client, nil
+}
+
+/*
+Método Keep conecta a sessão e a mantém no pool mesmo sem uso, para que continue recebendo mensagens.
+Sessões mantidas não são removidas por ociosidade.
+A sessão só é marcada como mantida depois de conectada e autenticada; se a conexão falhar,
+a sessão é removida do pool e sua posse é liberada, para que a próxima sincronização, ou outra réplica, tente de novo.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- sessionID: Identificador da sessão.
+Retorna:
+- Um erro, se houver.
+*/
+func (p *ClientPool) Keep(ctx context.Context, sessionID string) error {
+	entry, err := p.entry(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if err = p.connect(ctx, entry); err != nil {
+		p.remove(entry)
+		return err
+	}
+
+	p.mu.Lock()
+	entry.kept = true
+	p.mu.Unlock()
+
+	return nil
+}
+
+/*
+Método IsKept indica se a sessão está no pool e marcada para permanecer conectada.
+Parâmetros:
+- sessionID: Identificador da sessão.
+*/
+func (p *ClientPool) IsKept(sessionID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.clients[sessionID]
+	return ok && entry.kept
+}
+
+/*
+Método AddEventHandler registra uma função que recebe os eventos de todos os clientes do pool.
+Parâmetros:
+- handler: Função chamada com a sessão, o cliente e o evento recebido.
+*/
+func (p *ClientPool) AddEventHandler(handler SessionEventHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers = append(p.handlers, handler)
+}
+
+/*
+Método Fence verifica se esta réplica ainda tem a posse da sessão, imediatamente antes de um envio.
+Se a posse tiver sido perdida, ou a sessão tiver sido atribuída a outra réplica,
+a sessão é removida do pool e o envio não deve ser feito.
+Sem Leases, todas as sessões pertencem a esta réplica.
+A verificação não é atômica com o envio, pois o WhatsApp não aceita um token de fencing:
+se a posse expirar entre a verificação e o envio, por exemplo numa pausa da réplica maior que o TTL da posse,
+a réplica antiga ainda faz esse único envio, possivelmente ao mesmo tempo que a nova dona.
+Fence reduz essa janela ao tempo de um envio, mas não a elimina.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- sessionID: Identificador da sessão.
+Retorna:
+- ErrSessionLeaseLost se a posse tiver sido perdida, ou outro erro, se houver.
+*/
+func (p *ClientPool) Fence(ctx context.Context, sessionID string) error {
+	if p.Leases == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	entry, ok := p.clients[sessionID]
+	p.mu.Unlock()
+
+	if !ok || entry.lease == nil {
+		return ErrSessionLeaseLost
+	}
+	if p.Owns != nil && !p.Owns(sessionID) {
+		go p.remove(entry)
+		return ErrSessionLeaseLost
+	}
+
+	err := entry.lease.Check(ctx)
+	if errors.Is(err, ErrSessionLeaseLost) {
+		go p.remove(entry)
+	}
+
+	return err
+}
+
+/*
+Método Evict remove a sessão do pool e desconecta o cliente, se existir.
+Parâmetros:
+- sessionID: Identificador da sessão.
+*/
+func (p *ClientPool) Evict(sessionID string) {
+	p.mu.Lock()
+	entry, ok := p.clients[sessionID]
+	if ok {
+		delete(p.clients, sessionID)
+	}
+	p.mu.Unlock()
+
+	if ok {
+		entry.close()
+	}
+}
+
+/*
+Método Retain remove do pool as sessões que deixaram de pertencer a esta réplica, incluindo as mantidas com Keep.
+A posse das sessões removidas é liberada para que a nova réplica dona as conecte.
+Parâmetros:
+- owns: Função que indica se a sessão pertence a esta réplica.
+*/
+func (p *ClientPool) Retain(owns func(sessionID string) bool) {
+	var removed []*pooledClient
+	p.mu.Lock()
+	for sessionID, entry := range p.clients {
+		if !owns(sessionID) {
+			delete(p.clients, sessionID)
+			removed = append(removed, entry)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, entry := range removed {
+		log.Printf("Session %s moved to another consumer, disconnecting", entry.sessionID)
+		entry.close()
+	}
+}
+
+/*
+Método Close encerra o pool, desconectando todos os clientes.
+Após o fechamento, chamadas a Get retornam ErrClientPoolClosed.
+*/
+func (p *ClientPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	entries := p.clients
+	p.clients = make(map[string]*pooledClient)
+	p.mu.Unlock()
+
+	for _, entry := range entries {
+		entry.close()
+	}
+}
+
+/*
+Método entry retorna a entrada da sessão, criando o cliente caso ainda não exista.
+*/
+func (p *ClientPool) entry(ctx context.Context, sessionID string) (*pooledClient, error) {
+	if p.Owns != nil && !p.Owns(sessionID) {
+		return nil, ErrSessionLeaseHeld
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrClientPoolClosed
+	}
+	if entry, ok := p.clients[sessionID]; ok {
+		entry.lastUsed = time.Now()
+		p.mu.Unlock()
+		return entry, nil
+	}
+	p.mu.Unlock()
+
+	/*
+	   Só a ausência do aparelho é definitiva; falhas do banco são temporárias e a mensagem volta a ser tentada.
+	*/
+	deviceStore, err := p.WhatsAppRepository.FindDeviceWM(ctx, sessionID)
+	if err == sql.ErrNoRows || (err == nil && deviceStore == nil) {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDeviceUnavailable, err)
+	}
+
+	/*
+	   Adquire a posse da sessão antes de conectar o aparelho.
+	   Se a posse já pertencer a esta réplica, outra chamada concorrente acabou de criar o cliente.
+	*/
+	var lease *SessionLease
+	if p.Leases != nil {
+		lease, err = p.Leases.Acquire(ctx, sessionID)
+		if errors.Is(err, ErrSessionLeaseHeld) {
+			p.mu.Lock()
+			existing, ok := p.clients[sessionID]
+			p.mu.Unlock()
+			if ok {
+				return existing, nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	/*
+	   A reconexão automática do whatsmeow é desativada para que o pool controle
+	   a reconexão e deixe de tentar quando a sessão for removida.
+	*/
+	client := whatsmeow.NewClient(deviceStore, nil)
+	client.EnableAutoReconnect = false
+
+	entry := &pooledClient{
+		sessionID: sessionID,
+		client:    client,
+		lease:     lease,
+		lastUsed:  time.Now(),
+	}
+	client.AddEventHandler(func(evt interface{}) {
+		p.handleEvent(entry, evt)
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		if lease != nil {
+			lease.Release()
+		}
+		return nil, ErrClientPoolClosed
+	}
+	if existing, ok := p.clients[sessionID]; ok {
+		if lease != nil {
+			lease.Release()
+		}
+		existing.lastUsed = time.Now()
+		return existing, nil
+	}
+	p.clients[sessionID] = entry
+
+	if lease != nil {
+		go p.watchLease(entry)
+	}
+
+	return entry, nil
+}
+
+/*
+Método watchLease remove a sessão do pool quando sua posse é perdida,
+para que esta réplica pare de usar o aparelho assumido por outra réplica.
+*/
+func (p *ClientPool) watchLease(entry *pooledClient) {
+	<-entry.lease.stop
+
+	select {
+	case <-entry.lease.Lost():
+		log.Printf("Session %s lease lost, removing it from the pool", entry.sessionID)
+		p.remove(entry)
+	default:
+	}
+}
+
+/*
+Método connect conecta o cliente, se necessário, e aguarda a autenticação.
+*/
+func (p *ClientPool) connect(ctx context.Context, entry *pooledClient) error {
+	entry.connectMu.Lock()
+	defer entry.connectMu.Unlock()
+
+	if !entry.client.IsConnected() {
+		err := entry.client.Connect()
+		if err != nil && !errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+			return err
+		}
+	}
+
+	return waitLoggedIn(ctx, entry.client, p.LoginTimeout)
+}
+
+/*
+Função waitLoggedIn aguarda até que o cliente esteja autenticado no WhatsApp.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- client: Cliente whatsmeow já conectado.
+- timeout: Tempo máximo de espera.
+Retorna:
+- ErrClientLoginTimeout se o cliente não autenticar dentro do prazo.
+*/
+func waitLoggedIn(ctx context.Context, client *whatsmeow.Client, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for !client.IsLoggedIn() {
+		select {
+		case <-ctx.Done():
+			return ErrClientLoginTimeout
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+/*
+Método handleEvent repassa o evento aos handlers registrados e trata os eventos de conexão do cliente.
+- Disconnected: inicia a reconexão com espera exponencial.
+- LoggedOut e StreamReplaced: remove a sessão do pool, pois não há como reconectar.
+*/
+func (p *ClientPool) handleEvent(entry *pooledClient, evt interface{}) {
+	p.mu.Lock()
+	handlers := p.handlers
+	p.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(entry.sessionID, entry.client, evt)
+	}
+
+	switch evt.(type) {
+	case *events.Disconnected:
+		go p.reconnect(entry)
+	case *events.LoggedOut, *events.StreamReplaced:
+		log.Printf("Session %s can no longer connect, removing it from the pool", entry.sessionID)
+		go p.remove(entry)
+	}
+}
+
+/*
+Método reconnect tenta reconectar o cliente até obter sucesso, a sessão ser removida ou o pool ser fechado.
+O intervalo entre as tentativas dobra a cada falha, limitado a clientReconnectMaxDelay.
+*/
+func (p *ClientPool) reconnect(entry *pooledClient) {
+	delay := time.Second
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-time.After(delay):
+		}
+
+		if !p.owns(entry) {
+			return
+		}
+
+		var err error
+		entry.connectMu.Lock()
+		if !entry.client.IsConnected() {
+			err = entry.client.Connect()
+		}
+		entry.connectMu.Unlock()
+
+		if err == nil || errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+			log.Printf("Session %s reconnected", entry.sessionID)
+			return
+		}
+
+		log.Printf("Error reconnecting session %s: %v", entry.sessionID, err)
+		delay = min(delay*2, clientReconnectMaxDelay)
+	}
+}
+
+/*
+Método owns indica se a entrada ainda é a entrada ativa da sessão no pool.
+*/
+func (p *ClientPool) owns(entry *pooledClient) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.closed && p.clients[entry.sessionID] == entry
+}
+
+/*
+Método remove retira a entrada do pool somente se ela ainda for a entrada ativa da sessão.
+*/
+func (p *ClientPool) remove(entry *pooledClient) {
+	p.mu.Lock()
+	owned := p.clients[entry.sessionID] == entry
+	if owned {
+		delete(p.clients, entry.sessionID)
+	}
+	p.mu.Unlock()
+
+	if owned {
+		entry.close()
+	}
+}
+
+/*
+Método evictIdle remove periodicamente os clientes sem uso há mais de IdleTimeout.
+*/
+func (p *ClientPool) evictIdle() {
+	ticker := time.NewTicker(p.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		var idle []*pooledClient
+		p.mu.Lock()
+		for sessionID, entry := range p.clients {
+			if !entry.kept && time.Since(entry.lastUsed) > p.IdleTimeout {
+				delete(p.clients, sessionID)
+				idle = append(idle, entry)
+			}
+		}
+		p.mu.Unlock()
+
+		for _, entry := range idle {
+			log.Printf("Session %s idle, disconnecting", entry.sessionID)
+			entry.close()
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"gozap/core"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"go.mau.fi/whatsmeow/store/sqlstore"
+)
+
+/*
+Definição de variáveis de erro específicas para o consumo da fila de mensagens.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrConsumerRedisRequired = errors.New("consumer.redis_required: QUEUE_PARTITIONS requires Redis")
+)
+
+/*
+Estrutura Consumer contém as conexões usadas no consumo da fila de mensagens.
+Esta estrutura é responsável por montar o pool de clientes, os webhooks e os workers das sessões
+e por enviar as mensagens publicadas pela API.
+É usada pelo binário do consumer e pela API no modo de binário único, em que os dois compartilham o Messenger.
+Campos:
+- Postgres: Conexão com o banco de dados da aplicação.
+- WhatsMeowDB: Conexão com o banco de dados do whatsmeow.
+- Redis: Conexão com o Redis, usada na posse das sessões e na divisão das partições.
+Sem Redis, a réplica é a única e envia por todas as sessões sem disputar sua posse; QUEUE_PARTITIONS não pode ser usado.
+- Messenger: Conexão com o driver de mensageria.
+- NewMessenger: Cria uma conexão por partição consumida, quando QUEUE_PARTITIONS é usado.
+*/
+type Consumer struct {
+	Postgres     *sql.DB
+	WhatsMeowDB  *sqlstore.Container
+	Redis        *core.RedisClient
+	Messenger    core.MessengerInterface
+	NewMessenger func() core.MessengerInterface
+}
+
+/*
+Método Run inicia o consumo da fila de mensagens e bloqueia até o contexto ser cancelado.
+Com o cancelamento, o recebimento de mensagens é interrompido e as mensagens já recebidas são enviadas e confirmadas,
+por no máximo SHUTDOWN_TIMEOUT; as que não terminarem a tempo voltam à fila quando o Messenger é fechado.
+Ao retornar, a réplica saiu do grupo de consumers e o pool de clientes e os webhooks foram encerrados.
+Parâmetros:
+- ctx: Contexto cujo cancelamento encerra o consumo.
+Retorna:
+- Um erro, se o consumo não puder ser iniciado.
+*/
+func (c Consumer) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	/*
+	   Cria o pool de clientes WhatsApp reutilizados entre as mensagens.
+	   Clientes sem uso por CLIENT_IDLE_TIMEOUT são desconectados.
+	*/
+	clientPool := NewClientPool(
+		WhatsAppRepository{
+			WhatsMeowDB: c.WhatsMeowDB,
+			DB:          c.Postgres,
+		},
+		core.GetEnvDuration("CLIENT_IDLE_TIMEOUT", 10*time.Minute),
+	)
+	defer clientPool.Close()
+
+	/*
+	   A posse de cada sessão é disputada entre as réplicas do consumer por meio de bloqueios no Redis,
+	   para que duas réplicas nunca usem o mesmo aparelho ao mesmo tempo.
+	*/
+	if c.Redis != nil {
+		clientPool.Leases = &SessionLeases{
+			Redis: c.Redis,
+			TTL:   core.GetEnvDuration("SESSION_LEASE_TTL", 30*time.Second),
+		}
+	}
+
+	/*
+	   Mantém o status das sessões atualizado a partir dos eventos dos clientes,
+	   por exemplo marcando como logged_out um aparelho desconectado pelo celular.
+	*/
+	sessionRepository := SessionRepository{
+		DB: c.Postgres,
+	}
+	clientPool.AddEventHandler(SessionStateHandler{
+		SessionRepository: sessionRepository,
+	}.HandleEvent)
+
+	/*
+	   Encaminha as mensagens, confirmações e presenças recebidas para os webhooks das contas.
+	   Entregas que falham são repetidas com espera exponencial, guardadas no Postgres durante a espera.
+	*/
+	webhooks := NewWebhookDispatcher(
+		AccountRepository{
+			DB: c.Postgres,
+		},
+		WebhookRetryRepository{
+			DB: c.Postgres,
+		},
+		core.GetEnvInt("WEBHOOK_WORKERS", 4),
+		core.RetryPolicy{
+			MaxAttempts: core.GetEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			BaseDelay:   core.GetEnvDuration("WEBHOOK_RETRY_DELAY", time.Second),
+			MaxDelay:    core.GetEnvDuration("WEBHOOK_RETRY_MAX_DELAY", 5*time.Minute),
+		},
+	)
+	defer webhooks.Close()
+
+	/*
+	   Atualiza o status das mensagens enviadas a partir das confirmações de entrega, leitura e reprodução.
+	*/
+	messageRepository := MessageRepository{
+		DB: c.Postgres,
+	}
+	clientPool.AddEventHandler(MessageStatusHandler{
+		MessageRepository: messageRepository,
+	}.HandleEvent)
+
+	clientPool.AddEventHandler(InboundHandler{
+		Webhooks: webhooks,
+	}.HandleEvent)
+
+	/*
+	   Com QUEUE_PARTITIONS, as sessões são divididas entre as réplicas ativas do consumer.
+	   Cada réplica envia heartbeats ao Redis; quando uma réplica entra ou morre,
+	   as partições são redistribuídas e cada réplica passa a consumir apenas as filas das suas partições.
+	*/
+	partitions := core.GetEnvInt("QUEUE_PARTITIONS", 0)
+	if partitions > 0 && c.Redis == nil {
+		return ErrConsumerRedisRequired
+	}
+	var sharding *SessionSharding
+	if partitions > 0 {
+		sharding = &SessionSharding{
+			Membership: &core.Membership{
+				Redis:      c.Redis,
+				Key:        "gozap:consumers",
+				InstanceID: consumerID(),
+				TTL:        core.GetEnvDuration("CONSUMER_HEARTBEAT_TTL", 15*time.Second),
+			},
+			Partitions: partitions,
+		}
+		clientPool.Owns = sharding.Owns
+	}
+
+	/*
+	   Mantém as sessões prontas conectadas para receber mensagens.
+	   Novas sessões são incluídas a cada SESSION_SYNC_INTERVAL.
+	*/
+	go SessionKeeper{
+		ClientPool:        clientPool,
+		SessionRepository: sessionRepository,
+		Interval:          core.GetEnvDuration("SESSION_SYNC_INTERVAL", time.Minute),
+		Sharding:          sharding,
+	}.Run(ctx)
+
+	sendMessage := SendMessage{
+		ClientPool: clientPool,
+		MediaLoader: MediaLoader{
+			MediaRepository: MediaRepository{
+				DB: c.Postgres,
+			},
+		},
+		MessageRepository: messageRepository,
+	}
+
+	/*
+	   As mensagens de cada sessão são enviadas em ordem, uma de cada vez, e sessões diferentes em paralelo.
+	   Falhas temporárias são repetidas no próprio worker da sessão, que só passa à mensagem seguinte
+	   depois de enviar a atual ou enviá-la à fila de mensagens mortas.
+	   O encerramento e a revogação da partição interrompem as esperas entre as tentativas,
+	   e a mensagem interrompida volta à fila sem contar uma tentativa.
+	   Uma sessão lenta acumula no máximo SESSION_MAX_PENDING mensagens; as seguintes voltam à fila sem contar
+	   uma tentativa e são aceitas depois na ordem em que chegaram, sem segurar os workers do Messenger.
+	*/
+	sessionDispatcher := core.NewSessionDispatcher()
+	sessionDispatcher.MaxPending = core.GetEnvInt("SESSION_MAX_PENDING", 20)
+	sessionDispatcher.Context = ctx
+	sessionRetry := core.RetryPolicy{
+		MaxAttempts: core.GetEnvInt("SESSION_RETRY_ATTEMPTS", 3),
+		BaseDelay:   core.GetEnvDuration("SESSION_RETRY_DELAY", 500*time.Millisecond),
+		MaxDelay:    core.GetEnvDuration("SESSION_RETRY_MAX_DELAY", 5*time.Second),
+	}
+
+	handler := func(msg core.Message) {
+		ProcessMessage(sendMessage, sessionDispatcher, sessionRetry, msg)
+	}
+
+	if sharding == nil {
+		err := c.Messenger.Consume(ctx, os.Getenv("QUEUE_MESSAGE"), handler)
+		if err != nil {
+			return err
+		}
+
+		<-ctx.Done()
+		c.drain(sessionDispatcher)
+		return nil
+	}
+
+	/*
+	   Antes de uma partição revogada ser fechada, as mensagens dela que aguardam o worker da sessão são descartadas,
+	   voltando à fila para o novo dono, e as que estão sendo enviadas terminam, por no máximo SHUTDOWN_TIMEOUT.
+	   Envios que não terminarem a tempo são barrados pelo pool, que recusa as sessões atribuídas a outra réplica.
+	*/
+	partitionConsumer := &core.PartitionConsumer{
+		Context:      ctx,
+		NewMessenger: c.NewMessenger,
+		Queue:        os.Getenv("QUEUE_MESSAGE"),
+		Handler:      handler,
+		Revoke: func(revoked []int) {
+			ctx, cancel := context.WithTimeout(context.Background(), core.GetEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+			defer cancel()
+
+			err := sessionDispatcher.Revoke(ctx, func(sessionID string) bool {
+				return slices.Contains(revoked, core.PartitionOf(sessionID, partitions))
+			})
+			if err != nil {
+				log.Printf("Timeout draining revoked partitions %v: %v", revoked, err)
+			}
+		},
+	}
+	defer partitionConsumer.Close()
+
+	sharding.Membership.OnChange = func(members []string) {
+		partitionConsumer.Assign(sharding.OwnedPartitions())
+		clientPool.Retain(sharding.Owns)
+	}
+
+	/*
+	   Run retorna após o cancelamento do contexto, quando a réplica já saiu do grupo,
+	   para que as demais assumam suas partições imediatamente.
+	*/
+	sharding.Membership.Run(ctx)
+	c.drain(sessionDispatcher)
+	return nil
+}
+
+/*
+Método drain aguarda os workers das sessões concluírem as mensagens já recebidas, por no máximo SHUTDOWN_TIMEOUT.
+*/
+func (c Consumer) drain(sessionDispatcher *core.SessionDispatcher) {
+	log.Println("Draining in-flight messages")
+
+	ctx, cancel := context.WithTimeout(context.Background(), core.GetEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+
+	err := sessionDispatcher.Drain(ctx)
+	if err != nil {
+		log.Printf("Shutdown timeout reached, unfinished messages will be redelivered: %v", err)
+	}
+}
+
+/*
+Função consumerID retorna o identificador desta réplica no grupo de consumers.
+Usa CONSUMER_ID ou, se vazio, o hostname do container.
+*/
+func consumerID() string {
+	if id := os.Getenv("CONSUMER_ID"); id != "" {
+		return id
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("Could not determine consumer id: %v", err)
+	}
+	return hostname
+}
+
+/*
+Função ProcessMessage decodifica a mensagem e a enfileira no worker da sua sessão.
+Mensagens que não podem ser decodificadas vão direto para a fila de mensagens mortas.
+Se a fila da sessão estiver cheia, a mensagem volta à fila com Requeue, sem contar uma tentativa.
+*/
+func ProcessMessage(sendMessage SendMessage, sessionDispatcher *core.SessionDispatcher, retry core.RetryPolicy, msg core.Message) {
+
+	var incomingMsg Message
+	err := json.Unmarshal(msg.Body, &incomingMsg)
+	if err != nil {
+		log.Printf("Error unmarshalling message %s: %v", msg.ID, err)
+		msg.Reject("invalid_json")
+		return
+	}
+
+	accepted := sessionDispatcher.Dispatch(incomingMsg.SessionId, msg.ID, func(ctx context.Context) {
+		DeliverMessage(ctx, sendMessage, retry, &incomingMsg, msg)
+	})
+	if !accepted {
+		msg.Requeue()
+	}
+}
+
+/*
+Função DeliverMessage envia a mensagem, repetindo as falhas temporárias no próprio worker da sessão.
+A mensagem segura o worker até ser enviada ou ir para a fila de mensagens mortas,
+para que as mensagens seguintes da sessão nunca passem à sua frente.
+Cada rodada repete o envio conforme a política retry e conta como uma tentativa do driver;
+entre as rodadas, o worker aguarda retry.MaxDelay. Esgotadas as tentativas, a mensagem é rejeitada.
+Sem QUEUE_PARTITIONS, mensagens de sessões que pertencem a outra réplica voltam à fila com Requeue, sem contar uma tentativa.
+Com QUEUE_PARTITIONS, elas continuam à frente da fila da sessão, sem contar tentativas, durante a troca de dono da partição:
+na réplica antiga, até a revogação da partição cancelar ctx e a mensagem voltar à fila, sem confirmação, na sua posição
+original quando o Messenger é fechado; na réplica nova, até a réplica antiga liberar a posse da sessão.
+Com o cancelamento de ctx, as esperas são interrompidas e a mensagem volta à fila com Requeue, sem contar uma tentativa.
+*/
+func DeliverMessage(ctx context.Context, sendMessage SendMessage, retry core.RetryPolicy, incomingMsg *Message, msg core.Message) {
+	/*
+	   Mensagens agendadas só são enviadas se ainda corresponderem ao agendamento;
+	   cópias de agendamentos cancelados ou alterados são descartadas.
+	*/
+	if scheduledAt, ok := msg.Headers[MessageHeaderScheduledAt]; ok {
+		due, err := sendMessage.Due(incomingMsg, scheduledAt)
+		if err != nil {
+			log.Printf("Error checking scheduled message %s: %v", incomingMsg.ID, err)
+			msg.Nack()
+			return
+		}
+		if !due {
+			log.Printf("Discarding cancelled or rescheduled message %s", incomingMsg.ID)
+			msg.Ack()
+			return
+		}
+	}
+
+	/*
+	   Cópias de mensagens já enviadas são descartadas, por exemplo as publicadas de novo pelo driver
+	   quando a conexão cai depois da publicação e antes da confirmação. Se a verificação falhar, a mensagem é enviada.
+	*/
+	sent, err := sendMessage.Sent(incomingMsg)
+	if err != nil {
+		log.Printf("Error checking message %s: %v", incomingMsg.ID, err)
+	}
+	if sent {
+		log.Printf("Discarding duplicate of sent message %s", incomingMsg.ID)
+		msg.Ack()
+		return
+	}
+
+	round := msg.Attempt
+	for {
+		err := sendRound(ctx, sendMessage, retry, incomingMsg)
+		if err == nil {
+			log.Printf("Processing message for session %s: %s", incomingMsg.SessionId, incomingMsg.Message)
+			msg.Ack()
+			return
+		}
+
+		if IsPermanentSendError(err) {
+			log.Printf("Discarding message %s: %v", incomingMsg.ID, err)
+			sendMessage.Fail(incomingMsg, err)
+			msg.Ack()
+			return
+		}
+
+		/*
+		   Sem QUEUE_PARTITIONS, a mensagem pode chegar a uma réplica que não tem a posse da sessão.
+		   Ela volta à fila sem contar uma tentativa, até ser recebida pela réplica dona da sessão
+		   ou até a posse de uma réplica que caiu expirar; nesse modo, as réplicas não garantem a ordem entre si.
+		*/
+		if isSessionOwnedElsewhere(err) && sendMessage.ClientPool.Owns == nil {
+			log.Printf("Requeueing message %s of session %s owned by another consumer", incomingMsg.ID, incomingMsg.SessionId)
+			msg.Requeue()
+			return
+		}
+
+		/*
+		   Com QUEUE_PARTITIONS, a sessão está trocando de réplica. Voltar à fila por Requeue colocaria a mensagem
+		   atrás das seguintes da sessão, então ela segura o worker da sessão e é tentada de novo sem contar uma tentativa.
+		*/
+		if isSessionOwnedElsewhere(err) {
+			if !sleep(ctx, retry.MaxDelay) {
+				log.Printf("Releasing message %s of session %s moved to another consumer", incomingMsg.ID, incomingMsg.SessionId)
+				return
+			}
+			continue
+		}
+
+		log.Printf("Error sending message (attempt %d/%d): %v", round, msg.MaxAttempts, err)
+
+		/*
+		   Na última tentativa a mensagem vai para a fila de mensagens mortas e é marcada como failed.
+		   Se for reprocessada a partir da fila de mensagens mortas, o status volta a ser atualizado no envio.
+		*/
+		if round >= msg.MaxAttempts {
+			sendMessage.Fail(incomingMsg, err)
+			msg.Reject(core.DeadLetterReasonMaxAttempts)
+			return
+		}
+		if !sleep(ctx, retry.MaxDelay) {
+			log.Printf("Requeueing message %s of session %s interrupted between attempts", incomingMsg.ID, incomingMsg.SessionId)
+			msg.Requeue()
+			return
+		}
+		round++
+	}
+}
+
+/*
+Função sendRound envia a mensagem, repetindo as falhas temporárias conforme a política retry.
+Retorna o erro da última tentativa, ou imediatamente um erro permanente ou de posse da sessão.
+Com o cancelamento de ctx, retorna o erro da última tentativa sem aguardar a seguinte.
+*/
+func sendRound(ctx context.Context, sendMessage SendMessage, retry core.RetryPolicy, incomingMsg *Message) error {
+	for attempt := 1; ; attempt++ {
+		err := sendMessage.Send(incomingMsg)
+		if err == nil || IsPermanentSendError(err) || isSessionOwnedElsewhere(err) || !retry.CanRetry(attempt) {
+			return err
+		}
+		if !sleep(ctx, retry.Delay(attempt)) {
+			return err
+		}
+	}
+}
+
+/*
+Função sleep aguarda o tempo informado ou o cancelamento do contexto.
+Retorna false se o contexto for cancelado antes.
+*/
+func sleep(ctx context.Context, delay time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+/*
+Função isSessionOwnedElsewhere indica se o envio falhou porque a posse da sessão pertence a outra réplica.
+*/
+func isSessionOwnedElsewhere(err error) bool {
+	return errors.Is(err, ErrSessionLeaseHeld) || errors.Is(err, ErrSessionLeaseLost)
+}
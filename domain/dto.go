@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 /*
 Estrutura ValidateRequest representa a solicitação para validar um número de telefone.
 Campos:
@@ -23,21 +25,35 @@ Estrutura SendRequest representa a solicitação para enviar uma mensagem.
 Campos:
 - JID: Identificador do remetente.
 - To: Identificador do destinatário.
-- Message: Conteúdo da mensagem a ser enviada.
+- Message: Conteúdo da mensagem a ser enviada, nas mensagens de texto.
+- Type: Tipo da mensagem (text, image, video, audio, ptt, document, sticker); vazio equivale a text.
+- Caption: Legenda das imagens, vídeos e documentos.
+- Media: Origem da mídia (url, base64 ou mediaId), com mimeType e fileName opcionais.
+- SendAt: Data de envio, em RFC 3339; vazia ou passada envia imediatamente.
 */
 type SendRequest struct {
-	SessionId string `json:"sessionId"`
-	To        string `json:"to"`
-	Message   string `json:"message"`
+	SessionId string        `json:"sessionId"`
+	To        string        `json:"to"`
+	Message   string        `json:"message"`
+	Type      string        `json:"type,omitempty"`
+	Caption   string        `json:"caption,omitempty"`
+	Media     *MediaPayload `json:"media,omitempty"`
+	SendAt    *time.Time    `json:"sendAt,omitempty"`
 }
 
 /*
 Estrutura SendResponse representa a resposta para a solicitação de envio de mensagem.
 Campos:
-- Sent: Indica se a mensagem foi enviada com sucesso.
+- Sent: Indica se a mensagem foi enfileirada com sucesso.
+- ID: Identificador da mensagem, usado em GET /messages/{id}.
+- Status: Status inicial da mensagem (queued, ou scheduled com sendAt).
+- ScheduledAt: Data de envio das mensagens agendadas.
 */
 type SendResponse struct {
-	Sent bool `json:"sent"`
+	Sent        bool    `json:"sent"`
+	ID          string  `json:"id,omitempty"`
+	Status      string  `json:"status,omitempty"`
+	ScheduledAt *string `json:"scheduledAt,omitempty"`
 }
 
 /*
@@ -54,14 +70,26 @@ type CreateAccountRequest struct {
 	Name       string `json:"name"`
 	Origin     string `json:"origin"`
 	ExternalId string `json:"externalId"`
+	WebhookURL string `json:"webhookUrl,omitempty"`
 }
 
 type CreateAccountResponse struct {
-	ID string `json:"id"`
+	ID            string `json:"id"`
+	WebhookSecret string `json:"webhookSecret"`
+}
+
+type RotateWebhookSecretResponse struct {
+	WebhookSecret           string `json:"webhookSecret"`
+	PreviousSecretExpiresAt string `json:"previousSecretExpiresAt"`
+}
+
+type ConnectPhoneRequest struct {
+	Phone string `json:"phone"`
 }
 
 type CreateSessionRequest struct {
 	AccountID string `json:"accountId"`
+	Phone     string `json:"phone,omitempty"`
 }
 
 type CreateSessionResponse struct {
@@ -87,3 +115,206 @@ type GetSessionByIDResponse struct {
 	CreatedAt     string       `json:"createdAt"`
 	UpdatedAt     string       `json:"updatedAt"`
 }
+
+type UpdateAccountRequest struct {
+	Name       string `json:"name"`
+	Origin     string `json:"origin"`
+	ExternalId string `json:"externalId"`
+	WebhookURL string `json:"webhookUrl,omitempty"`
+}
+
+type GetAccountByIDResponse struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Origin     string `json:"origin"`
+	ExternalId string `json:"externalId"`
+	WebhookURL string `json:"webhookUrl,omitempty"`
+	CreatedAt  string `json:"createdAt"`
+	UpdatedAt  string `json:"updatedAt"`
+}
+
+type ListAccountsRequest struct {
+	Origin     string
+	ExternalId string
+	Limit      int
+	Offset     int
+}
+
+type ListAccountsResponse struct {
+	Accounts []GetAccountByIDResponse `json:"accounts"`
+	Limit    int                      `json:"limit"`
+	Offset   int                      `json:"offset"`
+}
+
+type ListSessionsRequest struct {
+	AccountID string
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+type ListSessionsResponse struct {
+	Sessions []GetSessionByIDResponse `json:"sessions"`
+	Limit    int                      `json:"limit"`
+	Offset   int                      `json:"offset"`
+}
+
+/*
+Estrutura UploadMediaResponse representa a resposta ao envio de um arquivo de mídia.
+Campos:
+- ID: Identificador a ser usado em media.mediaId no envio de mensagens.
+- MimeType: Tipo MIME do arquivo.
+- FileName: Nome do arquivo.
+- Size: Tamanho do arquivo em bytes.
+*/
+type UploadMediaResponse struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mimeType"`
+	FileName string `json:"fileName"`
+	Size     int64  `json:"size"`
+}
+
+type GetMessageByIDResponse struct {
+	ID          string  `json:"id"`
+	SessionID   string  `json:"sessionId"`
+	To          string  `json:"to"`
+	Type        string  `json:"type"`
+	Status      string  `json:"status"`
+	WhatsAppID  *string `json:"whatsappId,omitempty"`
+	Error       *string `json:"error,omitempty"`
+	SentAt      *string `json:"sentAt,omitempty"`
+	DeliveredAt *string `json:"deliveredAt,omitempty"`
+	ReadAt      *string `json:"readAt,omitempty"`
+	PlayedAt    *string `json:"playedAt,omitempty"`
+	ScheduledAt *string `json:"scheduledAt,omitempty"`
+	CreatedAt   string  `json:"createdAt"`
+	UpdatedAt   string  `json:"updatedAt"`
+}
+
+type ListScheduledMessagesRequest struct {
+	SessionID string
+	Limit     int
+	Offset    int
+}
+
+type ListScheduledMessagesResponse struct {
+	Messages []GetMessageByIDResponse `json:"messages"`
+	Limit    int                      `json:"limit"`
+	Offset   int                      `json:"offset"`
+}
+
+/*
+Estrutura RescheduleMessageRequest representa a solicitação para alterar a data de envio de uma mensagem agendada.
+Campos:
+- SendAt: Nova data de envio, em RFC 3339. Este campo é obrigatório.
+*/
+type RescheduleMessageRequest struct {
+	SendAt *time.Time `json:"sendAt"`
+}
+
+/*
+Estrutura CreateCampaignRequest representa a solicitação para criar uma campanha.
+Campos:
+- SessionID: Sessão que envia as mensagens. Este campo é obrigatório.
+- Name: Nome da campanha.
+- Template: Mensagem enviada a todos os destinatários, com variáveis {{nome}}.
+- Recipients: Destinatários, com as variáveis do modelo; no envio por CSV, lidos do arquivo.
+*/
+type CreateCampaignRequest struct {
+	SessionID  string                     `json:"sessionId"`
+	Name       string                     `json:"name"`
+	Template   CampaignTemplate           `json:"template"`
+	Recipients []CampaignRecipientRequest `json:"recipients"`
+}
+
+type CampaignRecipientRequest struct {
+	To        string            `json:"to"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+/*
+Estrutura CampaignProgressResponse representa a quantidade de destinatários da campanha em cada status.
+Destinatários enfileirados seguem o status da mensagem enviada; sent equivale a server_ack.
+*/
+type CampaignProgressResponse struct {
+	Total     int `json:"total"`
+	Pending   int `json:"pending"`
+	Queued    int `json:"queued"`
+	Sent      int `json:"sent"`
+	Delivered int `json:"delivered"`
+	Read      int `json:"read"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+}
+
+type GetCampaignByIDResponse struct {
+	ID          string                   `json:"id"`
+	SessionID   string                   `json:"sessionId"`
+	Name        string                   `json:"name"`
+	Template    CampaignTemplate         `json:"template"`
+	Status      string                   `json:"status"`
+	Progress    CampaignProgressResponse `json:"progress"`
+	CompletedAt *string                  `json:"completedAt,omitempty"`
+	CreatedAt   string                   `json:"createdAt"`
+	UpdatedAt   string                   `json:"updatedAt"`
+}
+
+type ListCampaignsRequest struct {
+	SessionID string
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+type ListCampaignsResponse struct {
+	Campaigns []GetCampaignByIDResponse `json:"campaigns"`
+	Limit     int                       `json:"limit"`
+	Offset    int                       `json:"offset"`
+}
+
+type CampaignRecipientResponse struct {
+	Position  int               `json:"position"`
+	To        string            `json:"to"`
+	Variables map[string]string `json:"variables,omitempty"`
+	Status    string            `json:"status"`
+	MessageID *string           `json:"messageId,omitempty"`
+	Error     *string           `json:"error,omitempty"`
+	UpdatedAt string            `json:"updatedAt"`
+}
+
+type ListCampaignRecipientsRequest struct {
+	CampaignID string
+	Status     string
+	Limit      int
+	Offset     int
+}
+
+type ListCampaignRecipientsResponse struct {
+	Recipients []CampaignRecipientResponse `json:"recipients"`
+	Limit      int                         `json:"limit"`
+	Offset     int                         `json:"offset"`
+}
+
+type ListDeadLettersRequest struct {
+	Limit int
+}
+
+type DeadLetterResponse struct {
+	ID        string            `json:"id"`
+	Queue     string            `json:"queue"`
+	MessageID string            `json:"messageId,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      string            `json:"body"`
+	Attempt   int               `json:"attempt"`
+	Reason    string            `json:"reason"`
+	FailedAt  string            `json:"failedAt,omitempty"`
+}
+
+type ListDeadLettersResponse struct {
+	Data []DeadLetterResponse `json:"data"`
+}
+
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
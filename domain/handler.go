@@ -3,7 +3,16 @@ package domain
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"gozap/core"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
 /*
@@ -31,6 +40,28 @@ func (h WhatsAppHandler) Connect(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(res)
 }
 
+/*
+Método ConnectPhone lida com a solicitação HTTP para conectar ao serviço WhatsApp pelo número de telefone.
+Decodifica a solicitação JSON para a estrutura ConnectPhoneRequest.
+Retorna o código de pareamento de 8 caracteres a ser digitado no celular.
+*/
+func (h WhatsAppHandler) ConnectPhone(w http.ResponseWriter, r *http.Request) {
+	req := ConnectPhoneRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.WhatsAppService.ConnectPhone(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
 /*
 Método Validate lida com a solicitação HTTP para validar um número de telefone.
 Decodifica a solicitação JSON para a estrutura ValidateRequest.
@@ -62,7 +93,9 @@ Método Send lida com a solicitação HTTP para enviar uma mensagem.
 Decodifica a solicitação JSON para a estrutura SendRequest.
 Em caso de erro, retorna um status HTTP 400.
 Chama o serviço de envio de mensagem e retorna a resposta como JSON.
-Em caso de erro no serviço, retorna um status HTTP 500.
+Mensagens ou mídias inválidas retornam um status HTTP 400.
+Mensagens não confirmadas pelo servidor de mensageria, ou enviadas durante uma queda da conexão,
+retornam um status HTTP 503 e são marcadas como failed; demais erros no serviço, um status HTTP 500.
 */
 func (h WhatsAppHandler) Send(w http.ResponseWriter, r *http.Request) {
 	req := SendRequest{}
@@ -74,7 +107,7 @@ func (h WhatsAppHandler) Send(w http.ResponseWriter, r *http.Request) {
 
 	res, err := h.WhatsAppService.Send(context.Background(), req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -82,3 +115,649 @@ func (h WhatsAppHandler) Send(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(res)
 }
+
+/*
+Função writeJSON escreve a resposta como JSON com o status HTTP informado.
+*/
+func writeJSON(w http.ResponseWriter, status int, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+/*
+Função writeError converte os erros de domínio no status HTTP correspondente.
+Erros não mapeados retornam um status HTTP 500.
+*/
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrAccountNotFound), errors.Is(err, ErrSessionNotFound), errors.Is(err, ErrMediaNotFound),
+		errors.Is(err, ErrMessageNotFound), errors.Is(err, ErrCampaignNotFound), errors.Is(err, core.ErrDeadLetterNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAccountAlreadyExists), errors.Is(err, ErrAccountHasSessions),
+		errors.Is(err, ErrSessionInvalidTransition), errors.Is(err, ErrPairingNotActive), errors.Is(err, ErrAccountRotationGrace),
+		errors.Is(err, ErrMessageInvalidTransition), errors.Is(err, ErrCampaignInvalidTransition):
+		status = http.StatusConflict
+	case errors.Is(err, ErrAccountInvalid), errors.Is(err, ErrAccountWebhookURL), errors.Is(err, ErrSessionAccountRequired),
+		errors.Is(err, ErrSessionPhoneInvalid), errors.Is(err, ErrMessageInvalid), errors.Is(err, ErrMediaInvalid),
+		errors.Is(err, ErrMediaTypeNotAllowed), errors.Is(err, ErrMessageSendAtRequired), errors.Is(err, ErrCampaignInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, core.ErrDeadLetterUnsupported):
+		status = http.StatusNotImplemented
+	case errors.Is(err, core.ErrDeadLetterScanLimit):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, ErrMediaTooLarge), errors.Is(err, ErrCampaignTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrSessionPairingFailed):
+		status = http.StatusBadGateway
+	case errors.Is(err, core.ErrPublishNotConfirmed), errors.Is(err, core.ErrMessengerDisconnected),
+		errors.Is(err, ErrDeviceUnavailable):
+		status = http.StatusServiceUnavailable
+	}
+
+	http.Error(w, err.Error(), status)
+}
+
+/*
+Função queryInt lê um parâmetro numérico da query string, retornando zero quando ausente ou inválido.
+*/
+func queryInt(r *http.Request, key string) int {
+	value, _ := strconv.Atoi(r.URL.Query().Get(key))
+	return value
+}
+
+/*
+Estrutura AccountHandler que contém o serviço AccountService.
+Esta estrutura é responsável por lidar com as solicitações HTTP relacionadas às contas.
+*/
+type AccountHandler struct {
+	AccountService AccountService
+}
+
+/*
+Método Create lida com a solicitação HTTP para criar uma conta.
+Decodifica a solicitação JSON para a estrutura CreateAccountRequest.
+Retorna um status HTTP 201 com o ID da conta criada.
+*/
+func (h AccountHandler) Create(w http.ResponseWriter, r *http.Request) {
+	req := CreateAccountRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.AccountService.Create(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, res)
+}
+
+/*
+Método GetByID lida com a solicitação HTTP para obter uma conta pelo ID.
+Retorna um status HTTP 404 se a conta não existir.
+*/
+func (h AccountHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	res, err := h.AccountService.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método List lida com a solicitação HTTP para listar as contas.
+Aceita os filtros origin e externalId e a paginação limit e offset na query string.
+*/
+func (h AccountHandler) List(w http.ResponseWriter, r *http.Request) {
+	res, err := h.AccountService.List(r.Context(), ListAccountsRequest{
+		Origin:     r.URL.Query().Get("origin"),
+		ExternalId: r.URL.Query().Get("externalId"),
+		Limit:      queryInt(r, "limit"),
+		Offset:     queryInt(r, "offset"),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método Update lida com a solicitação HTTP para atualizar uma conta.
+Decodifica a solicitação JSON para a estrutura UpdateAccountRequest.
+*/
+func (h AccountHandler) Update(w http.ResponseWriter, r *http.Request) {
+	req := UpdateAccountRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.AccountService.Update(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método RotateWebhookSecret lida com a solicitação HTTP para trocar o segredo dos webhooks da conta.
+Retorna o novo segredo; o anterior continua válido até previousSecretExpiresAt.
+Retorna um status HTTP 409 se o segredo anterior de uma troca recente ainda for válido.
+*/
+func (h AccountHandler) RotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	res, err := h.AccountService.RotateWebhookSecret(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método Delete lida com a solicitação HTTP para remover uma conta.
+Retorna um status HTTP 204 em caso de sucesso.
+*/
+func (h AccountHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	err := h.AccountService.Delete(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+Estrutura SessionHandler que contém o serviço SessionService.
+Esta estrutura é responsável por lidar com as solicitações HTTP relacionadas às sessões.
+*/
+type SessionHandler struct {
+	SessionService SessionService
+}
+
+/*
+Método Create lida com a solicitação HTTP para criar uma sessão.
+Decodifica a solicitação JSON para a estrutura CreateSessionRequest.
+Quando o campo phone é informado, o pareamento é feito por código em vez de QR.
+Retorna um status HTTP 201 com o código de autenticação e o ID da sessão.
+*/
+func (h SessionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	req := CreateSessionRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.SessionService.Create(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, res)
+}
+
+/*
+Método GetByID lida com a solicitação HTTP para obter uma sessão pelo ID.
+Retorna o status atual da sessão, permitindo que a interface acompanhe o pareamento.
+*/
+func (h SessionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	res, err := h.SessionService.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método List lida com a solicitação HTTP para listar as sessões.
+Aceita os filtros accountId e status e a paginação limit e offset na query string.
+*/
+func (h SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	res, err := h.SessionService.List(r.Context(), ListSessionsRequest{
+		AccountID: r.URL.Query().Get("accountId"),
+		Status:    r.URL.Query().Get("status"),
+		Limit:     queryInt(r, "limit"),
+		Offset:    queryInt(r, "offset"),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Intervalo entre os comentários de keep-alive enviados no stream de pareamento.
+*/
+const pairingStreamKeepAlive = 15 * time.Second
+
+/*
+Método StreamPairing lida com a solicitação HTTP para acompanhar o pareamento via Server-Sent Events.
+Envia o código QR atual e cada código renovado (evento code, com o data URI e o código bruto),
+seguidos do evento final success, timeout ou error, quando a conexão é encerrada.
+Retorna um status HTTP 409 se a sessão não tiver pareamento em andamento neste processo.
+*/
+func (h SessionHandler) StreamPairing(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, cancel, err := h.SessionService.SubscribePairing(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(pairingStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case evt, ok := <-events:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(evt)
+			if err != nil {
+				return
+			}
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Event, data)
+			flusher.Flush()
+
+			if evt.IsFinal() {
+				return
+			}
+		}
+	}
+}
+
+/*
+Método Logout lida com a solicitação HTTP para desconectar uma sessão.
+Faz o logout no WhatsApp, remove o dispositivo e marca a sessão como logged_out.
+Retorna um status HTTP 204 em caso de sucesso.
+*/
+func (h SessionHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	err := h.SessionService.Logout(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+Estrutura MediaHandler que contém o serviço MediaService.
+Esta estrutura é responsável por lidar com as solicitações HTTP relacionadas aos arquivos de mídia.
+*/
+type MediaHandler struct {
+	MediaService MediaService
+}
+
+/*
+Método Upload lida com a solicitação HTTP para enviar um arquivo de mídia.
+Recebe o arquivo no campo "file" de um formulário multipart/form-data.
+Retorna um status HTTP 201 com o ID a ser usado em media.mediaId no envio de mensagens.
+*/
+func (h MediaHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxMediaUploadSize+1<<20)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, ErrMediaTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxMediaUploadSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.MediaService.Upload(r.Context(), header.Filename, header.Header.Get("Content-Type"), data)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, res)
+}
+
+/*
+Estrutura MessageHandler que contém o serviço MessageService.
+Esta estrutura é responsável por lidar com as solicitações HTTP de consulta das mensagens enviadas.
+*/
+type MessageHandler struct {
+	MessageService MessageService
+}
+
+/*
+Método GetByID lida com a solicitação HTTP para obter o status de uma mensagem pelo ID.
+Retorna um status HTTP 404 se a mensagem não existir.
+*/
+func (h MessageHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	res, err := h.MessageService.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método ListScheduled lida com a solicitação HTTP para listar as mensagens agendadas pendentes.
+Aceita os parâmetros sessionId, limit e offset na query string.
+*/
+func (h MessageHandler) ListScheduled(w http.ResponseWriter, r *http.Request) {
+	res, err := h.MessageService.ListScheduled(r.Context(), ListScheduledMessagesRequest{
+		SessionID: r.URL.Query().Get("sessionId"),
+		Limit:     queryInt(r, "limit"),
+		Offset:    queryInt(r, "offset"),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método Reschedule lida com a solicitação HTTP para alterar a data de envio de uma mensagem agendada.
+Decodifica a solicitação JSON para a estrutura RescheduleMessageRequest.
+Retorna um status HTTP 409 se a mensagem não estiver mais agendada.
+*/
+func (h MessageHandler) Reschedule(w http.ResponseWriter, r *http.Request) {
+	req := RescheduleMessageRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.MessageService.Reschedule(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método Cancel lida com a solicitação HTTP para cancelar uma mensagem agendada.
+Retorna um status HTTP 204 quando a mensagem é cancelada ou 409 se ela não estiver mais agendada.
+*/
+func (h MessageHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	err := h.MessageService.Cancel(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+Estrutura DeadLetterHandler que contém o serviço DeadLetterService.
+Esta estrutura é responsável por lidar com as solicitações HTTP da fila de mensagens mortas.
+*/
+type DeadLetterHandler struct {
+	DeadLetterService DeadLetterService
+}
+
+/*
+Método List lida com a solicitação HTTP para listar as mensagens mortas.
+Aceita o parâmetro limit na query string.
+Retorna um status HTTP 501 se o driver de mensageria não tiver suporte.
+*/
+func (h DeadLetterHandler) List(w http.ResponseWriter, r *http.Request) {
+	res, err := h.DeadLetterService.List(r.Context(), ListDeadLettersRequest{
+		Limit: queryInt(r, "limit"),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método Replay lida com a solicitação HTTP para reprocessar uma mensagem morta.
+Retorna um status HTTP 204 quando a mensagem é devolvida à fila.
+*/
+func (h DeadLetterHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	err := h.DeadLetterService.Replay(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+Estrutura CampaignHandler que contém o serviço CampaignService.
+Esta estrutura é responsável por lidar com as solicitações HTTP relacionadas às campanhas.
+*/
+type CampaignHandler struct {
+	CampaignService CampaignService
+}
+
+/*
+Método Create lida com a solicitação HTTP para criar uma campanha.
+Aceita a estrutura CreateCampaignRequest em JSON ou um formulário multipart/form-data com os campos
+sessionId, name e template (em JSON) e a lista de destinatários em CSV no arquivo "recipients".
+Retorna um status HTTP 201 com a campanha criada.
+*/
+func (h CampaignHandler) Create(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxCampaignUploadSize)
+
+	req, err := decodeCreateCampaign(r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, ErrCampaignTooLarge)
+			return
+		}
+		if errors.Is(err, ErrCampaignInvalid) || errors.Is(err, ErrCampaignTooLarge) {
+			writeError(w, err)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.CampaignService.Create(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, res)
+}
+
+/*
+Função decodeCreateCampaign lê a solicitação de criação da campanha em JSON ou multipart/form-data.
+*/
+func decodeCreateCampaign(r *http.Request) (req CreateCampaignRequest, err error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		err = json.NewDecoder(r.Body).Decode(&req)
+		return req, err
+	}
+
+	err = r.ParseMultipartForm(MaxCampaignUploadSize)
+	if err != nil {
+		return req, err
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	req.SessionID = r.FormValue("sessionId")
+	req.Name = r.FormValue("name")
+	err = json.Unmarshal([]byte(r.FormValue("template")), &req.Template)
+	if err != nil {
+		return req, fmt.Errorf("%w: template: %v", ErrCampaignInvalid, err)
+	}
+
+	file, _, err := r.FormFile("recipients")
+	if err != nil {
+		return req, fmt.Errorf("%w: recipients: %v", ErrCampaignInvalid, err)
+	}
+	defer file.Close()
+
+	req.Recipients, err = ParseCampaignRecipientsCSV(file)
+	return req, err
+}
+
+/*
+Método GetByID lida com a solicitação HTTP para obter uma campanha pelo ID, com o progresso do envio.
+*/
+func (h CampaignHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	res, err := h.CampaignService.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método List lida com a solicitação HTTP para listar as campanhas.
+Aceita os filtros sessionId e status e a paginação limit e offset na query string.
+*/
+func (h CampaignHandler) List(w http.ResponseWriter, r *http.Request) {
+	res, err := h.CampaignService.List(r.Context(), ListCampaignsRequest{
+		SessionID: r.URL.Query().Get("sessionId"),
+		Status:    r.URL.Query().Get("status"),
+		Limit:     queryInt(r, "limit"),
+		Offset:    queryInt(r, "offset"),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método ListRecipients lida com a solicitação HTTP para listar os destinatários de uma campanha.
+Aceita o filtro status e a paginação limit e offset na query string.
+*/
+func (h CampaignHandler) ListRecipients(w http.ResponseWriter, r *http.Request) {
+	res, err := h.CampaignService.ListRecipients(r.Context(), ListCampaignRecipientsRequest{
+		CampaignID: chi.URLParam(r, "id"),
+		Status:     r.URL.Query().Get("status"),
+		Limit:      queryInt(r, "limit"),
+		Offset:     queryInt(r, "offset"),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Método Pause lida com a solicitação HTTP para pausar uma campanha.
+Retorna um status HTTP 409 se a campanha não estiver em andamento.
+*/
+func (h CampaignHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	h.updateStatus(w, r, h.CampaignService.Pause)
+}
+
+/*
+Método Resume lida com a solicitação HTTP para retomar uma campanha pausada.
+Retorna um status HTTP 409 se a campanha não estiver pausada.
+*/
+func (h CampaignHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	h.updateStatus(w, r, h.CampaignService.Resume)
+}
+
+/*
+Método Cancel lida com a solicitação HTTP para cancelar uma campanha.
+Retorna um status HTTP 409 se a campanha já estiver concluída ou cancelada.
+*/
+func (h CampaignHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	h.updateStatus(w, r, h.CampaignService.Cancel)
+}
+
+/*
+Método updateStatus executa a mudança de status da campanha do parâmetro id e retorna a campanha atualizada.
+*/
+func (h CampaignHandler) updateStatus(w http.ResponseWriter, r *http.Request, update func(context.Context, string) (GetCampaignByIDResponse, error)) {
+	res, err := update(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+/*
+Estrutura HealthHandler que contém o serviço HealthService.
+Esta estrutura é responsável por lidar com as solicitações HTTP de verificação de saúde.
+*/
+type HealthHandler struct {
+	HealthService HealthService
+}
+
+/*
+Método Check lida com a solicitação HTTP de verificação de saúde.
+Retorna um status HTTP 200 se todas as dependências estiverem disponíveis ou 503 caso contrário,
+por exemplo durante a reconexão ao servidor de mensageria.
+*/
+func (h HealthHandler) Check(w http.ResponseWriter, r *http.Request) {
+	res, healthy := h.HealthService.Check(r.Context())
+	if !healthy {
+		writeJSON(w, http.StatusServiceUnavailable, res)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"strings"
 
 	"go.mau.fi/whatsmeow/types"
@@ -28,3 +30,14 @@ Retorna:
 func JIDToNumber(jid types.JID) string {
 	return jid.User
 }
+
+/*
+Função newID gera um identificador aleatório de 128 bits em hexadecimal.
+Retorna:
+- Uma string com 32 caracteres hexadecimais.
+*/
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
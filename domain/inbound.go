@@ -0,0 +1,226 @@
+package domain
+
+import (
+	"log"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+/*
+Tipos de evento enviados aos webhooks.
+*/
+const (
+	EventTypeMessage      = "message"
+	EventTypeReceipt      = "receipt"
+	EventTypePresence     = "presence"
+	EventTypeChatPresence = "chat_presence"
+)
+
+/*
+Estrutura InboundMessage representa uma mensagem recebida, normalizada para o webhook.
+*/
+type InboundMessage struct {
+	MessageID       string    `json:"messageId"`
+	Chat            string    `json:"chat"`
+	Sender          string    `json:"sender"`
+	FromMe          bool      `json:"fromMe"`
+	IsGroup         bool      `json:"isGroup"`
+	PushName        string    `json:"pushName,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+	Type            string    `json:"type"`
+	Text            string    `json:"text,omitempty"`
+	Caption         string    `json:"caption,omitempty"`
+	MimeType        string    `json:"mimeType,omitempty"`
+	FileName        string    `json:"fileName,omitempty"`
+	QuotedMessageID string    `json:"quotedMessageId,omitempty"`
+}
+
+/*
+Estrutura InboundReceipt representa uma confirmação de entrega ou leitura recebida.
+*/
+type InboundReceipt struct {
+	MessageIDs []string  `json:"messageIds"`
+	Chat       string    `json:"chat"`
+	Sender     string    `json:"sender"`
+	Type       string    `json:"type"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+/*
+Estrutura InboundPresence representa uma mudança de presença (online/offline) de um contato.
+*/
+type InboundPresence struct {
+	From      string     `json:"from"`
+	Available bool       `json:"available"`
+	LastSeen  *time.Time `json:"lastSeen,omitempty"`
+}
+
+/*
+Estrutura InboundChatPresence representa uma notificação de digitação ou gravação em uma conversa.
+*/
+type InboundChatPresence struct {
+	Chat   string `json:"chat"`
+	Sender string `json:"sender"`
+	State  string `json:"state"`
+	Media  string `json:"media,omitempty"`
+}
+
+/*
+Estrutura InboundHandler converte os eventos recebidos pelos clientes do pool em EventEnvelope
+e os envia ao WebhookDispatcher.
+*/
+type InboundHandler struct {
+	Webhooks *WebhookDispatcher
+}
+
+/*
+Método HandleEvent normaliza mensagens, confirmações e presenças e as enfileira para os webhooks.
+Os demais eventos são ignorados.
+Parâmetros:
+- sessionID: Identificador da sessão.
+- client: Cliente whatsmeow que recebeu o evento.
+- evt: Evento recebido.
+*/
+func (h InboundHandler) HandleEvent(sessionID string, client *whatsmeow.Client, evt interface{}) {
+	var (
+		eventType string
+		data      interface{}
+	)
+
+	switch evt := evt.(type) {
+	case *events.Message:
+		if evt.Info.Chat == types.StatusBroadcastJID {
+			return
+		}
+		eventType = EventTypeMessage
+		data = normalizeMessage(evt)
+	case *events.Receipt:
+		eventType = EventTypeReceipt
+		data = normalizeReceipt(evt)
+	case *events.Presence:
+		eventType = EventTypePresence
+		presence := InboundPresence{
+			From:      evt.From.String(),
+			Available: !evt.Unavailable,
+		}
+		if !evt.LastSeen.IsZero() {
+			presence.LastSeen = &evt.LastSeen
+		}
+		data = presence
+	case *events.ChatPresence:
+		eventType = EventTypeChatPresence
+		data = InboundChatPresence{
+			Chat:   evt.Chat.String(),
+			Sender: evt.Sender.String(),
+			State:  string(evt.State),
+			Media:  string(evt.Media),
+		}
+	default:
+		return
+	}
+
+	err := h.Webhooks.Dispatch(EventEnvelope{
+		ID:        newID(),
+		Type:      eventType,
+		SessionID: sessionID,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("Error dispatching %s event for session %s: %v", eventType, sessionID, err)
+	}
+}
+
+/*
+Função normalizeMessage extrai o tipo, o texto e os metadados de mídia de uma mensagem recebida.
+*/
+func normalizeMessage(evt *events.Message) InboundMessage {
+	res := InboundMessage{
+		MessageID: evt.Info.ID,
+		Chat:      evt.Info.Chat.String(),
+		Sender:    evt.Info.Sender.String(),
+		FromMe:    evt.Info.IsFromMe,
+		IsGroup:   evt.Info.IsGroup,
+		PushName:  evt.Info.PushName,
+		Timestamp: evt.Info.Timestamp,
+		Type:      "unknown",
+	}
+
+	msg := evt.Message
+	var contextInfo *waProto.ContextInfo
+
+	switch {
+	case msg.GetConversation() != "":
+		res.Type = "text"
+		res.Text = msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		res.Type = "text"
+		res.Text = msg.GetExtendedTextMessage().GetText()
+		contextInfo = msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.GetImageMessage() != nil:
+		res.Type = "image"
+		res.Caption = msg.GetImageMessage().GetCaption()
+		res.MimeType = msg.GetImageMessage().GetMimetype()
+		contextInfo = msg.GetImageMessage().GetContextInfo()
+	case msg.GetVideoMessage() != nil:
+		res.Type = "video"
+		res.Caption = msg.GetVideoMessage().GetCaption()
+		res.MimeType = msg.GetVideoMessage().GetMimetype()
+		contextInfo = msg.GetVideoMessage().GetContextInfo()
+	case msg.GetAudioMessage() != nil:
+		res.Type = "audio"
+		if msg.GetAudioMessage().GetPTT() {
+			res.Type = "ptt"
+		}
+		res.MimeType = msg.GetAudioMessage().GetMimetype()
+		contextInfo = msg.GetAudioMessage().GetContextInfo()
+	case msg.GetDocumentMessage() != nil:
+		res.Type = "document"
+		res.Caption = msg.GetDocumentMessage().GetCaption()
+		res.MimeType = msg.GetDocumentMessage().GetMimetype()
+		res.FileName = msg.GetDocumentMessage().GetFileName()
+		contextInfo = msg.GetDocumentMessage().GetContextInfo()
+	case msg.GetStickerMessage() != nil:
+		res.Type = "sticker"
+		res.MimeType = msg.GetStickerMessage().GetMimetype()
+		contextInfo = msg.GetStickerMessage().GetContextInfo()
+	case msg.GetLocationMessage() != nil:
+		res.Type = "location"
+		res.Text = msg.GetLocationMessage().GetName()
+	case msg.GetContactMessage() != nil:
+		res.Type = "contact"
+		res.Text = msg.GetContactMessage().GetVcard()
+	case msg.GetReactionMessage() != nil:
+		res.Type = "reaction"
+		res.Text = msg.GetReactionMessage().GetText()
+		res.QuotedMessageID = msg.GetReactionMessage().GetKey().GetID()
+	}
+
+	if contextInfo != nil {
+		res.QuotedMessageID = contextInfo.GetStanzaID()
+	}
+
+	return res
+}
+
+/*
+Função normalizeReceipt converte uma confirmação do whatsmeow, usando "delivered" para o tipo vazio.
+*/
+func normalizeReceipt(evt *events.Receipt) InboundReceipt {
+	receiptType := string(evt.Type)
+	if evt.Type == types.ReceiptTypeDelivered {
+		receiptType = "delivered"
+	}
+
+	return InboundReceipt{
+		MessageIDs: evt.MessageIDs,
+		Chat:       evt.Chat.String(),
+		Sender:     evt.Sender.String(),
+		Type:       receiptType,
+		Timestamp:  evt.Timestamp,
+	}
+}
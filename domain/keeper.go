@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+/*
+Quantidade máxima de sessões conectadas ao mesmo tempo durante uma sincronização.
+*/
+const sessionKeeperConcurrency = 8
+
+/*
+Estrutura SessionKeeper mantém conectadas no pool todas as sessões prontas,
+para que as mensagens, confirmações e presenças recebidas cheguem aos handlers do pool.
+As sessões são sincronizadas com o banco de dados a cada Interval, incluindo as recém-pareadas.
+Com Sharding, cada réplica mantém apenas as sessões das suas partições.
+Com várias réplicas, cada sessão é mantida pela réplica que adquirir sua posse; as demais a ignoram.
+*/
+type SessionKeeper struct {
+	ClientPool        *ClientPool
+	SessionRepository SessionRepository
+	Interval          time.Duration
+	Sharding          *SessionSharding
+}
+
+/*
+Método Run sincroniza as sessões imediatamente e depois a cada Interval, até o contexto ser cancelado.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento.
+*/
+func (k SessionKeeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(k.Interval)
+	defer ticker.Stop()
+
+	for {
+		k.sync(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Método sync conecta as sessões prontas que ainda não estão sendo mantidas pelo pool.
+*/
+func (k SessionKeeper) sync(ctx context.Context) {
+	ids, err := k.SessionRepository.ListSessionIDsByStatus(ctx, SessionStatusReady)
+	if err != nil {
+		log.Printf("Error listing ready sessions: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, sessionKeeperConcurrency)
+
+	for _, id := range ids {
+		if !k.Sharding.Owns(id) || k.ClientPool.IsKept(id) {
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			err := k.ClientPool.Keep(ctx, id)
+			if err != nil && !errors.Is(err, ErrSessionLeaseHeld) {
+				log.Printf("Error keeping session %s online: %v", id, err)
+			}
+		}(id)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"gozap/core"
+	"log"
+	"sync"
+	"time"
+)
+
+/*
+Definição de variáveis de erro específicas para a posse distribuída das sessões.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrSessionLeaseHeld = errors.New("session.lease_held: session is owned by another consumer")
+	ErrSessionLeaseLost = errors.New("session.lease_lost: session lease expired or was taken by another consumer")
+)
+
+const sessionLeaseKeyPrefix = "gozap:session-lease:"
+
+/*
+Estrutura SessionLeases concede a posse exclusiva das sessões entre as réplicas do consumer.
+Apenas a réplica com a posse da sessão pode conectar o aparelho e enviar mensagens por ele.
+A posse é um bloqueio no Redis com token, renovado a cada TTL/3 enquanto o cliente estiver no pool.
+*/
+type SessionLeases struct {
+	Redis *core.RedisClient
+	TTL   time.Duration
+}
+
+/*
+Estrutura SessionLease representa a posse de uma sessão por esta réplica.
+validUntil é o prazo da última renovação bem-sucedida; após ele, a posse é considerada perdida
+mesmo que o Redis esteja inacessível, pois outra réplica pode tê-la adquirido.
+*/
+type SessionLease struct {
+	sessionID string
+	token     string
+	leases    *SessionLeases
+
+	mu         sync.Mutex
+	validUntil time.Time
+	lost       chan struct{}
+	stop       chan struct{}
+	once       sync.Once
+}
+
+/*
+Método Acquire adquire a posse da sessão e inicia sua renovação.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- sessionID: Identificador da sessão.
+Retorna:
+- A posse da sessão e ErrSessionLeaseHeld se ela pertencer a outra réplica, ou outro erro, se houver.
+*/
+func (l *SessionLeases) Acquire(ctx context.Context, sessionID string) (*SessionLease, error) {
+	acquiredAt := time.Now()
+
+	token, err := l.Redis.AcquireLock(sessionLeaseKeyPrefix+sessionID, l.TTL)
+	if errors.Is(err, core.ErrRedisLockHeld) {
+		return nil, ErrSessionLeaseHeld
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lease := &SessionLease{
+		sessionID:  sessionID,
+		token:      token,
+		leases:     l,
+		validUntil: acquiredAt.Add(l.TTL),
+		lost:       make(chan struct{}),
+		stop:       make(chan struct{}),
+	}
+	go lease.renew()
+
+	return lease, nil
+}
+
+/*
+Método renew renova a posse a cada TTL/3 até ela ser liberada ou perdida.
+Falhas temporárias do Redis são repetidas enquanto o prazo da posse não expirar.
+*/
+func (l *SessionLease) renew() {
+	ticker := time.NewTicker(l.leases.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		renewedAt := time.Now()
+		err := l.leases.Redis.RefreshLock(sessionLeaseKeyPrefix+l.sessionID, l.token, l.leases.TTL)
+		switch {
+		case err == nil:
+			l.mu.Lock()
+			l.validUntil = renewedAt.Add(l.leases.TTL)
+			l.mu.Unlock()
+		case errors.Is(err, core.ErrRedisLockLost):
+			l.markLost()
+			return
+		case !l.valid():
+			log.Printf("Error renewing lease of session %s: %v", l.sessionID, err)
+			l.markLost()
+			return
+		default:
+			log.Printf("Error renewing lease of session %s, retrying: %v", l.sessionID, err)
+		}
+	}
+}
+
+/*
+Método valid indica se o prazo da última renovação ainda não expirou.
+*/
+func (l *SessionLease) valid() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Now().Before(l.validUntil)
+}
+
+/*
+Método markLost sinaliza a perda da posse e encerra a renovação.
+O canal lost é fechado antes de stop, para que quem aguarda stop já encontre lost fechado.
+*/
+func (l *SessionLease) markLost() {
+	l.once.Do(func() {
+		log.Printf("Lost lease of session %s", l.sessionID)
+		close(l.lost)
+		close(l.stop)
+	})
+}
+
+/*
+Método Lost retorna um canal fechado quando a posse da sessão é perdida.
+*/
+func (l *SessionLease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+/*
+Método Check verifica se esta réplica ainda tem a posse da sessão.
+Deve ser chamado imediatamente antes de cada envio, para que uma réplica que perdeu a posse pare de enviar.
+Retorna:
+- ErrSessionLeaseLost se a posse tiver expirado ou pertencer a outra réplica, ou outro erro, se houver.
+*/
+func (l *SessionLease) Check(ctx context.Context) error {
+	select {
+	case <-l.lost:
+		return ErrSessionLeaseLost
+	default:
+	}
+
+	if !l.valid() {
+		l.markLost()
+		return ErrSessionLeaseLost
+	}
+
+	err := l.leases.Redis.CheckLock(sessionLeaseKeyPrefix+l.sessionID, l.token)
+	if errors.Is(err, core.ErrRedisLockLost) {
+		l.markLost()
+		return ErrSessionLeaseLost
+	}
+
+	return err
+}
+
+/*
+Método Release encerra a renovação e libera a posse da sessão, se ela ainda pertencer a esta réplica.
+*/
+func (l *SessionLease) Release() {
+	l.once.Do(func() {
+		close(l.stop)
+		err := l.leases.Redis.ReleaseLock(sessionLeaseKeyPrefix+l.sessionID, l.token)
+		if err != nil && !errors.Is(err, core.ErrRedisLockLost) {
+			log.Printf("Error releasing lease of session %s: %v", l.sessionID, err)
+		}
+	})
+}
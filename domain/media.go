@@ -0,0 +1,495 @@
+package domain
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+/*
+Definição de variáveis de erro específicas para as mensagens e mídias.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrMessageInvalid      = errors.New("message.invalid: sessionId, to and a valid type are required")
+	ErrMediaInvalid        = errors.New("media.invalid: exactly one of url, base64 or mediaId is required")
+	ErrMediaNotFound       = errors.New("media.not_found: media not found")
+	ErrMediaTooLarge       = errors.New("media.too_large: media exceeds the size limit for its type")
+	ErrMediaTypeNotAllowed = errors.New("media.type_not_allowed: mime type is not allowed for the message type")
+	ErrMediaDownload       = errors.New("media.download_failed: could not download media from url")
+	ErrMediaURLNotAllowed  = errors.New("media.url_not_allowed: media url must resolve to a public address")
+)
+
+/*
+Tipos de mensagem aceitos no envio.
+*/
+const (
+	MessageTypeText     = "text"
+	MessageTypeImage    = "image"
+	MessageTypeVideo    = "video"
+	MessageTypeAudio    = "audio"
+	MessageTypePTT      = "ptt"
+	MessageTypeDocument = "document"
+	MessageTypeSticker  = "sticker"
+)
+
+const (
+	mediaDownloadTimeout = time.Minute
+	MaxMediaUploadSize   = 100 << 20
+)
+
+/*
+Estrutura mediaKind descreve as regras de cada tipo de mídia.
+Campos:
+- appInfo: Tipo de mídia usado pelo whatsmeow na criptografia do upload.
+- maxSize: Tamanho máximo aceito pelo WhatsApp, em bytes.
+- mimeTypes: Tipos MIME aceitos; vazio aceita qualquer tipo.
+- caption: Indica se o tipo aceita legenda.
+*/
+type mediaKind struct {
+	appInfo   whatsmeow.MediaType
+	maxSize   int
+	mimeTypes []string
+	caption   bool
+}
+
+var mediaKinds = map[string]mediaKind{
+	MessageTypeImage: {
+		appInfo:   whatsmeow.MediaImage,
+		maxSize:   5 << 20,
+		mimeTypes: []string{"image/jpeg", "image/png"},
+		caption:   true,
+	},
+	MessageTypeVideo: {
+		appInfo:   whatsmeow.MediaVideo,
+		maxSize:   16 << 20,
+		mimeTypes: []string{"video/mp4", "video/3gpp"},
+		caption:   true,
+	},
+	MessageTypeAudio: {
+		appInfo:   whatsmeow.MediaAudio,
+		maxSize:   16 << 20,
+		mimeTypes: []string{"audio/aac", "audio/mp4", "audio/mpeg", "audio/amr", "audio/ogg"},
+	},
+	MessageTypePTT: {
+		appInfo:   whatsmeow.MediaAudio,
+		maxSize:   16 << 20,
+		mimeTypes: []string{"audio/ogg"},
+	},
+	MessageTypeDocument: {
+		appInfo: whatsmeow.MediaDocument,
+		maxSize: MaxMediaUploadSize,
+		caption: true,
+	},
+	MessageTypeSticker: {
+		appInfo:   whatsmeow.MediaImage,
+		maxSize:   500 << 10,
+		mimeTypes: []string{"image/webp"},
+	},
+}
+
+/*
+Método check valida o tipo MIME e o tamanho de uma mídia.
+Parâmetros:
+- mimeType: Tipo MIME da mídia.
+- size: Tamanho da mídia em bytes.
+Retorna:
+- ErrMediaTypeNotAllowed ou ErrMediaTooLarge, se a mídia não puder ser enviada.
+*/
+func (k mediaKind) check(mimeType string, size int) error {
+	if size > k.maxSize {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrMediaTooLarge, size, k.maxSize)
+	}
+	if len(k.mimeTypes) == 0 {
+		return nil
+	}
+
+	base := baseMimeType(mimeType)
+	for _, allowed := range k.mimeTypes {
+		if base == allowed {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrMediaTypeNotAllowed, mimeType)
+}
+
+/*
+Estrutura Media representa um arquivo enviado previamente pela API e guardado no banco de dados.
+Campos:
+- ID: Identificador da mídia, usado como mediaId no envio.
+- MimeType: Tipo MIME do arquivo.
+- FileName: Nome original do arquivo.
+- Size: Tamanho do arquivo em bytes.
+- Data: Conteúdo do arquivo.
+- CreatedAt: Data de criação.
+- ExpiresAt: Data a partir da qual a mídia é removida pelo MediaPurger;
+nula nas mídias enviadas em /media, que não expiram.
+*/
+type Media struct {
+	ID        string
+	MimeType  string
+	FileName  string
+	Size      int64
+	Data      []byte
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+}
+
+/*
+Estrutura MediaPayload representa a origem da mídia de uma mensagem.
+Apenas um entre URL, Base64 e MediaID deve ser informado.
+Campos:
+- URL: Endereço http(s) de onde a mídia é baixada no envio.
+- Base64: Conteúdo da mídia em base64; guardado pela API na tabela de mídias e enfileirado como MediaID.
+- MediaID: Identificador de uma mídia enviada em POST /media.
+- MimeType: Tipo MIME da mídia; detectado pelo conteúdo quando vazio.
+- FileName: Nome do arquivo, exibido nos documentos.
+*/
+type MediaPayload struct {
+	URL      string `json:"url,omitempty"`
+	Base64   string `json:"base64,omitempty"`
+	MediaID  string `json:"mediaId,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	FileName string `json:"fileName,omitempty"`
+}
+
+/*
+Método validate verifica a origem da mídia e, para base64, o tipo e o tamanho do conteúdo.
+Mídias por URL ou MediaID são verificadas no envio, quando o conteúdo é carregado.
+Parâmetros:
+- kind: Regras do tipo de mensagem.
+*/
+func (p *MediaPayload) validate(kind mediaKind) error {
+	sources := 0
+	for _, source := range []string{p.URL, p.Base64, p.MediaID} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return ErrMediaInvalid
+	}
+
+	if p.URL != "" {
+		u, err := url.Parse(p.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: url must be an absolute http(s) url", ErrMediaInvalid)
+		}
+	}
+
+	if p.Base64 != "" {
+		data, err := decodeBase64(p.Base64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid base64", ErrMediaInvalid)
+		}
+		return kind.check(detectMimeType(p.MimeType, data), len(data))
+	}
+
+	if p.MimeType != "" {
+		return kind.check(p.MimeType, 0)
+	}
+
+	return nil
+}
+
+/*
+Função validateMessage valida o tipo, o destinatário, o texto e a mídia de uma mensagem.
+Parâmetros:
+- message: Mensagem a ser validada.
+*/
+func validateMessage(message *Message) error {
+	if message.SessionId == "" || message.To == "" {
+		return ErrMessageInvalid
+	}
+
+	switch message.Type {
+	case "", MessageTypeText:
+		if message.Message == "" {
+			return fmt.Errorf("%w: message is required for text messages", ErrMessageInvalid)
+		}
+		return nil
+	}
+
+	kind, ok := mediaKinds[message.Type]
+	if !ok {
+		return fmt.Errorf("%w: unknown type %q", ErrMessageInvalid, message.Type)
+	}
+	if message.Media == nil {
+		return ErrMediaInvalid
+	}
+	if message.Caption != "" && !kind.caption {
+		return fmt.Errorf("%w: %s messages do not accept a caption", ErrMessageInvalid, message.Type)
+	}
+
+	return message.Media.validate(kind)
+}
+
+/*
+Função decodeBase64 decodifica o conteúdo em base64, aceitando também data URIs.
+*/
+func decodeBase64(value string) ([]byte, error) {
+	if strings.HasPrefix(value, "data:") {
+		if _, data, ok := strings.Cut(value, ","); ok {
+			value = data
+		}
+	}
+	return base64.StdEncoding.DecodeString(value)
+}
+
+/*
+Função detectMimeType retorna o tipo MIME informado ou, se vazio, o detectado pelo conteúdo.
+*/
+func detectMimeType(mimeType string, data []byte) string {
+	if mimeType != "" {
+		return mimeType
+	}
+
+	detected := http.DetectContentType(data)
+	if baseMimeType(detected) == "application/ogg" {
+		return "audio/ogg"
+	}
+	return detected
+}
+
+/*
+Função baseMimeType remove os parâmetros do tipo MIME, por exemplo "audio/ogg; codecs=opus".
+*/
+func baseMimeType(mimeType string) string {
+	base, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	return base
+}
+
+/*
+Estrutura MediaLoader carrega o conteúdo das mídias das mensagens a partir da URL,
+do base64 ou do banco de dados.
+HTTPClient substitui o cliente do download; ao contrário do padrão, ele não é restrito a endereços públicos.
+*/
+type MediaLoader struct {
+	MediaRepository MediaRepository
+	HTTPClient      *http.Client
+}
+
+/*
+Método Load carrega o conteúdo da mídia e valida seu tipo e tamanho.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- messageType: Tipo da mensagem.
+- payload: Origem da mídia.
+Retorna:
+- O conteúdo, o tipo MIME e o nome do arquivo e um erro, se houver.
+*/
+func (l MediaLoader) Load(ctx context.Context, messageType string, payload *MediaPayload) (data []byte, mimeType string, fileName string, err error) {
+	kind, ok := mediaKinds[messageType]
+	if !ok || payload == nil {
+		return nil, "", "", ErrMediaInvalid
+	}
+
+	mimeType = payload.MimeType
+	fileName = payload.FileName
+
+	switch {
+	case payload.Base64 != "":
+		data, err = decodeBase64(payload.Base64)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("%w: invalid base64", ErrMediaInvalid)
+		}
+	case payload.MediaID != "":
+		media, err := l.MediaRepository.FindMediaByID(ctx, payload.MediaID)
+		if err != nil {
+			return nil, "", "", err
+		}
+		data = media.Data
+		if mimeType == "" {
+			mimeType = media.MimeType
+		}
+		if fileName == "" {
+			fileName = media.FileName
+		}
+	case payload.URL != "":
+		data, mimeType, err = l.download(ctx, payload.URL, mimeType, kind.maxSize)
+		if err != nil {
+			return nil, "", "", err
+		}
+	default:
+		return nil, "", "", ErrMediaInvalid
+	}
+
+	mimeType = detectMimeType(mimeType, data)
+	if err = kind.check(mimeType, len(data)); err != nil {
+		return nil, "", "", err
+	}
+
+	return data, mimeType, fileName, nil
+}
+
+/*
+Cliente HTTP padrão do download das mídias, que só se conecta a endereços públicos.
+A verificação é feita na conexão, depois da resolução do nome e a cada redirecionamento,
+para que a URL informada não alcance a rede interna do consumer.
+*/
+var mediaHTTPClient = &http.Client{
+	Timeout: mediaDownloadTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+			Control: publicAddressControl,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+/*
+Faixas de endereços não públicos que não são cobertas pelos métodos de netip.Addr.
+*/
+var mediaBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+/*
+Função publicAddressControl recusa conexões a endereços privados, de loopback, link-local, multicast ou reservados.
+*/
+func publicAddressControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("%w: %s", ErrMediaURLNotAllowed, ip)
+	}
+	for _, prefix := range mediaBlockedPrefixes {
+		if prefix.Contains(ip) {
+			return fmt.Errorf("%w: %s", ErrMediaURLNotAllowed, ip)
+		}
+	}
+
+	return nil
+}
+
+/*
+Método download baixa a mídia da URL, lendo no máximo maxSize+1 bytes.
+O tipo MIME informado tem precedência sobre o Content-Type da resposta.
+Sem HTTPClient, usa mediaHTTPClient, restrito a endereços públicos.
+Um HTTPClient informado é usado como está e não passa pela verificação de publicAddressControl:
+cabe a quem o informa restringir os endereços, por exemplo com um Dialer cujo Control seja publicAddressControl.
+*/
+func (l MediaLoader) download(ctx context.Context, rawURL string, mimeType string, maxSize int) ([]byte, string, error) {
+	httpClient := l.HTTPClient
+	if httpClient == nil {
+		httpClient = mediaHTTPClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrMediaDownload, err)
+	}
+
+	res, err := httpClient.Do(req)
+	if errors.Is(err, ErrMediaURLNotAllowed) {
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrMediaDownload, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("%w: status %d", ErrMediaDownload, res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrMediaDownload, err)
+	}
+
+	if mimeType == "" && baseMimeType(res.Header.Get("Content-Type")) != "application/octet-stream" {
+		mimeType = res.Header.Get("Content-Type")
+	}
+
+	return data, mimeType, nil
+}
+
+/*
+Quantidade máxima de mídias removidas por instrução pelo MediaPurger.
+*/
+const mediaPurgeBatch = 100
+
+/*
+Estrutura MediaPurger remove as mídias expiradas, como as guardadas a partir do base64 das mensagens.
+As réplicas da API podem remover ao mesmo tempo; cada mídia é removida uma única vez.
+*/
+type MediaPurger struct {
+	MediaRepository MediaRepository
+	Interval        time.Duration
+}
+
+/*
+Método Run remove as mídias expiradas imediatamente e depois a cada Interval, até o contexto ser cancelado.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento.
+*/
+func (p MediaPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Método purge remove as mídias expiradas, em lotes de mediaPurgeBatch.
+*/
+func (p MediaPurger) purge(ctx context.Context) {
+	for ctx.Err() == nil {
+		removed, err := p.MediaRepository.DeleteExpiredMedia(ctx, mediaPurgeBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error removing expired media: %v", err)
+			}
+			return
+		}
+		if removed > 0 {
+			log.Printf("Removed %d expired media", removed)
+		}
+		if removed < mediaPurgeBatch {
+			return
+		}
+	}
+}
@@ -3,25 +3,182 @@ package domain
 import (
 	"context"
 	"errors"
+	"gozap/core"
+	"log"
 	"strings"
+	"time"
 
 	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
 	"google.golang.org/protobuf/proto"
 )
 
+/*
+Definição de variáveis de erro específicas para o acompanhamento das mensagens enviadas.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrMessageNotFound          = errors.New("message.not_found: message not found")
+	ErrMessageInvalidTransition = errors.New("message.invalid_transition: message status does not allow this change")
+	ErrMessageSendAtRequired    = errors.New("message.send_at_required: sendAt is required")
+)
+
+/*
+Status possíveis de uma mensagem enviada.
+O ciclo de vida segue: queued → server_ack → delivered → read → played, ou failed.
+Mensagens com sendAt começam como scheduled e passam a queued quando são consumidas na data agendada,
+ou a cancelled, se canceladas antes.
+*/
+const (
+	MessageStatusScheduled = "scheduled"
+	MessageStatusCancelled = "cancelled"
+	MessageStatusQueued    = "queued"
+	MessageStatusServerAck = "server_ack"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+	MessageStatusPlayed    = "played"
+	MessageStatusFailed    = "failed"
+)
+
+/*
+Cabeçalhos publicados no envelope das mensagens enfileiradas pela API.
+Session-Id é o cabeçalho de ordem dos drivers, para que as mensagens da sessão sejam entregues em ordem.
+Scheduled-At identifica o agendamento publicado, para que cópias de agendamentos alterados sejam descartadas.
+*/
+const (
+	MessageHeaderSessionID   = core.HeaderOrderingKey
+	MessageHeaderScheduledAt = "Scheduled-At"
+)
+
+/*
+Mapa messageTransitions define, para cada status de destino, os status de origem permitidos.
+Confirmações atrasadas ou fora de ordem nunca fazem o status retroceder.
+Uma mensagem failed pode voltar a server_ack quando é reprocessada a partir da fila de mensagens mortas.
+*/
+var messageTransitions = map[string][]string{
+	MessageStatusServerAck: {MessageStatusQueued, MessageStatusFailed},
+	MessageStatusDelivered: {MessageStatusQueued, MessageStatusServerAck},
+	MessageStatusRead:      {MessageStatusQueued, MessageStatusServerAck, MessageStatusDelivered},
+	MessageStatusPlayed:    {MessageStatusQueued, MessageStatusServerAck, MessageStatusDelivered, MessageStatusRead},
+	MessageStatusFailed:    {MessageStatusQueued, MessageStatusServerAck},
+	MessageStatusCancelled: {MessageStatusScheduled},
+}
+
+/*
+Estrutura MessageRecord representa o registro persistido de uma mensagem enviada pela API.
+O ID é gerado na API; WhatsAppID é o ID da mensagem no WhatsApp, usado para associar as confirmações.
+ScheduledAt é a data de envio das mensagens agendadas.
+*/
+type MessageRecord struct {
+	ID          string
+	SessionID   string
+	To          string
+	Type        string
+	Status      string
+	WhatsAppID  *string
+	Error       *string
+	SentAt      *time.Time
+	DeliveredAt *time.Time
+	ReadAt      *time.Time
+	PlayedAt    *time.Time
+	ScheduledAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+/*
+Método ToResponse converte o registro da mensagem na estrutura de resposta da API.
+*/
+func (m *MessageRecord) ToResponse() GetMessageByIDResponse {
+	formatTime := func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		formatted := t.Format(time.RFC3339)
+		return &formatted
+	}
+
+	return GetMessageByIDResponse{
+		ID:          m.ID,
+		SessionID:   m.SessionID,
+		To:          m.To,
+		Type:        m.Type,
+		Status:      m.Status,
+		WhatsAppID:  m.WhatsAppID,
+		Error:       m.Error,
+		SentAt:      formatTime(m.SentAt),
+		DeliveredAt: formatTime(m.DeliveredAt),
+		ReadAt:      formatTime(m.ReadAt),
+		PlayedAt:    formatTime(m.PlayedAt),
+		ScheduledAt: formatTime(m.ScheduledAt),
+		CreatedAt:   m.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   m.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+/*
+Estrutura ScheduledMessage representa uma mensagem agendada, com o conteúdo a ser publicado na data de envio.
+Campos:
+- ID: Identificador da mensagem.
+- SessionID: Identificador da sessão que envia a mensagem.
+- Queue: Fila em que a mensagem é publicada.
+- Body: Mensagem serializada, no formato consumido pelo consumer.
+- SendAt: Data de envio.
+*/
+type ScheduledMessage struct {
+	ID        string
+	SessionID string
+	Queue     string
+	Body      []byte
+	SendAt    time.Time
+}
+
+/*
+Método Envelope monta o envelope publicado na data de envio, identificado pelo cabeçalho Scheduled-At.
+*/
+func (m ScheduledMessage) Envelope() core.Envelope {
+	envelope := messageEnvelope(m.ID, m.SessionID, m.Body)
+	envelope.Headers[MessageHeaderScheduledAt] = m.SendAt.UTC().Format(time.RFC3339Nano)
+	return envelope
+}
+
+/*
+Função messageEnvelope monta o envelope de uma mensagem enfileirada pela API.
+O envelope usa o mesmo ID da mensagem registrada, para que o rastreamento no servidor de mensageria
+corresponda ao status consultado em GET /messages/{id}.
+*/
+func messageEnvelope(id string, sessionID string, body []byte) core.Envelope {
+	return core.Envelope{
+		ID:          id,
+		ContentType: "application/json",
+		Headers: map[string]string{
+			MessageHeaderSessionID: sessionID,
+		},
+		Body: body,
+	}
+}
+
 /*
 Estrutura Message representa uma mensagem a ser enviada.
 Campos:
+- ID: Identificador gerado pela API, usado para acompanhar o status da mensagem.
 - JID: Identificador do remetente.
 - To: Identificador do destinatário.
 - Message: Conteúdo da mensagem a ser enviada.
+- Type: Tipo da mensagem (text, image, video, audio, ptt, document, sticker); vazio equivale a text.
+- Caption: Legenda das imagens, vídeos e documentos.
+- Media: Origem da mídia, nos tipos diferentes de text.
 */
 type Message struct {
-	SessionId string `json:"sessionId"`
-	To        string `json:"to"`
-	Message   string `json:"message"`
+	ID        string        `json:"id,omitempty"`
+	SessionId string        `json:"sessionId"`
+	To        string        `json:"to"`
+	Message   string        `json:"message"`
+	Type      string        `json:"type,omitempty"`
+	Caption   string        `json:"caption,omitempty"`
+	Media     *MediaPayload `json:"media,omitempty"`
 }
 
 /*
@@ -34,11 +191,13 @@ func (m *Message) GetMessage() string {
 }
 
 /*
-Estrutura SendMessage contém o repositório WhatsAppRepository.
+Estrutura SendMessage contém o pool de clientes WhatsApp.
 Esta estrutura é responsável por enviar mensagens usando o serviço WhatsApp.
 */
 type SendMessage struct {
-	WhatsAppRepository WhatsAppRepository
+	ClientPool        *ClientPool
+	MediaLoader       MediaLoader
+	MessageRepository MessageRepository
 }
 
 /*
@@ -51,30 +210,14 @@ Retorna:
 func (s *SendMessage) Send(message *Message) (err error) {
 
 	/*
-	   Encontra o dispositivo WhatsApp associado ao JID.
-	   Se o dispositivo não for encontrado, retorna um erro.
+	   Obtém do pool o cliente WhatsApp da sessão, já conectado e autenticado.
+	   O cliente é reaproveitado entre as mensagens e não é desconectado após o envio.
 	*/
-	deviceStore, err := s.WhatsAppRepository.FindDeviceWM(context.Background(), message.SessionId)
+	client, err := s.ClientPool.Get(context.Background(), message.SessionId)
 	if err != nil {
-		return errors.New("device_not_found")
-	}
-
-	/*
-	   Cria um novo cliente WhatsApp usando o dispositivo encontrado.
-	   Conecta ao cliente WhatsApp.
-	   Se a conexão falhar, retorna um erro.
-	*/
-	client := whatsmeow.NewClient(deviceStore, nil)
-	if err = client.Connect(); err != nil {
 		return err
 	}
 
-	defer client.Disconnect()
-
-	if !client.IsConnected() {
-		return errors.New("client is not connected")
-	}
-
 	message.To = strings.Replace(message.To, "+", "", -1)
 
 	/*
@@ -85,20 +228,286 @@ func (s *SendMessage) Send(message *Message) (err error) {
 		User:   message.To,
 	}
 
+	content, err := s.build(context.Background(), client, message)
+	if err != nil {
+		return err
+	}
+
+	/*
+	   Gera o ID da mensagem no WhatsApp e o registra antes do envio,
+	   para que as confirmações recebidas logo após o envio encontrem a mensagem.
+	   Uma nova tentativa reutiliza o ID registrado na primeira, pois o envio anterior pode ter chegado ao WhatsApp
+	   mesmo retornando erro; assim a cópia não tem um novo ID e as confirmações da primeira continuam associadas.
+	*/
+	whatsAppID := client.GenerateMessageID()
+	if message.ID != "" {
+		whatsAppID, err = s.MessageRepository.AssignMessageWhatsAppID(context.Background(), message.ID, whatsAppID)
+		if err != nil {
+			return err
+		}
+	}
+
+	/*
+	   Confirma que esta réplica ainda tem a posse da sessão imediatamente antes do envio.
+	   Uma réplica que perdeu a posse não envia, evitando dois consumers usando o mesmo aparelho.
+	*/
+	err = s.ClientPool.Fence(context.Background(), message.SessionId)
+	if err != nil {
+		return err
+	}
+
 	/*
 	   Envia a mensagem para o destinatário usando o cliente WhatsApp.
 	   Se o envio falhar, retorna um erro.
 	*/
-	_, err = client.SendMessage(
+	resp, err := client.SendMessage(
 		context.Background(),
 		TO,
-		&waProto.Message{
-			Conversation: proto.String(message.GetMessage()),
-		})
+		content,
+		whatsmeow.SendRequestExtra{ID: whatsAppID})
 
 	if err != nil {
 		return err
 	}
 
+	if message.ID != "" {
+		err = s.MessageRepository.UpdateMessageStatus(context.Background(), message.ID, MessageStatusServerAck, resp.Timestamp, nil)
+		if err != nil && err != ErrMessageInvalidTransition {
+			log.Printf("Error updating message %s to %s: %v", message.ID, MessageStatusServerAck, err)
+		}
+	}
+
 	return nil
 }
+
+/*
+Método Due confirma, antes do envio, que a mensagem agendada ainda deve ser enviada e a marca como queued.
+Cópias publicadas antes de um cancelamento ou reagendamento não correspondem mais ao agendamento e são descartadas.
+Parâmetros:
+- message: Mensagem recebida.
+- scheduledAt: Valor do cabeçalho Scheduled-At do envelope.
+Retorna:
+- false se a mensagem deve ser descartada, e um erro se a verificação falhar.
+*/
+func (s *SendMessage) Due(message *Message, scheduledAt string) (bool, error) {
+	at, err := time.Parse(time.RFC3339Nano, scheduledAt)
+	if err != nil || message.ID == "" {
+		return false, nil
+	}
+
+	return s.MessageRepository.DispatchScheduledMessage(context.Background(), message.ID, at)
+}
+
+/*
+Método Sent indica se a mensagem já foi enviada, ou seja, se seu status já passou de queued.
+Usado para descartar cópias duplicadas, como as republicadas pelo driver quando a conexão cai antes da confirmação.
+Mensagens failed não são consideradas enviadas, para que possam ser reprocessadas a partir da fila de mensagens mortas.
+Parâmetros:
+- message: Mensagem recebida.
+Retorna:
+- true se a mensagem já foi enviada, e um erro se a verificação falhar.
+*/
+func (s *SendMessage) Sent(message *Message) (bool, error) {
+	if message.ID == "" {
+		return false, nil
+	}
+
+	record, err := s.MessageRepository.FindMessageByID(context.Background(), message.ID)
+	if errors.Is(err, ErrMessageNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch record.Status {
+	case MessageStatusServerAck, MessageStatusDelivered, MessageStatusRead, MessageStatusPlayed:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+/*
+Função IsPermanentSendError indica se o erro de envio não se resolve com novas tentativas,
+como mensagens inválidas ou mídias inexistentes, grandes demais, de tipo não aceito ou em endereços não públicos.
+*/
+func IsPermanentSendError(err error) bool {
+	return errors.Is(err, ErrMessageInvalid) ||
+		errors.Is(err, ErrMediaInvalid) ||
+		errors.Is(err, ErrMediaNotFound) ||
+		errors.Is(err, ErrMediaTooLarge) ||
+		errors.Is(err, ErrMediaTypeNotAllowed) ||
+		errors.Is(err, ErrMediaURLNotAllowed)
+}
+
+/*
+Método Fail marca a mensagem como failed quando ela não pode ser enviada.
+Parâmetros:
+- message: Mensagem que falhou.
+- reason: Erro que impediu o envio.
+*/
+func (s *SendMessage) Fail(message *Message, reason error) {
+	if message.ID == "" {
+		return
+	}
+
+	text := reason.Error()
+	err := s.MessageRepository.UpdateMessageStatus(context.Background(), message.ID, MessageStatusFailed, time.Now(), &text)
+	if err != nil && err != ErrMessageInvalidTransition {
+		log.Printf("Error updating message %s to %s: %v", message.ID, MessageStatusFailed, err)
+	}
+}
+
+/*
+Método build monta o conteúdo da mensagem de acordo com o tipo.
+As mídias são carregadas pelo MediaLoader e enviadas aos servidores do WhatsApp com client.Upload.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- client: Cliente WhatsApp da sessão.
+- message: Mensagem a ser enviada.
+Retorna:
+- O conteúdo da mensagem e um erro, se houver.
+*/
+func (s *SendMessage) build(ctx context.Context, client *whatsmeow.Client, message *Message) (*waProto.Message, error) {
+	if err := validateMessage(message); err != nil {
+		return nil, err
+	}
+
+	if message.Type == "" || message.Type == MessageTypeText {
+		return &waProto.Message{
+			Conversation: proto.String(message.GetMessage()),
+		}, nil
+	}
+
+	data, mimeType, fileName, err := s.MediaLoader.Load(ctx, message.Type, message.Media)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded, err := client.Upload(ctx, data, mediaKinds[message.Type].appInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var caption *string
+	if message.Caption != "" {
+		caption = proto.String(message.Caption)
+	}
+
+	switch message.Type {
+	case MessageTypeImage:
+		return &waProto.Message{ImageMessage: &waProto.ImageMessage{
+			Caption:       caption,
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+	case MessageTypeVideo:
+		return &waProto.Message{VideoMessage: &waProto.VideoMessage{
+			Caption:       caption,
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+	case MessageTypeAudio, MessageTypePTT:
+		ptt := message.Type == MessageTypePTT
+		if ptt {
+			mimeType = "audio/ogg; codecs=opus"
+		}
+		return &waProto.Message{AudioMessage: &waProto.AudioMessage{
+			PTT:           proto.Bool(ptt),
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+	case MessageTypeDocument:
+		if fileName == "" {
+			fileName = "document"
+		}
+		return &waProto.Message{DocumentMessage: &waProto.DocumentMessage{
+			Caption:       caption,
+			FileName:      proto.String(fileName),
+			Title:         proto.String(fileName),
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+	default:
+		return &waProto.Message{StickerMessage: &waProto.StickerMessage{
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}, nil
+	}
+}
+
+/*
+Estrutura MessageStatusHandler atualiza o status das mensagens enviadas a partir das confirmações do WhatsApp.
+*/
+type MessageStatusHandler struct {
+	MessageRepository MessageRepository
+}
+
+/*
+Método HandleEvent aplica o status correspondente às confirmações recebidas pela sessão.
+- Confirmação de entrega: delivered.
+- read e played: read e played.
+- server-error: failed.
+Confirmações dos próprios aparelhos (read-self, played-self) e demais tipos são ignorados.
+Parâmetros:
+- sessionID: Identificador da sessão.
+- client: Cliente whatsmeow que recebeu o evento.
+- evt: Evento recebido.
+*/
+func (h MessageStatusHandler) HandleEvent(sessionID string, client *whatsmeow.Client, evt interface{}) {
+	receipt, ok := evt.(*events.Receipt)
+	if !ok {
+		return
+	}
+
+	var (
+		status string
+		reason *string
+	)
+
+	switch receipt.Type {
+	case types.ReceiptTypeDelivered:
+		status = MessageStatusDelivered
+	case types.ReceiptTypeRead:
+		status = MessageStatusRead
+	case types.ReceiptTypePlayed:
+		status = MessageStatusPlayed
+	case types.ReceiptTypeServerError:
+		status = MessageStatusFailed
+		text := string(receipt.Type)
+		reason = &text
+	default:
+		return
+	}
+
+	err := h.MessageRepository.UpdateMessageStatusByWhatsAppIDs(context.Background(), sessionID, receipt.MessageIDs, status, receipt.Timestamp, reason)
+	if err != nil {
+		log.Printf("Error updating messages %v of session %s to %s: %v", receipt.MessageIDs, sessionID, status, err)
+	}
+}
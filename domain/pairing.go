@@ -0,0 +1,205 @@
+package domain
+
+import (
+	"errors"
+	"sync"
+)
+
+/*
+Definição de variáveis de erro específicas para o acompanhamento de pareamentos.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrPairingNotActive = errors.New("pairing.not_active: session has no pairing in progress")
+)
+
+/*
+Tipos de evento publicados durante o pareamento.
+code é emitido a cada novo código QR; success, timeout e error encerram o pareamento.
+*/
+const (
+	PairingEventCode    = "code"
+	PairingEventSuccess = "success"
+	PairingEventTimeout = "timeout"
+	PairingEventError   = "error"
+)
+
+/*
+Estrutura PairingEvent representa um evento de pareamento enviado aos clientes da API.
+Campos:
+- Event: Tipo do evento.
+- SessionID: Identificador da sessão.
+- Code: Conteúdo bruto do código QR ou código de pareamento.
+- AuthCode: Imagem PNG do código QR em data URI.
+- Error: Motivo da falha, nos eventos de erro.
+*/
+type PairingEvent struct {
+	Event     string `json:"event"`
+	SessionID string `json:"sessionId"`
+	Code      string `json:"code,omitempty"`
+	AuthCode  string `json:"authCode,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+/*
+Método IsFinal indica se o evento encerra o pareamento.
+*/
+func (e PairingEvent) IsFinal() bool {
+	return e.Event != PairingEventCode
+}
+
+/*
+Estrutura PairingRegistry mantém os pareamentos em andamento neste processo
+e distribui seus eventos para os assinantes, como os streams de QR da API.
+*/
+type PairingRegistry struct {
+	mu       sync.Mutex
+	pairings map[string]*pairing
+}
+
+/*
+Estrutura pairing guarda o último código emitido e os assinantes de um pareamento.
+*/
+type pairing struct {
+	last        *PairingEvent
+	subscribers map[chan PairingEvent]struct{}
+	stop        func()
+}
+
+/*
+Função NewPairingRegistry cria uma nova instância do PairingRegistry.
+Retorna um ponteiro para a estrutura PairingRegistry.
+*/
+func NewPairingRegistry() *PairingRegistry {
+	return &PairingRegistry{
+		pairings: make(map[string]*pairing),
+	}
+}
+
+/*
+Método Start registra um pareamento em andamento para a sessão.
+Parâmetros:
+- sessionID: Identificador da sessão.
+- stop: Função que interrompe o pareamento, usada por Cancel.
+*/
+func (r *PairingRegistry) Start(sessionID string, stop func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.pairings[sessionID]; !ok {
+		r.pairings[sessionID] = &pairing{
+			subscribers: make(map[chan PairingEvent]struct{}),
+			stop:        stop,
+		}
+	}
+}
+
+/*
+Método Cancel interrompe o pareamento em andamento da sessão, se houver,
+publicando um evento de erro com o motivo informado.
+Parâmetros:
+- sessionID: Identificador da sessão.
+- reason: Motivo do cancelamento.
+Retorna:
+- Verdadeiro se havia um pareamento em andamento.
+*/
+func (r *PairingRegistry) Cancel(sessionID string, reason string) bool {
+	r.mu.Lock()
+	p, ok := r.pairings[sessionID]
+	r.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	r.Publish(PairingEvent{
+		Event:     PairingEventError,
+		SessionID: sessionID,
+		Error:     reason,
+	})
+	if p.stop != nil {
+		p.stop()
+	}
+
+	return true
+}
+
+/*
+Método Publish envia o evento a todos os assinantes do pareamento.
+Eventos finais encerram o pareamento e fecham os canais dos assinantes.
+Assinantes lentos descartam códigos intermediários, mas sempre recebem o evento final.
+Parâmetros:
+- evt: Evento a ser publicado.
+*/
+func (r *PairingRegistry) Publish(evt PairingEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pairings[evt.SessionID]
+	if !ok {
+		return
+	}
+
+	if !evt.IsFinal() {
+		p.last = &evt
+		for ch := range p.subscribers {
+			select {
+			case ch <- evt:
+			default:
+			}
+		}
+		return
+	}
+
+	for ch := range p.subscribers {
+		select {
+		case ch <- evt:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- evt
+		}
+		close(ch)
+	}
+	delete(r.pairings, evt.SessionID)
+}
+
+/*
+Método Subscribe assina os eventos do pareamento da sessão.
+O último código emitido é entregue imediatamente. O canal é fechado após o evento final.
+Parâmetros:
+- sessionID: Identificador da sessão.
+Retorna:
+- O canal de eventos, a função que cancela a assinatura e ErrPairingNotActive se não houver pareamento em andamento.
+*/
+func (r *PairingRegistry) Subscribe(sessionID string) (<-chan PairingEvent, func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pairings[sessionID]
+	if !ok {
+		return nil, nil, ErrPairingNotActive
+	}
+
+	ch := make(chan PairingEvent, 4)
+	if p.last != nil {
+		ch <- *p.last
+	}
+	p.subscribers[ch] = struct{}{}
+
+	cancel := func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if current, ok := r.pairings[sessionID]; ok && current == p {
+			if _, ok := p.subscribers[ch]; ok {
+				delete(p.subscribers, ch)
+				close(ch)
+			}
+		}
+	}
+
+	return ch, cancel, nil
+}
@@ -3,7 +3,11 @@ package domain
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
 
+	"github.com/lib/pq"
 	"go.mau.fi/whatsmeow/store"
 	"go.mau.fi/whatsmeow/store/sqlstore"
 )
@@ -74,3 +78,1593 @@ func (r WhatsAppRepository) FindDeviceWM(ctx context.Context, sessionID string)
 
 	return device, nil
 }
+
+/*
+Códigos de erro do Postgres tratados pelos repositórios.
+*/
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+	pqInvalidText         = "22P02"
+)
+
+/*
+Função isPQError indica se o erro retornado pelo driver do Postgres possui o código informado.
+*/
+func isPQError(err error, code string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && string(pqErr.Code) == code
+}
+
+/*
+Estrutura AccountRepository que contém a conexão com o banco de dados Postgres.
+Esta estrutura é responsável por realizar operações no banco de dados relacionadas às contas.
+*/
+type AccountRepository struct {
+	DB *sql.DB
+}
+
+/*
+Constante accountColumns lista as colunas lidas nas consultas de contas, na ordem usada por scanAccount.
+*/
+const accountColumns = `id, name, origin, external_id, webhook_url, created_at, updated_at,
+	webhook_secret, webhook_previous_secret, webhook_previous_secret_expires_at`
+
+/*
+Função scanAccount lê uma linha de conta na ordem definida por accountColumns.
+*/
+func scanAccount(row interface{ Scan(dest ...any) error }) (account *Account, err error) {
+	account = &Account{}
+	err = row.Scan(
+		&account.ID,
+		&account.Name,
+		&account.Origin,
+		&account.ExternalId,
+		&account.WebhookURL,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+		&account.WebhookSecret,
+		&account.WebhookPreviousSecret,
+		&account.WebhookPreviousSecretExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+/*
+Método CreateAccount insere uma nova conta no banco de dados.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- account: Ponteiro para a conta a ser criada. ID e datas são preenchidos pelo banco.
+Retorna:
+- Um erro, se houver.
+*/
+func (r AccountRepository) CreateAccount(ctx context.Context, account *Account) (err error) {
+	query := `
+		INSERT INTO accounts (name, origin, external_id, webhook_url, webhook_secret)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+		`
+	err = r.DB.QueryRowContext(ctx, query, account.Name, account.Origin, account.ExternalId, account.WebhookURL, account.WebhookSecret).
+		Scan(&account.ID, &account.CreatedAt, &account.UpdatedAt)
+	if isPQError(err, pqUniqueViolation) {
+		return ErrAccountAlreadyExists
+	}
+
+	return err
+}
+
+/*
+Método FindAccountByID encontra uma conta pelo ID.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da conta.
+Retorna:
+- Um ponteiro para a conta e um erro, se houver.
+*/
+func (r AccountRepository) FindAccountByID(ctx context.Context, id string) (account *Account, err error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE id = $1`
+
+	account, err = scanAccount(r.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows || isPQError(err, pqInvalidText) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+/*
+Método ListAccounts lista as contas, opcionalmente filtradas por origem e identificador externo.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListAccountsRequest com os filtros e a paginação.
+Retorna:
+- Um slice de contas e um erro, se houver.
+*/
+func (r AccountRepository) ListAccounts(ctx context.Context, req ListAccountsRequest) (accounts []Account, err error) {
+	query := `SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE ($1 = '' OR origin = $1)
+		AND ($2 = '' OR external_id = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+		`
+	rows, err := r.DB.QueryContext(ctx, query, req.Origin, req.ExternalId, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts = []Account{}
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+
+	return accounts, rows.Err()
+}
+
+/*
+Método UpdateAccount atualiza os dados de uma conta existente.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- account: Ponteiro para a conta com os novos valores. UpdatedAt é preenchido pelo banco.
+Retorna:
+- Um erro, se houver.
+*/
+func (r AccountRepository) UpdateAccount(ctx context.Context, account *Account) (err error) {
+	query := `
+		UPDATE accounts
+		SET name = $2, origin = $3, external_id = $4, webhook_url = $5, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+		`
+	err = r.DB.QueryRowContext(ctx, query, account.ID, account.Name, account.Origin, account.ExternalId, account.WebhookURL).
+		Scan(&account.CreatedAt, &account.UpdatedAt)
+	if err == sql.ErrNoRows || isPQError(err, pqInvalidText) {
+		return ErrAccountNotFound
+	}
+	if isPQError(err, pqUniqueViolation) {
+		return ErrAccountAlreadyExists
+	}
+
+	return err
+}
+
+/*
+Método RotateWebhookSecret troca o segredo de webhook da conta, mantendo o segredo atual
+como segredo anterior até a data de expiração informada.
+A troca é recusada enquanto o segredo anterior de uma troca recente ainda for válido,
+para que ele não seja descartado antes do fim do seu prazo.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- account: Ponteiro para a conta com o ID, o novo segredo e a expiração do segredo anterior.
+Retorna:
+- ErrAccountRotationGrace se o segredo anterior ainda for válido, ou outro erro, se houver.
+*/
+func (r AccountRepository) RotateWebhookSecret(ctx context.Context, account *Account) (err error) {
+	query := `
+		UPDATE accounts
+		SET webhook_previous_secret = webhook_secret,
+			webhook_secret = $2,
+			webhook_previous_secret_expires_at = $3,
+			updated_at = NOW()
+		WHERE id = $1
+			AND (webhook_previous_secret = '' OR webhook_previous_secret_expires_at IS NULL
+				OR webhook_previous_secret_expires_at <= NOW())
+		RETURNING webhook_previous_secret
+		`
+	err = r.DB.QueryRowContext(ctx, query, account.ID, account.WebhookSecret, account.WebhookPreviousSecretExpiresAt).
+		Scan(&account.WebhookPreviousSecret)
+	if isPQError(err, pqInvalidText) {
+		return ErrAccountNotFound
+	}
+	if err == sql.ErrNoRows {
+		if _, err = r.FindAccountByID(ctx, account.ID); err != nil {
+			return err
+		}
+		return ErrAccountRotationGrace
+	}
+
+	return err
+}
+
+/*
+Método FindAccountBySessionID encontra a conta à qual a sessão pertence.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- sessionID: Identificador da sessão.
+Retorna:
+- Um ponteiro para a conta e ErrAccountNotFound se a sessão não estiver vinculada a uma conta.
+*/
+func (r AccountRepository) FindAccountBySessionID(ctx context.Context, sessionID string) (account *Account, err error) {
+	query := `SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE id = (SELECT account_id FROM sessions WHERE id = $1)
+		`
+	account, err = scanAccount(r.DB.QueryRowContext(ctx, query, sessionID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+/*
+Método DeleteAccount remove uma conta do banco de dados.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da conta.
+Retorna:
+- Um erro, se houver.
+*/
+func (r AccountRepository) DeleteAccount(ctx context.Context, id string) (err error) {
+	query := `
+		DELETE FROM accounts
+		WHERE id = $1
+		`
+	result, err := r.DB.ExecContext(ctx, query, id)
+	if isPQError(err, pqInvalidText) {
+		return ErrAccountNotFound
+	}
+	if isPQError(err, pqForeignKeyViolation) {
+		return ErrAccountHasSessions
+	}
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAccountNotFound
+	}
+
+	return nil
+}
+
+/*
+Estrutura SessionRepository que contém a conexão com o banco de dados Postgres.
+Esta estrutura é responsável por persistir o ciclo de vida das sessões WhatsApp.
+*/
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+/*
+Constante sessionColumns lista as colunas lidas nas consultas de sessões, na ordem usada por scanSession.
+*/
+const sessionColumns = `id, account_id, status, auth_code, jid, push_name, platform, ready_at, failure_reason, created_at, updated_at`
+
+/*
+Função scanSession lê uma linha de sessão na ordem definida por sessionColumns.
+*/
+func scanSession(row interface{ Scan(dest ...any) error }) (session *Session, err error) {
+	session = &Session{}
+	err = row.Scan(
+		&session.ID,
+		&session.AccountID,
+		&session.Status,
+		&session.AuthCode,
+		&session.JID,
+		&session.PushName,
+		&session.Platform,
+		&session.ReadyAt,
+		&session.FailureReason,
+		&session.CreatedAt,
+		&session.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+/*
+Método CreateSession insere uma nova sessão no banco de dados.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- session: Ponteiro para a sessão a ser criada. As datas são preenchidas pelo banco.
+Retorna:
+- Um erro, se houver.
+*/
+func (r SessionRepository) CreateSession(ctx context.Context, session *Session) (err error) {
+	query := `
+		INSERT INTO sessions (id, account_id, status, auth_code)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at
+		`
+	err = r.DB.QueryRowContext(ctx, query, session.ID, session.AccountID, session.Status, session.AuthCode).
+		Scan(&session.CreatedAt, &session.UpdatedAt)
+	if isPQError(err, pqForeignKeyViolation) || isPQError(err, pqInvalidText) {
+		return ErrAccountNotFound
+	}
+
+	return err
+}
+
+/*
+Método FindSessionByID encontra uma sessão pelo ID.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da sessão.
+Retorna:
+- Um ponteiro para a sessão e um erro, se houver.
+*/
+func (r SessionRepository) FindSessionByID(ctx context.Context, id string) (session *Session, err error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+
+	session, err = scanSession(r.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+/*
+Método ListSessions lista as sessões, opcionalmente filtradas por conta e status.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListSessionsRequest com os filtros e a paginação.
+Retorna:
+- Um slice de sessões e um erro, se houver.
+*/
+func (r SessionRepository) ListSessions(ctx context.Context, req ListSessionsRequest) (sessions []Session, err error) {
+	query := `SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE ($1 = '' OR account_id::text = $1)
+		AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+		`
+	rows, err := r.DB.QueryContext(ctx, query, req.AccountID, req.Status, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions = []Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, rows.Err()
+}
+
+/*
+Método ListSessionIDsByStatus lista os IDs de todas as sessões com o status informado.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- status: Status das sessões.
+Retorna:
+- Um slice com os IDs das sessões e um erro, se houver.
+*/
+func (r SessionRepository) ListSessionIDsByStatus(ctx context.Context, status string) (ids []string, err error) {
+	query := `
+		SELECT id
+		FROM sessions
+		WHERE status = $1
+		ORDER BY id
+		`
+	rows, err := r.DB.QueryContext(ctx, query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+/*
+Método UpdateSessionAuthCode grava o código de autenticação mais recente de uma sessão aguardando pareamento.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da sessão.
+- authCode: Código de autenticação (QR em data URI ou código de pareamento).
+Retorna:
+- Um erro, se houver.
+*/
+func (r SessionRepository) UpdateSessionAuthCode(ctx context.Context, id string, authCode string) (err error) {
+	query := `
+		UPDATE sessions
+		SET auth_code = $2, updated_at = NOW()
+		WHERE id = $1 AND status IN ($3, $4)
+		`
+	_, err = r.DB.ExecContext(ctx, query, id, authCode, SessionStatusPendingQR, SessionStatusPendingCode)
+	return err
+}
+
+/*
+Método UpdateSessionStatus altera o status de uma sessão respeitando as transições permitidas.
+A verificação do status de origem é feita na própria instrução UPDATE, evitando condições de corrida.
+O código de autenticação é descartado ao sair dos status pendentes e ready_at é preenchido ao ficar pronta.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da sessão.
+- status: Novo status da sessão.
+- update: Campos opcionais gravados junto com o novo status.
+Retorna:
+- ErrSessionInvalidTransition se a sessão não existir ou não puder ir para o novo status.
+*/
+func (r SessionRepository) UpdateSessionStatus(ctx context.Context, id string, status string, update SessionUpdate) (err error) {
+	query := `
+		UPDATE sessions
+		SET status = $2,
+			auth_code = '',
+			jid = COALESCE($3, jid),
+			push_name = COALESCE($4, push_name),
+			platform = COALESCE($5, platform),
+			failure_reason = COALESCE($6, failure_reason),
+			ready_at = CASE WHEN $2 = 'ready' THEN COALESCE(ready_at, NOW()) ELSE ready_at END,
+			updated_at = NOW()
+		WHERE id = $1 AND status = ANY($7)
+		`
+	result, err := r.DB.ExecContext(ctx, query,
+		id,
+		status,
+		update.JID,
+		update.PushName,
+		update.Platform,
+		update.FailureReason,
+		pq.Array(sessionTransitions[status]),
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrSessionInvalidTransition
+	}
+
+	return nil
+}
+
+/*
+Estrutura MediaRepository que contém a conexão com o banco de dados Postgres.
+Esta estrutura é responsável por guardar os arquivos de mídia enviados pela API.
+*/
+type MediaRepository struct {
+	DB *sql.DB
+}
+
+/*
+Método CreateMedia insere um novo arquivo de mídia no banco de dados.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- media: Ponteiro para a mídia a ser criada. A data de criação é preenchida pelo banco; ExpiresAt nulo não expira.
+Retorna:
+- Um erro, se houver.
+*/
+func (r MediaRepository) CreateMedia(ctx context.Context, media *Media) (err error) {
+	query := `
+		INSERT INTO media (id, mime_type, file_name, size, data, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at
+		`
+	return r.DB.QueryRowContext(ctx, query, media.ID, media.MimeType, media.FileName, media.Size, media.Data, media.ExpiresAt).
+		Scan(&media.CreatedAt)
+}
+
+/*
+Método DeleteExpiredMedia remove até limit mídias cuja data de expiração já passou.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- limit: Quantidade máxima de mídias removidas.
+Retorna:
+- A quantidade de mídias removidas e um erro, se houver.
+*/
+func (r MediaRepository) DeleteExpiredMedia(ctx context.Context, limit int) (removed int, err error) {
+	query := `
+		DELETE FROM media WHERE id IN (
+			SELECT id FROM media
+			WHERE expires_at IS NOT NULL AND expires_at <= NOW()
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)`
+
+	result, err := r.DB.ExecContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+/*
+Método FindMediaByID encontra uma mídia pelo ID, incluindo o conteúdo.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mídia.
+Retorna:
+- Um ponteiro para a mídia e um erro, se houver.
+*/
+func (r MediaRepository) FindMediaByID(ctx context.Context, id string) (media *Media, err error) {
+	query := `SELECT id, mime_type, file_name, size, data, created_at FROM media WHERE id = $1`
+
+	media = &Media{}
+	err = r.DB.QueryRowContext(ctx, query, id).
+		Scan(&media.ID, &media.MimeType, &media.FileName, &media.Size, &media.Data, &media.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+/*
+Método FindMediaInfoByID encontra uma mídia pelo ID sem carregar o conteúdo.
+Usado na validação do envio, antes de a mensagem ser enfileirada.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mídia.
+Retorna:
+- Um ponteiro para a mídia, sem Data, e um erro, se houver.
+*/
+func (r MediaRepository) FindMediaInfoByID(ctx context.Context, id string) (media *Media, err error) {
+	query := `SELECT id, mime_type, file_name, size, created_at FROM media WHERE id = $1`
+
+	media = &Media{}
+	err = r.DB.QueryRowContext(ctx, query, id).
+		Scan(&media.ID, &media.MimeType, &media.FileName, &media.Size, &media.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+/*
+Estrutura MessageRepository que contém a conexão com o banco de dados Postgres.
+Esta estrutura é responsável por persistir as mensagens enviadas e seus status.
+*/
+type MessageRepository struct {
+	DB *sql.DB
+}
+
+/*
+Constante messageColumns lista as colunas lidas nas consultas de mensagens, na ordem usada por scanMessage.
+*/
+const messageColumns = `id, session_id, recipient, type, status, whatsapp_id, error, sent_at, delivered_at, read_at, played_at, scheduled_at, created_at, updated_at`
+
+/*
+Constante messageStatusSet contém a atualização de status comum às consultas de mensagens.
+$2 é o novo status, $3 o momento da confirmação e $4 o motivo da falha.
+*/
+const messageStatusSet = `
+			status = $2,
+			sent_at = CASE WHEN $2 = 'server_ack' THEN COALESCE(sent_at, $3) ELSE sent_at END,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN COALESCE(delivered_at, $3) ELSE delivered_at END,
+			read_at = CASE WHEN $2 = 'read' THEN COALESCE(read_at, $3) ELSE read_at END,
+			played_at = CASE WHEN $2 = 'played' THEN COALESCE(played_at, $3) ELSE played_at END,
+			error = COALESCE($4, error),
+			updated_at = NOW()`
+
+/*
+Constante createMessageQuery insere uma mensagem, usada com e sem transação.
+Um ID já registrado mantém a mensagem existente, para os reenvios da mesma mensagem;
+somente uma mensagem failed volta ao status informado, para ser enviada de novo.
+*/
+const createMessageQuery = `
+	INSERT INTO messages (id, session_id, recipient, type, status, scheduled_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (id) DO UPDATE
+	SET status = CASE WHEN messages.status = 'failed' THEN EXCLUDED.status ELSE messages.status END
+	RETURNING created_at, updated_at
+	`
+
+/*
+Função scanMessage lê uma linha de mensagem na ordem definida por messageColumns.
+*/
+func scanMessage(row interface{ Scan(dest ...any) error }) (message *MessageRecord, err error) {
+	message = &MessageRecord{}
+	err = row.Scan(
+		&message.ID,
+		&message.SessionID,
+		&message.To,
+		&message.Type,
+		&message.Status,
+		&message.WhatsAppID,
+		&message.Error,
+		&message.SentAt,
+		&message.DeliveredAt,
+		&message.ReadAt,
+		&message.PlayedAt,
+		&message.ScheduledAt,
+		&message.CreatedAt,
+		&message.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+/*
+Método CreateMessage insere uma nova mensagem no banco de dados.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- message: Ponteiro para a mensagem a ser criada. As datas são preenchidas pelo banco.
+Retorna:
+- Um erro, se houver.
+*/
+func (r MessageRepository) CreateMessage(ctx context.Context, message *MessageRecord) (err error) {
+	return r.DB.QueryRowContext(ctx, createMessageQuery, message.ID, message.SessionID, message.To, message.Type, message.Status, message.ScheduledAt).
+		Scan(&message.CreatedAt, &message.UpdatedAt)
+}
+
+/*
+Método CreateMessageTx cria um novo registro de mensagem dentro da transação informada.
+Usado para registrar a mensagem e publicá-la no outbox atomicamente.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- tx: Transação em andamento.
+- message: Ponteiro para a mensagem a ser criada.
+Retorna:
+- Um erro, se houver.
+*/
+func (r MessageRepository) CreateMessageTx(ctx context.Context, tx *sql.Tx, message *MessageRecord) (err error) {
+	return tx.QueryRowContext(ctx, createMessageQuery, message.ID, message.SessionID, message.To, message.Type, message.Status, message.ScheduledAt).
+		Scan(&message.CreatedAt, &message.UpdatedAt)
+}
+
+/*
+Método CreateScheduledMessage registra a mensagem agendada e o conteúdo a ser publicado na mesma transação.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- message: Ponteiro para a mensagem, com status scheduled e ScheduledAt preenchido.
+- queue: Fila em que a mensagem será publicada.
+- body: Mensagem serializada.
+Retorna:
+- Um erro, se houver.
+*/
+func (r MessageRepository) CreateScheduledMessage(ctx context.Context, message *MessageRecord, queue string, body []byte) (err error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = r.CreateMessageTx(ctx, tx, message)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO scheduled_messages (id, queue, body) VALUES ($1, $2, $3)`
+	_, err = tx.ExecContext(ctx, query, message.ID, queue, body)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/*
+Método ListScheduledMessages lista as mensagens agendadas pendentes, pela data de envio.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListScheduledMessagesRequest com a sessão, opcional, e a paginação.
+Retorna:
+- Um slice com as mensagens e um erro, se houver.
+*/
+func (r MessageRepository) ListScheduledMessages(ctx context.Context, req ListScheduledMessagesRequest) (messages []MessageRecord, err error) {
+	query := `SELECT ` + messageColumns + `
+		FROM messages
+		WHERE status = 'scheduled'
+		AND ($1 = '' OR session_id = $1)
+		ORDER BY scheduled_at, id
+		LIMIT $2 OFFSET $3
+		`
+	rows, err := r.DB.QueryContext(ctx, query, req.SessionID, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages = []MessageRecord{}
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *message)
+	}
+
+	return messages, rows.Err()
+}
+
+/*
+Método RescheduleMessage altera a data de envio de uma mensagem agendada pendente
+e a marca para ser publicada novamente.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem.
+- at: Nova data de envio.
+Retorna:
+- A mensagem agendada com a nova data.
+- ErrMessageInvalidTransition se a mensagem não estiver agendada, ErrMessageNotFound ou outro erro, se houver.
+*/
+func (r MessageRepository) RescheduleMessage(ctx context.Context, id string, at time.Time) (scheduled *ScheduledMessage, err error) {
+	query := `
+		WITH updated AS (
+			UPDATE messages SET scheduled_at = $2, updated_at = NOW()
+			WHERE id = $1 AND status = 'scheduled'
+			RETURNING id, session_id, scheduled_at
+		)
+		UPDATE scheduled_messages s SET published_at = NULL, locked_until = NULL
+		FROM updated u
+		WHERE s.id = u.id
+		RETURNING s.id, u.session_id, s.queue, s.body, u.scheduled_at
+		`
+
+	scheduled = &ScheduledMessage{}
+	err = r.DB.QueryRowContext(ctx, query, id, at).
+		Scan(&scheduled.ID, &scheduled.SessionID, &scheduled.Queue, &scheduled.Body, &scheduled.SendAt)
+	if err == sql.ErrNoRows {
+		return nil, r.scheduleError(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return scheduled, nil
+}
+
+/*
+Método CancelScheduledMessage cancela uma mensagem agendada pendente e remove o conteúdo a ser publicado.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem.
+Retorna:
+- ErrMessageInvalidTransition se a mensagem não estiver agendada, ErrMessageNotFound ou outro erro, se houver.
+*/
+func (r MessageRepository) CancelScheduledMessage(ctx context.Context, id string) (err error) {
+	query := `
+		WITH cancelled AS (
+			UPDATE messages SET status = 'cancelled', updated_at = NOW()
+			WHERE id = $1 AND status = 'scheduled'
+			RETURNING id
+		), deleted AS (
+			DELETE FROM scheduled_messages WHERE id IN (SELECT id FROM cancelled)
+		)
+		SELECT COUNT(*) FROM cancelled
+		`
+
+	var count int
+	err = r.DB.QueryRowContext(ctx, query, id).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return r.scheduleError(ctx, id)
+	}
+
+	return nil
+}
+
+/*
+Método scheduleError retorna ErrMessageNotFound se a mensagem não existir
+ou ErrMessageInvalidTransition se ela não estiver mais agendada.
+*/
+func (r MessageRepository) scheduleError(ctx context.Context, id string) error {
+	_, err := r.FindMessageByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return ErrMessageInvalidTransition
+}
+
+/*
+Método ReserveDueScheduledMessages reserva por lease as mensagens agendadas vencidas e ainda não publicadas.
+Mensagens reservadas por outras réplicas são ignoradas com SKIP LOCKED; reservas não concluídas expiram ao fim do lease.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- limit: Quantidade máxima de mensagens.
+- lease: Duração da reserva.
+Retorna:
+- Um slice com as mensagens reservadas e um erro, se houver.
+*/
+func (r MessageRepository) ReserveDueScheduledMessages(ctx context.Context, limit int, lease time.Duration) (scheduled []ScheduledMessage, err error) {
+	query := `
+		UPDATE scheduled_messages s SET locked_until = NOW() + $2::bigint * INTERVAL '1 millisecond'
+		FROM messages m
+		WHERE m.id = s.id AND s.id IN (
+			SELECT ss.id FROM scheduled_messages ss
+			JOIN messages mm ON mm.id = ss.id
+			WHERE mm.status = 'scheduled' AND mm.scheduled_at <= NOW() AND ss.published_at IS NULL
+				AND (ss.locked_until IS NULL OR ss.locked_until < NOW())
+			ORDER BY mm.scheduled_at
+			LIMIT $1
+			FOR UPDATE OF ss SKIP LOCKED
+		)
+		RETURNING s.id, m.session_id, s.queue, s.body, m.scheduled_at
+		`
+	rows, err := r.DB.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scheduled = []ScheduledMessage{}
+	for rows.Next() {
+		var message ScheduledMessage
+		err := rows.Scan(&message.ID, &message.SessionID, &message.Queue, &message.Body, &message.SendAt)
+		if err != nil {
+			return nil, err
+		}
+		scheduled = append(scheduled, message)
+	}
+
+	return scheduled, rows.Err()
+}
+
+/*
+Método MarkScheduledMessagePublished registra que a mensagem agendada foi publicada,
+para que o agendador não a publique novamente.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem.
+- sendAt: Data de envio publicada; um reagendamento feito durante a publicação não é marcado.
+Retorna:
+- Um erro, se houver.
+*/
+func (r MessageRepository) MarkScheduledMessagePublished(ctx context.Context, id string, sendAt time.Time) (err error) {
+	query := `
+		UPDATE scheduled_messages s SET published_at = NOW(), locked_until = NULL
+		FROM messages m
+		WHERE s.id = $1 AND m.id = s.id AND m.scheduled_at = $2
+		`
+	_, err = r.DB.ExecContext(ctx, query, id, sendAt)
+	return err
+}
+
+/*
+Método DispatchScheduledMessage confirma, no consumo, que a data publicada ainda é a data agendada da mensagem,
+marcando-a como queued e removendo o conteúdo agendado.
+Entregas repetidas do mesmo agendamento continuam válidas depois da primeira.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem.
+- at: Data de envio publicada no envelope.
+Retorna:
+- false se a mensagem foi cancelada ou reagendada, e um erro, se houver.
+*/
+func (r MessageRepository) DispatchScheduledMessage(ctx context.Context, id string, at time.Time) (due bool, err error) {
+	query := `
+		WITH dispatched AS (
+			UPDATE messages
+			SET status = CASE WHEN status = 'scheduled' THEN 'queued' ELSE status END, updated_at = NOW()
+			WHERE id = $1 AND scheduled_at = $2 AND status <> 'cancelled'
+			RETURNING id
+		), deleted AS (
+			DELETE FROM scheduled_messages WHERE id IN (SELECT id FROM dispatched)
+		)
+		SELECT COUNT(*) FROM dispatched
+		`
+
+	var count int
+	err = r.DB.QueryRowContext(ctx, query, id, at).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+/*
+Método FindMessageByID encontra uma mensagem pelo ID.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem.
+Retorna:
+- Um ponteiro para a mensagem e um erro, se houver.
+*/
+func (r MessageRepository) FindMessageByID(ctx context.Context, id string) (message *MessageRecord, err error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = $1`
+
+	message, err = scanMessage(r.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+/*
+Método AssignMessageWhatsAppID registra o ID da mensagem no WhatsApp, se ela ainda não tiver um.
+Se a mensagem já tiver um ID, de uma tentativa anterior, ele é mantido e retornado,
+para que um novo envio use o mesmo ID e as confirmações continuem associadas à mensagem.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem.
+- whatsAppID: ID gerado para a mensagem no WhatsApp.
+Retorna:
+- O ID da mensagem no WhatsApp e ErrMessageNotFound se a mensagem não existir, ou outro erro, se houver.
+*/
+func (r MessageRepository) AssignMessageWhatsAppID(ctx context.Context, id string, whatsAppID string) (assigned string, err error) {
+	query := `
+		UPDATE messages SET whatsapp_id = COALESCE(whatsapp_id, $2), updated_at = NOW()
+		WHERE id = $1
+		RETURNING whatsapp_id`
+
+	err = r.DB.QueryRowContext(ctx, query, id, whatsAppID).Scan(&assigned)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrMessageNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return assigned, nil
+}
+
+/*
+Método UpdateMessageStatus altera o status da mensagem, respeitando as transições de messageTransitions.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem.
+- status: Novo status.
+- at: Momento da mudança, gravado na data correspondente ao status.
+- reason: Motivo da falha; nulo mantém o valor atual.
+Retorna:
+- ErrMessageInvalidTransition se o status atual não permitir a mudança, ou outro erro, se houver.
+*/
+func (r MessageRepository) UpdateMessageStatus(ctx context.Context, id string, status string, at time.Time, reason *string) (err error) {
+	query := `UPDATE messages SET ` + messageStatusSet + ` WHERE id = $1 AND status = ANY($5)`
+
+	result, err := r.DB.ExecContext(ctx, query, id, status, at, reason, pq.Array(messageTransitions[status]))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrMessageInvalidTransition
+	}
+
+	return nil
+}
+
+/*
+Método UpdateMessageStatusByWhatsAppIDs altera o status das mensagens da sessão a partir dos IDs do WhatsApp
+informados em uma confirmação. Mensagens em status posteriores são mantidas.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- sessionID: Identificador da sessão que enviou as mensagens.
+- whatsAppIDs: IDs das mensagens no WhatsApp.
+- status: Novo status.
+- at: Momento da confirmação.
+- reason: Motivo da falha; nulo mantém o valor atual.
+Retorna:
+- Um erro, se houver.
+*/
+func (r MessageRepository) UpdateMessageStatusByWhatsAppIDs(ctx context.Context, sessionID string, whatsAppIDs []string, status string, at time.Time, reason *string) (err error) {
+	query := `UPDATE messages SET ` + messageStatusSet + `
+		WHERE session_id = $1 AND whatsapp_id = ANY($5) AND status = ANY($6)`
+
+	_, err = r.DB.ExecContext(ctx, query, sessionID, status, at, reason, pq.Array(whatsAppIDs), pq.Array(messageTransitions[status]))
+	return err
+}
+
+/*
+Estrutura CampaignRepository que contém a conexão com o banco de dados Postgres.
+Esta estrutura é responsável por realizar operações no banco de dados relacionadas às campanhas.
+*/
+type CampaignRepository struct {
+	DB *sql.DB
+}
+
+/*
+Constante campaignColumns lista as colunas lidas nas consultas de campanhas, na ordem usada por scanCampaign.
+*/
+const campaignColumns = `id, session_id, name, template, status, completed_at, created_at, updated_at`
+
+/*
+Constante campaignRecipientStatus calcula o status informado do destinatário:
+o do próprio destinatário até ser enfileirado e, depois, o da mensagem enviada.
+Usada nas consultas de campaign_recipients r com LEFT JOIN messages m.
+*/
+const campaignRecipientStatus = `
+	CASE
+		WHEN m.status IS NULL THEN r.status
+		WHEN m.status IN ('scheduled', 'queued') THEN 'queued'
+		WHEN m.status = 'server_ack' THEN 'sent'
+		WHEN m.status IN ('read', 'played') THEN 'read'
+		ELSE m.status
+	END`
+
+/*
+Função scanCampaign lê uma campanha a partir de uma linha com as colunas de campaignColumns.
+*/
+func scanCampaign(row interface{ Scan(dest ...any) error }) (campaign *Campaign, err error) {
+	campaign = &Campaign{}
+	var template []byte
+	err = row.Scan(
+		&campaign.ID,
+		&campaign.SessionID,
+		&campaign.Name,
+		&template,
+		&campaign.Status,
+		&campaign.CompletedAt,
+		&campaign.CreatedAt,
+		&campaign.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(template, &campaign.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+/*
+Método CreateCampaign insere a campanha e seus destinatários na mesma transação.
+Os destinatários são gravados com COPY, na ordem recebida.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- campaign: Ponteiro para a campanha a ser criada. As datas são preenchidas pelo banco.
+- recipients: Destinatários da campanha.
+Retorna:
+- Um erro, se houver.
+*/
+func (r CampaignRepository) CreateCampaign(ctx context.Context, campaign *Campaign, recipients []CampaignRecipientRequest) (err error) {
+	template, err := json.Marshal(campaign.Template)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO campaigns (id, session_id, name, template, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at, updated_at
+		`
+	err = tx.QueryRowContext(ctx, query, campaign.ID, campaign.SessionID, campaign.Name, template, campaign.Status).
+		Scan(&campaign.CreatedAt, &campaign.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("campaign_recipients", "campaign_id", "position", "recipient", "variables"))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, recipient := range recipients {
+		variables := recipient.Variables
+		if variables == nil {
+			variables = map[string]string{}
+		}
+		data, err := json.Marshal(variables)
+		if err != nil {
+			return err
+		}
+
+		_, err = stmt.ExecContext(ctx, campaign.ID, i+1, recipient.To, string(data))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = stmt.ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/*
+Método FindCampaignByID encontra uma campanha pelo ID.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da campanha.
+Retorna:
+- Um ponteiro para a campanha e um erro, se houver. Retorna ErrCampaignNotFound se a campanha não existir.
+*/
+func (r CampaignRepository) FindCampaignByID(ctx context.Context, id string) (campaign *Campaign, err error) {
+	query := `SELECT ` + campaignColumns + ` FROM campaigns WHERE id = $1`
+
+	campaign, err = scanCampaign(r.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+/*
+Método ListCampaigns lista as campanhas, opcionalmente filtradas por sessão e status.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListCampaignsRequest com os filtros e a paginação.
+Retorna:
+- Um slice com as campanhas e um erro, se houver.
+*/
+func (r CampaignRepository) ListCampaigns(ctx context.Context, req ListCampaignsRequest) (campaigns []Campaign, err error) {
+	query := `SELECT ` + campaignColumns + `
+		FROM campaigns
+		WHERE ($1 = '' OR session_id = $1)
+		AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+		`
+	rows, err := r.DB.QueryContext(ctx, query, req.SessionID, req.Status, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	campaigns = []Campaign{}
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, *campaign)
+	}
+
+	return campaigns, rows.Err()
+}
+
+/*
+Método CampaignProgress conta os destinatários de cada campanha por status informado.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- ids: Identificadores das campanhas.
+Retorna:
+- Um mapa do ID da campanha para a quantidade de destinatários por status e um erro, se houver.
+*/
+func (r CampaignRepository) CampaignProgress(ctx context.Context, ids []string) (progress map[string]map[string]int, err error) {
+	query := `
+		SELECT r.campaign_id, ` + campaignRecipientStatus + ` AS status, COUNT(*)
+		FROM campaign_recipients r
+		LEFT JOIN messages m ON m.id = r.message_id
+		WHERE r.campaign_id = ANY($1)
+		GROUP BY 1, 2
+		`
+	rows, err := r.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress = make(map[string]map[string]int, len(ids))
+	for rows.Next() {
+		var id, status string
+		var count int
+		err := rows.Scan(&id, &status, &count)
+		if err != nil {
+			return nil, err
+		}
+		if progress[id] == nil {
+			progress[id] = map[string]int{}
+		}
+		progress[id][status] = count
+	}
+
+	return progress, rows.Err()
+}
+
+/*
+Método ListCampaignRecipients lista os destinatários da campanha na ordem de envio,
+opcionalmente filtrados pelo status informado.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListCampaignRecipientsRequest com a campanha, o filtro e a paginação.
+Retorna:
+- Um slice com os destinatários e um erro, se houver.
+*/
+func (r CampaignRepository) ListCampaignRecipients(ctx context.Context, req ListCampaignRecipientsRequest) (recipients []CampaignRecipient, err error) {
+	query := `
+		SELECT * FROM (
+			SELECT r.id, r.campaign_id, r.position, r.recipient, r.variables, ` + campaignRecipientStatus + ` AS status,
+				r.message_id, COALESCE(m.error, r.error), GREATEST(r.updated_at, m.updated_at)
+			FROM campaign_recipients r
+			LEFT JOIN messages m ON m.id = r.message_id
+			WHERE r.campaign_id = $1
+		) recipients
+		WHERE ($2 = '' OR status = $2)
+		ORDER BY position
+		LIMIT $3 OFFSET $4
+		`
+	rows, err := r.DB.QueryContext(ctx, query, req.CampaignID, req.Status, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipients = []CampaignRecipient{}
+	for rows.Next() {
+		recipient, err := scanCampaignRecipient(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, *recipient)
+	}
+
+	return recipients, rows.Err()
+}
+
+/*
+Função scanCampaignRecipient lê um destinatário da campanha, com as variáveis em JSON.
+*/
+func scanCampaignRecipient(row interface{ Scan(dest ...any) error }) (recipient *CampaignRecipient, err error) {
+	recipient = &CampaignRecipient{}
+	var variables []byte
+	err = row.Scan(
+		&recipient.ID,
+		&recipient.CampaignID,
+		&recipient.Position,
+		&recipient.To,
+		&variables,
+		&recipient.Status,
+		&recipient.MessageID,
+		&recipient.Error,
+		&recipient.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(variables, &recipient.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	return recipient, nil
+}
+
+/*
+Método UpdateCampaignStatus altera o status da campanha, respeitando as transições de campaignTransitions.
+Ao cancelar, os destinatários ainda pendentes também são cancelados; os já enfileirados seguem o envio.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da campanha.
+- status: Novo status.
+Retorna:
+- ErrCampaignInvalidTransition se o status atual não permitir a mudança, ou outro erro, se houver.
+*/
+func (r CampaignRepository) UpdateCampaignStatus(ctx context.Context, id string, status string) (err error) {
+	query := `
+		WITH updated AS (
+			UPDATE campaigns SET status = $2, updated_at = NOW()
+			WHERE id = $1 AND status = ANY($3)
+			RETURNING id
+		), cancelled AS (
+			UPDATE campaign_recipients SET status = 'cancelled', locked_until = NULL, updated_at = NOW()
+			WHERE $2 = 'cancelled' AND status = 'pending' AND campaign_id IN (SELECT id FROM updated)
+		)
+		SELECT COUNT(*) FROM updated
+		`
+
+	var count int
+	err = r.DB.QueryRowContext(ctx, query, id, status, pq.Array(campaignTransitions[status])).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrCampaignInvalidTransition
+	}
+
+	return nil
+}
+
+/*
+Método ReservePendingRecipients reserva por lease os próximos destinatários pendentes das campanhas em andamento,
+na ordem de criação das campanhas e de cada lista.
+Destinatários reservados por outras réplicas são ignorados com SKIP LOCKED; reservas não concluídas expiram ao fim do lease.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- limit: Quantidade máxima de destinatários.
+- lease: Duração da reserva.
+Retorna:
+- Um slice com os destinatários reservados e um erro, se houver.
+*/
+func (r CampaignRepository) ReservePendingRecipients(ctx context.Context, limit int, lease time.Duration) (recipients []CampaignRecipient, err error) {
+	query := `
+		UPDATE campaign_recipients r SET locked_until = NOW() + $2::bigint * INTERVAL '1 millisecond'
+		WHERE r.id IN (
+			SELECT rr.id FROM campaign_recipients rr
+			JOIN campaigns c ON c.id = rr.campaign_id
+			WHERE c.status = 'running' AND rr.status = 'pending'
+				AND (rr.locked_until IS NULL OR rr.locked_until < NOW())
+			ORDER BY c.created_at, rr.campaign_id, rr.position
+			LIMIT $1
+			FOR UPDATE OF rr SKIP LOCKED
+		)
+		RETURNING r.id, r.campaign_id, r.position, r.recipient, r.variables, r.status, r.message_id, r.error, r.updated_at
+		`
+	rows, err := r.DB.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipients = []CampaignRecipient{}
+	for rows.Next() {
+		recipient, err := scanCampaignRecipient(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, *recipient)
+	}
+
+	return recipients, rows.Err()
+}
+
+/*
+Método AssignRecipientMessage registra no destinatário a mensagem registrada para ele, antes da publicação.
+Só é feito enquanto o destinatário estiver pendente e a campanha em andamento,
+para que destinatários de campanhas pausadas ou canceladas não sejam enviados.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador do destinatário.
+- messageID: Identificador da mensagem.
+Retorna:
+- ErrCampaignInvalidTransition se o destinatário não estiver mais pendente ou a campanha não estiver em andamento,
+ou outro erro, se houver.
+*/
+func (r CampaignRepository) AssignRecipientMessage(ctx context.Context, id int64, messageID string) (err error) {
+	query := `
+		UPDATE campaign_recipients r
+		SET message_id = $2, updated_at = NOW()
+		FROM campaigns c
+		WHERE r.id = $1 AND r.status = 'pending' AND c.id = r.campaign_id AND c.status = 'running'
+		`
+	result, err := r.DB.ExecContext(ctx, query, id, messageID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrCampaignInvalidTransition
+	}
+
+	return nil
+}
+
+/*
+Método MarkRecipientQueued registra que a mensagem do destinatário foi publicada.
+A partir daí, o status do destinatário segue o status da mensagem.
+Destinatários que deixaram de estar pendentes, como os cancelados, não são alterados.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador do destinatário.
+- messageID: Identificador da mensagem enviada.
+Retorna:
+- Um erro, se houver.
+*/
+func (r CampaignRepository) MarkRecipientQueued(ctx context.Context, id int64, messageID string) (err error) {
+	query := `
+		UPDATE campaign_recipients
+		SET status = 'queued', message_id = $2, error = NULL, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		`
+	_, err = r.DB.ExecContext(ctx, query, id, messageID)
+	return err
+}
+
+/*
+Método ReleaseRecipients libera a reserva dos destinatários pendentes informados,
+para que sejam enviados na próxima consulta sem aguardar o fim do lease.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- ids: Identificadores dos destinatários.
+Retorna:
+- Um erro, se houver.
+*/
+func (r CampaignRepository) ReleaseRecipients(ctx context.Context, ids []int64) (err error) {
+	query := `
+		UPDATE campaign_recipients SET locked_until = NULL
+		WHERE id = ANY($1) AND status = 'pending'
+		`
+	_, err = r.DB.ExecContext(ctx, query, pq.Array(ids))
+	return err
+}
+
+/*
+Método MarkRecipientFailed registra que a mensagem do destinatário foi recusada e não será enviada.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador do destinatário.
+- reason: Motivo da falha.
+Retorna:
+- Um erro, se houver.
+*/
+func (r CampaignRepository) MarkRecipientFailed(ctx context.Context, id int64, reason string) (err error) {
+	query := `
+		UPDATE campaign_recipients
+		SET status = 'failed', error = $2, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		`
+	_, err = r.DB.ExecContext(ctx, query, id, reason)
+	return err
+}
+
+/*
+Método CompleteCampaigns conclui as campanhas em andamento sem destinatários pendentes.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+Retorna:
+- Um erro, se houver.
+*/
+func (r CampaignRepository) CompleteCampaigns(ctx context.Context) (err error) {
+	query := `
+		UPDATE campaigns c SET status = 'completed', completed_at = NOW(), updated_at = NOW()
+		WHERE c.status = 'running' AND NOT EXISTS (
+			SELECT 1 FROM campaign_recipients r WHERE r.campaign_id = c.id AND r.status = 'pending'
+		)
+		`
+	_, err = r.DB.ExecContext(ctx, query)
+	return err
+}
+
+/*
+Estrutura WebhookRetryRepository que contém a conexão com o banco de dados Postgres.
+Esta estrutura é responsável por guardar as entregas de webhook a serem repetidas,
+para que a espera entre as tentativas não ocupe os workers e as entregas sobrevivam ao encerramento.
+*/
+type WebhookRetryRepository struct {
+	DB *sql.DB
+}
+
+/*
+Estrutura WebhookRetry representa uma entrega de webhook guardada para nova tentativa.
+Campos:
+- Envelope: Evento a ser entregue.
+- Attempt: Quantidade de tentativas já feitas.
+*/
+type WebhookRetry struct {
+	Envelope EventEnvelope
+	Attempt  int
+}
+
+/*
+Método SaveWebhookRetry guarda a entrega para uma nova tentativa na data informada, liberando a reserva, se houver.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- retry: Entrega e quantidade de tentativas já feitas.
+- at: Data da próxima tentativa.
+Retorna:
+- Um erro, se houver.
+*/
+func (r WebhookRetryRepository) SaveWebhookRetry(ctx context.Context, retry WebhookRetry, at time.Time) (err error) {
+	envelope, err := json.Marshal(retry.Envelope)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO webhook_retries (id, envelope, attempt, available_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE
+		SET envelope = EXCLUDED.envelope, attempt = EXCLUDED.attempt, available_at = EXCLUDED.available_at, locked_until = NULL
+		`
+	_, err = r.DB.ExecContext(ctx, query, retry.Envelope.ID, envelope, retry.Attempt, at)
+	return err
+}
+
+/*
+Método ReserveWebhookRetries reserva por lease as entregas cuja próxima tentativa venceu.
+Entregas reservadas por outras réplicas são ignoradas com SKIP LOCKED; reservas não concluídas expiram ao fim do lease.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- limit: Quantidade máxima de entregas.
+- lease: Duração da reserva.
+Retorna:
+- Um slice com as entregas reservadas e um erro, se houver.
+*/
+func (r WebhookRetryRepository) ReserveWebhookRetries(ctx context.Context, limit int, lease time.Duration) (retries []WebhookRetry, err error) {
+	query := `
+		UPDATE webhook_retries SET locked_until = NOW() + $2::bigint * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM webhook_retries
+			WHERE available_at <= NOW() AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY available_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING envelope, attempt
+		`
+	rows, err := r.DB.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	retries = []WebhookRetry{}
+	for rows.Next() {
+		var envelope []byte
+		var retry WebhookRetry
+		err := rows.Scan(&envelope, &retry.Attempt)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(envelope, &retry.Envelope)
+		if err != nil {
+			return nil, err
+		}
+		retries = append(retries, retry)
+	}
+
+	return retries, rows.Err()
+}
+
+/*
+Método DeleteWebhookRetry remove a entrega concluída ou abandonada.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da entrega.
+Retorna:
+- Um erro, se houver.
+*/
+func (r WebhookRetryRepository) DeleteWebhookRetry(ctx context.Context, id string) (err error) {
+	_, err = r.DB.ExecContext(ctx, `DELETE FROM webhook_retries WHERE id = $1`, id)
+	return err
+}
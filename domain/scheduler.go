@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"gozap/core"
+	"log"
+	"time"
+)
+
+/*
+Quantidade máxima de mensagens agendadas publicadas por consulta e duração da reserva de cada uma.
+*/
+const (
+	scheduledMessageBatch = 100
+	scheduledMessageLease = time.Minute
+)
+
+/*
+Estrutura MessageScheduler publica as mensagens agendadas quando vencem.
+Com drivers de entrega agendada nativa (core.DelayedPublisher), as mensagens já são publicadas no agendamento,
+e o agendador publica apenas as que falharam nesse momento; com os demais drivers, publica todas na data de envio.
+As réplicas da API dividem as mensagens vencidas com FOR UPDATE SKIP LOCKED.
+*/
+type MessageScheduler struct {
+	MessageRepository MessageRepository
+	Messenger         core.MessengerInterface
+	Interval          time.Duration
+}
+
+/*
+Método Run publica as mensagens vencidas a cada Interval, até o contexto ser cancelado.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento.
+*/
+func (s MessageScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Método dispatch publica as mensagens vencidas, em lotes de scheduledMessageBatch.
+Mensagens cuja publicação falha são tentadas novamente ao fim da reserva.
+*/
+func (s MessageScheduler) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		scheduled, err := s.MessageRepository.ReserveDueScheduledMessages(ctx, scheduledMessageBatch, scheduledMessageLease)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error reading scheduled messages: %v", err)
+			}
+			return
+		}
+
+		for _, message := range scheduled {
+			err = s.Messenger.Publish(ctx, message.Queue, message.Envelope())
+			if err != nil {
+				log.Printf("Error publishing scheduled message %s: %v", message.ID, err)
+				continue
+			}
+
+			err = s.MessageRepository.MarkScheduledMessagePublished(ctx, message.ID, message.SendAt)
+			if err != nil {
+				log.Printf("Error marking scheduled message %s as published: %v", message.ID, err)
+			}
+		}
+
+		if len(scheduled) < scheduledMessageBatch {
+			return
+		}
+	}
+}
+
+/*
+Função publishScheduled publica a mensagem agendada com entrega agendada nativa, se o driver tiver suporte.
+Sem suporte, ou se a publicação falhar, a mensagem fica para o MessageScheduler publicar na data de envio.
+*/
+func publishScheduled(ctx context.Context, messenger core.MessengerInterface, repository MessageRepository, message ScheduledMessage) {
+	publisher, ok := messenger.(core.DelayedPublisher)
+	if !ok {
+		return
+	}
+
+	err := publisher.PublishAt(ctx, message.Queue, message.Envelope(), message.SendAt)
+	if err != nil {
+		log.Printf("Error publishing scheduled message %s, it will be published when due: %v", message.ID, err)
+		return
+	}
+
+	err = repository.MarkScheduledMessagePublished(ctx, message.ID, message.SendAt)
+	if err != nil {
+		log.Printf("Error marking scheduled message %s as published: %v", message.ID, err)
+	}
+}
+
+/*
+Função scheduleTime normaliza a data de envio para UTC com a precisão do Postgres,
+para que a data publicada no envelope corresponda à data gravada.
+*/
+func scheduleTime(at time.Time) time.Time {
+	return at.UTC().Truncate(time.Microsecond)
+}
@@ -5,11 +5,14 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gozap/core"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/skip2/go-qrcode"
 	"go.mau.fi/whatsmeow"
@@ -23,64 +26,363 @@ Esta estrutura é responsável por fornecer funcionalidades relacionadas ao What
 */
 type WhatsAppService struct {
 	WhatsAppRepository WhatsAppRepository
+	SessionRepository  SessionRepository
+	MediaRepository    MediaRepository
+	MessageRepository  MessageRepository
+	Pairings           *PairingRegistry
 	Messenger          core.MessengerInterface
+	Partitions         int
+	MediaRetention     time.Duration
 }
 
 /*
 Método Connect lida com a conexão ao serviço WhatsApp.
 Gera um código QR para autenticação e o retorna como uma imagem PNG.
-Em caso de sucesso, atualiza ou cria o dispositivo no banco de dados Postgres.
+A sessão é registrada como pending_qr e seu status é atualizado pelos eventos do pareamento.
 Parâmetros:
 - ctx: Contexto para controle de cancelamento e prazos.
 Retorna:
-- Um slice de bytes contendo a imagem PNG do código QR e um erro, se houver.
+- Uma estrutura ConnectResponse com o código QR em data URI e o ID da sessão e um erro, se houver.
 */
 func (s WhatsAppService) Connect(ctx context.Context) (res ConnectResponse, err error) {
+	return s.PairQR(ctx, nil)
+}
+
+/*
+Método ConnectPhone lida com a conexão ao serviço WhatsApp usando o número de telefone.
+Gera o código de pareamento de 8 caracteres a ser digitado no celular, como alternativa ao código QR.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ConnectPhoneRequest contendo o número de telefone do aparelho.
+Retorna:
+- Uma estrutura ConnectResponse com o código de pareamento e o ID da sessão e um erro, se houver.
+*/
+func (s WhatsAppService) ConnectPhone(ctx context.Context, req ConnectPhoneRequest) (res ConnectResponse, err error) {
+	return s.PairPhone(ctx, nil, req.Phone)
+}
+
+/*
+Estrutura pairingClient agrupa o cliente de pareamento de uma sessão e seu canal de QR.
+*/
+type pairingClient struct {
+	sessionID string
+	client    *whatsmeow.Client
+	qrChan    <-chan whatsmeow.QRChannelItem
+}
+
+/*
+Método startPairing cria o dispositivo e a sessão com o status informado, conecta um cliente de pareamento
+e aguarda o primeiro código QR, que indica que a conexão está pronta para o pareamento.
+Após o evento Connected a sessão fica ready e o cliente de pareamento é desconectado,
+pois as mensagens são enviadas pelos clientes do consumer.
+Retorna:
+- O cliente de pareamento, o primeiro código QR e um erro, se houver.
+*/
+func (s WhatsAppService) startPairing(ctx context.Context, accountID *string, status string) (pc *pairingClient, code string, err error) {
 
-	deviceStore := s.WhatsAppRepository.CreateDeviceWM(context.Background())
+	deviceStore := s.WhatsAppRepository.CreateDeviceWM(ctx)
 	client := whatsmeow.NewClient(deviceStore, nil)
+	sessionID := strconv.FormatUint(uint64(deviceStore.RegistrationID), 10)
 
-	pairSuccessChan := make(chan *events.PairSuccess, 1)
-	client.AddEventHandler(func(evt interface{}) {
-		if pairSuccess, ok := evt.(*events.PairSuccess); ok {
-			pairSuccessChan <- pairSuccess
-		}
-	})
+	session := &Session{
+		ID:        sessionID,
+		AccountID: accountID,
+		Status:    status,
+	}
+	if err = s.SessionRepository.CreateSession(ctx, session); err != nil {
+		return nil, "", err
+	}
+
+	s.Pairings.Start(sessionID, client.Disconnect)
 
-	go func() {
-		select {
-		case <-pairSuccessChan:
-			//request para atualizar o agenda que o aparelho foi configurado com sucesso
+	states := SessionStateHandler{SessionRepository: s.SessionRepository}
+	client.AddEventHandler(func(evt interface{}) {
+		states.HandleEvent(sessionID, client, evt)
 
+		if _, ok := evt.(*events.Connected); ok {
+			go client.Disconnect()
 		}
-	}()
+	})
 
 	store.SetOSInfo("Windows", [3]uint32{1, 2, 3})
 
 	qrChan, _ := client.GetQRChannel(context.Background())
 	err = client.Connect()
+	if err != nil {
+		s.finishPairing(sessionID, client, PairingEvent{Event: PairingEventError, Error: err.Error()})
+		return nil, "", err
+	}
+
+	evt, ok := <-qrChan
+	if !ok || evt.Event != whatsmeow.QRChannelEventCode {
+		s.finishPairing(sessionID, client, qrChannelFinalEvent(evt, ok))
+		return nil, "", ErrSessionPairingFailed
+	}
+
+	return &pairingClient{
+		sessionID: sessionID,
+		client:    client,
+		qrChan:    qrChan,
+	}, evt.Code, nil
+}
+
+/*
+Método PairQR inicia o pareamento de um novo dispositivo por código QR.
+Cria a sessão como pending_qr e retorna o primeiro código QR.
+O cliente continua ativo em segundo plano, publicando cada novo código QR até o pareamento terminar.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- accountID: Conta à qual a sessão pertence, ou nil.
+Retorna:
+- Uma estrutura ConnectResponse com o primeiro código QR e o ID da sessão e um erro, se houver.
+*/
+func (s WhatsAppService) PairQR(ctx context.Context, accountID *string) (res ConnectResponse, err error) {
+	pc, code, err := s.startPairing(ctx, accountID, SessionStatusPendingQR)
 	if err != nil {
 		return ConnectResponse{}, err
 	}
-	qr := []byte{}
-	for evt := range qrChan {
-		if evt.Event == "code" {
-			qr, err = qrcode.Encode(evt.Code, qrcode.Medium, 256)
-			if err != nil {
-				return ConnectResponse{}, err
-			}
-			break
-		}
+
+	authCode, err := s.publishQRCode(pc.sessionID, code)
+	if err != nil {
+		s.finishPairing(pc.sessionID, pc.client, PairingEvent{Event: PairingEventError, Error: err.Error()})
+		return ConnectResponse{}, err
 	}
 
-	qrBase64 := base64.StdEncoding.EncodeToString(qr)
-	qrToStringBase64 := fmt.Sprintf("data:image/png;base64,%s", qrBase64)
+	go s.watchQRChannel(pc, true)
+
+	return ConnectResponse{
+		AuthCode:  authCode,
+		SessionID: pc.sessionID,
+	}, nil
+}
+
+/*
+Método PairPhone inicia o pareamento de um novo dispositivo pelo número de telefone.
+Cria a sessão como pending_code e solicita ao WhatsApp o código de pareamento de 8 caracteres.
+O cliente continua ativo em segundo plano até o pareamento terminar, como no pareamento por QR.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- accountID: Conta à qual a sessão pertence, ou nil.
+- phone: Número de telefone em formato internacional, com código do país.
+Retorna:
+- Uma estrutura ConnectResponse com o código de pareamento e o ID da sessão e um erro, se houver.
+*/
+func (s WhatsAppService) PairPhone(ctx context.Context, accountID *string, phone string) (res ConnectResponse, err error) {
+	phone, err = normalizePairingPhone(phone)
+	if err != nil {
+		return ConnectResponse{}, err
+	}
+
+	pc, _, err := s.startPairing(ctx, accountID, SessionStatusPendingCode)
+	if err != nil {
+		return ConnectResponse{}, err
+	}
+
+	code, err := pc.client.PairPhone(phone, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+	if err != nil {
+		s.finishPairing(pc.sessionID, pc.client, PairingEvent{Event: PairingEventError, Error: err.Error()})
+		return ConnectResponse{}, fmt.Errorf("%w: %v", ErrSessionPairingFailed, err)
+	}
+
+	if err = s.SessionRepository.UpdateSessionAuthCode(ctx, pc.sessionID, code); err != nil {
+		log.Printf("Error saving auth code for session %s: %v", pc.sessionID, err)
+	}
+
+	s.Pairings.Publish(PairingEvent{
+		Event:     PairingEventCode,
+		SessionID: pc.sessionID,
+		Code:      code,
+	})
+
+	go s.watchQRChannel(pc, false)
+
 	return ConnectResponse{
-		AuthCode:  qrToStringBase64,
-		SessionID: strconv.FormatUint(uint64(deviceStore.RegistrationID), 10),
+		AuthCode:  code,
+		SessionID: pc.sessionID,
 	}, nil
 }
 
+/*
+Função normalizePairingPhone remove a formatação do número e valida o formato internacional
+exigido pelo pareamento por código.
+*/
+func normalizePairingPhone(phone string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+
+	if len(digits) < 8 || len(digits) > 15 || strings.HasPrefix(digits, "0") {
+		return "", ErrSessionPhoneInvalid
+	}
+
+	return digits, nil
+}
+
+/*
+Método watchQRChannel acompanha o canal de QR até o fim do pareamento, mantendo o cliente de pareamento ativo.
+Quando publishCodes é verdadeiro, cada novo código QR é gravado na sessão e publicado para os assinantes;
+no pareamento por telefone os códigos QR são ignorados.
+O último item do canal (sucesso, expiração ou erro) encerra o pareamento.
+*/
+func (s WhatsAppService) watchQRChannel(pc *pairingClient, publishCodes bool) {
+	for evt := range pc.qrChan {
+		if evt.Event != whatsmeow.QRChannelEventCode {
+			s.finishPairing(pc.sessionID, pc.client, qrChannelFinalEvent(evt, true))
+			return
+		}
+
+		if !publishCodes {
+			continue
+		}
+
+		if _, err := s.publishQRCode(pc.sessionID, evt.Code); err != nil {
+			log.Printf("Error publishing QR code for session %s: %v", pc.sessionID, err)
+		}
+	}
+}
+
+/*
+Método publishQRCode grava o novo código QR na sessão e o publica para os assinantes do pareamento.
+Retorna:
+- O código QR em data URI e um erro, se houver.
+*/
+func (s WhatsAppService) publishQRCode(sessionID string, code string) (authCode string, err error) {
+	authCode, err = qrCodeDataURI(code)
+	if err != nil {
+		return "", err
+	}
+
+	if err = s.SessionRepository.UpdateSessionAuthCode(context.Background(), sessionID, authCode); err != nil {
+		log.Printf("Error saving auth code for session %s: %v", sessionID, err)
+	}
+
+	s.Pairings.Publish(PairingEvent{
+		Event:     PairingEventCode,
+		SessionID: sessionID,
+		Code:      code,
+		AuthCode:  authCode,
+	})
+
+	return authCode, nil
+}
+
+/*
+Método finishPairing encerra o pareamento publicando o evento final.
+Em caso de expiração ou erro, a sessão é marcada como failed e o cliente de pareamento é desconectado.
+No sucesso, o cliente continua ativo até o evento Connected.
+*/
+func (s WhatsAppService) finishPairing(sessionID string, client *whatsmeow.Client, evt PairingEvent) {
+	evt.SessionID = sessionID
+
+	switch evt.Event {
+	case PairingEventSuccess:
+	case PairingEventTimeout:
+		SessionStateHandler{SessionRepository: s.SessionRepository}.Fail(sessionID, "pairing_timeout")
+		client.Disconnect()
+	default:
+		SessionStateHandler{SessionRepository: s.SessionRepository}.Fail(sessionID, evt.Error)
+		client.Disconnect()
+	}
+
+	s.Pairings.Publish(evt)
+}
+
+/*
+Função qrChannelFinalEvent converte o item final do canal de QR do whatsmeow em um PairingEvent.
+Parâmetros:
+- item: Item recebido do canal.
+- ok: Indica se o item foi recebido antes do fechamento do canal.
+*/
+func qrChannelFinalEvent(item whatsmeow.QRChannelItem, ok bool) PairingEvent {
+	switch {
+	case !ok:
+		return PairingEvent{Event: PairingEventError, Error: "qr_channel_closed"}
+	case item.Event == whatsmeow.QRChannelSuccess.Event:
+		return PairingEvent{Event: PairingEventSuccess}
+	case item.Event == whatsmeow.QRChannelTimeout.Event:
+		return PairingEvent{Event: PairingEventTimeout}
+	case item.Event == whatsmeow.QRChannelEventError && item.Error != nil:
+		return PairingEvent{Event: PairingEventError, Error: item.Error.Error()}
+	default:
+		return PairingEvent{Event: PairingEventError, Error: item.Event}
+	}
+}
+
+/*
+Função qrCodeDataURI gera a imagem PNG do código QR e a retorna como data URI em base64.
+*/
+func qrCodeDataURI(code string) (string, error) {
+	qr, err := qrcode.Encode(code, qrcode.Medium, 256)
+	if err != nil {
+		return "", err
+	}
+
+	qrBase64 := base64.StdEncoding.EncodeToString(qr)
+	return fmt.Sprintf("data:image/png;base64,%s", qrBase64), nil
+}
+
+/*
+Método Logout desconecta a sessão do WhatsApp e remove o dispositivo do banco de dados WhatsMeow.
+O logout é enviado ao WhatsApp por um cliente temporário; ao conectar, ele substitui a conexão
+mantida pelo consumer, que recebe StreamReplaced e remove o cliente do seu pool.
+Se o logout remoto falhar, o dispositivo é removido apenas localmente.
+Pareamentos em andamento são cancelados e a sessão é marcada como logged_out.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- sessionID: Identificador da sessão.
+Retorna:
+- Um erro, se houver.
+*/
+func (s WhatsAppService) Logout(ctx context.Context, sessionID string) (err error) {
+	session, err := s.SessionRepository.FindSessionByID(ctx, sessionID)
+	if err != nil && err != ErrSessionNotFound {
+		return err
+	}
+	if session != nil && session.Status == SessionStatusLoggedOut {
+		return nil
+	}
+
+	s.Pairings.Cancel(sessionID, SessionStatusLoggedOut)
+
+	deviceStore, err := s.WhatsAppRepository.FindDeviceWM(ctx, sessionID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == sql.ErrNoRows && session == nil {
+		return ErrSessionNotFound
+	}
+
+	if deviceStore != nil {
+		client := whatsmeow.NewClient(deviceStore, nil)
+		client.EnableAutoReconnect = false
+
+		err = client.Connect()
+		if err == nil {
+			err = waitLoggedIn(ctx, client, clientLoginTimeout)
+		}
+		if err == nil {
+			err = client.Logout()
+		}
+		client.Disconnect()
+
+		if err != nil {
+			log.Printf("Error logging out session %s on WhatsApp, deleting device locally: %v", sessionID, err)
+			if err = s.WhatsAppRepository.DeleteDeviceWM(ctx, deviceStore); err != nil {
+				return err
+			}
+		}
+	}
+
+	if session == nil {
+		return nil
+	}
+
+	return s.SessionRepository.UpdateSessionStatus(ctx, sessionID, SessionStatusLoggedOut, SessionUpdate{})
+}
+
 /*
 Método Validate lida com a validação de um número de telefone.
 Verifica se o dispositivo associado ao número de telefone está ativo.
@@ -112,7 +414,8 @@ func (s WhatsAppService) Validate(ctx context.Context, req ValidateRequest) (res
 
 /*
 Método Send lida com o envio de uma mensagem.
-Publica a mensagem na fila RabbitMQ.
+Valida o tipo e a mídia da mensagem e a publica na fila RabbitMQ.
+Com sendAt futuro, a mensagem é registrada como scheduled e publicada para entrega na data de envio.
 Parâmetros:
 - ctx: Contexto para controle de cancelamento e prazos.
 - req: Estrutura SendRequest contendo os detalhes da mensagem a ser enviada.
@@ -120,27 +423,1081 @@ Retorna:
 - Uma estrutura SendResponse indicando se a mensagem foi enviada com sucesso e um erro, se houver.
 */
 func (s WhatsAppService) Send(ctx context.Context, req SendRequest) (res SendResponse, err error) {
-	err = s.Messenger.Connect()
+	return s.send(ctx, req, newID(), nil)
+}
+
+/*
+Método send valida, registra e publica a mensagem com o ID informado.
+Um ID já registrado reaproveita a mensagem, para que o reenvio da mesma mensagem, como nas campanhas,
+não crie uma nova; cópias publicadas mais de uma vez são descartadas pelo consumer depois do envio.
+Com registered, a função é chamada depois de a mensagem ser registrada e antes de ser publicada;
+um erro interrompe o envio. Com o outbox transacional, em que registro e publicação são atômicos,
+é chamada após a transação.
+*/
+func (s WhatsAppService) send(ctx context.Context, req SendRequest, id string, registered func(ctx context.Context) error) (res SendResponse, err error) {
+	err = s.validateSend(ctx, req)
 	if err != nil {
 		return SendResponse{
 			Sent: false,
 		}, err
 	}
-	defer s.Messenger.Close()
 
-	jsonReq, err := json.Marshal(req)
+	/*
+	   Mídias em base64 são guardadas na tabela de mídias e enfileiradas por mediaId,
+	   para que o conteúdo não trafegue no servidor de mensageria, nas novas tentativas e na fila de mensagens mortas.
+	   Com MediaRetention, elas expiram MediaRetention depois do envio, ou da data agendada.
+	*/
+	if req.Media != nil && req.Media.Base64 != "" {
+		req.Media, err = s.storeMedia(ctx, req.Media, req.SendAt)
+		if err != nil {
+			return SendResponse{
+				Sent: false,
+			}, err
+		}
+	}
+
+	/*
+	   Registra a mensagem como queued antes de publicá-la,
+	   para que o status possa ser consultado em GET /messages/{id}.
+	*/
+	message := &MessageRecord{
+		ID:        id,
+		SessionID: req.SessionId,
+		To:        req.To,
+		Type:      req.Type,
+		Status:    MessageStatusQueued,
+	}
+	if message.Type == "" {
+		message.Type = MessageTypeText
+	}
+	/*
+	   O Messenger é conectado uma única vez na inicialização da API e compartilhado entre as requisições.
+	*/
+	jsonReq, err := json.Marshal(Message{
+		ID:        message.ID,
+		SessionId: req.SessionId,
+		To:        req.To,
+		Message:   req.Message,
+		Type:      req.Type,
+		Caption:   req.Caption,
+		Media:     req.Media,
+	})
 	if err != nil {
 		log.Fatalf("Failed to marshal request: %v", err)
 	}
 
-	err = s.Messenger.Publish(os.Getenv("QUEUE_MESSAGE"), jsonReq)
+	/*
+	   Com partições, a mensagem vai para a fila da partição da sessão,
+	   consumida apenas pela réplica do consumer dona dessa partição.
+	*/
+	queue := os.Getenv("QUEUE_MESSAGE")
+	if s.Partitions > 0 {
+		queue = core.PartitionQueue(queue, core.PartitionOf(req.SessionId, s.Partitions))
+	}
+
+	if req.SendAt != nil && req.SendAt.After(time.Now()) {
+		return s.schedule(ctx, message, queue, jsonReq, *req.SendAt)
+	}
+
+	envelope := messageEnvelope(message.ID, req.SessionId, jsonReq)
+
+	/*
+	   Com o outbox transacional, a mensagem é registrada e enfileirada na mesma transação:
+	   ou as duas escritas acontecem, ou nenhuma.
+	*/
+	if publisher, ok := s.Messenger.(core.TxPublisher); ok {
+		err = s.sendTx(ctx, publisher, message, queue, envelope)
+		if err == nil && registered != nil {
+			err = registered(ctx)
+		}
+		if err != nil {
+			return SendResponse{
+				Sent: false,
+			}, err
+		}
+	} else {
+		err = s.MessageRepository.CreateMessage(ctx, message)
+		if err == nil && registered != nil {
+			err = registered(ctx)
+		}
+		if err != nil {
+			return SendResponse{
+				Sent: false,
+			}, err
+		}
+
+		err = s.Messenger.Publish(ctx, queue, envelope)
+		if err != nil {
+			reason := err.Error()
+			_ = s.MessageRepository.UpdateMessageStatus(ctx, message.ID, MessageStatusFailed, time.Now(), &reason)
+			return SendResponse{
+				Sent: false,
+			}, err
+		}
+	}
+
+	return SendResponse{
+		Sent:   true,
+		ID:     message.ID,
+		Status: message.Status,
+	}, nil
+}
+
+/*
+Método schedule registra a mensagem agendada e o conteúdo a ser publicado.
+Com entrega agendada nativa no driver, a mensagem é publicada imediatamente para a data de envio;
+caso contrário, é publicada pelo MessageScheduler quando vencer.
+*/
+func (s WhatsAppService) schedule(ctx context.Context, message *MessageRecord, queue string, body []byte, at time.Time) (SendResponse, error) {
+	at = scheduleTime(at)
+	message.Status = MessageStatusScheduled
+	message.ScheduledAt = &at
+
+	err := s.MessageRepository.CreateScheduledMessage(ctx, message, queue, body)
 	if err != nil {
 		return SendResponse{
 			Sent: false,
 		}, err
 	}
 
+	publishScheduled(ctx, s.Messenger, s.MessageRepository, ScheduledMessage{
+		ID:        message.ID,
+		SessionID: message.SessionID,
+		Queue:     queue,
+		Body:      body,
+		SendAt:    at,
+	})
+
+	scheduledAt := at.Format(time.RFC3339)
 	return SendResponse{
-		Sent: true,
+		Sent:        true,
+		ID:          message.ID,
+		Status:      message.Status,
+		ScheduledAt: &scheduledAt,
+	}, nil
+}
+
+/*
+Método sendTx registra a mensagem e a publica no outbox na mesma transação.
+*/
+func (s WhatsAppService) sendTx(ctx context.Context, publisher core.TxPublisher, message *MessageRecord, queue string, envelope core.Envelope) error {
+	tx, err := s.MessageRepository.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.MessageRepository.CreateMessageTx(ctx, tx, message)
+	if err != nil {
+		return err
+	}
+
+	err = publisher.PublishTx(ctx, tx, queue, envelope)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/*
+Método validateSend valida a mensagem antes de enfileirá-la.
+Mídias referenciadas por mediaId precisam existir e respeitar o tipo e o tamanho aceitos.
+*/
+func (s WhatsAppService) validateSend(ctx context.Context, req SendRequest) error {
+	message := &Message{
+		SessionId: req.SessionId,
+		To:        req.To,
+		Message:   req.Message,
+		Type:      req.Type,
+		Caption:   req.Caption,
+		Media:     req.Media,
+	}
+	if err := validateMessage(message); err != nil {
+		return err
+	}
+
+	if req.Media == nil || req.Media.MediaID == "" {
+		return nil
+	}
+
+	media, err := s.MediaRepository.FindMediaInfoByID(ctx, req.Media.MediaID)
+	if err != nil {
+		return err
+	}
+
+	mimeType := req.Media.MimeType
+	if mimeType == "" {
+		mimeType = media.MimeType
+	}
+
+	return mediaKinds[req.Type].check(mimeType, int(media.Size))
+}
+
+/*
+Método storeMedia guarda o conteúdo de uma mídia em base64 e retorna a origem equivalente por mediaId.
+Com MediaRetention, a mídia expira MediaRetention depois de sendAt, ou de agora se sendAt for nulo ou passado;
+mensagens reagendadas ou reprocessadas depois disso falham com ErrMediaNotFound.
+*/
+func (s WhatsAppService) storeMedia(ctx context.Context, payload *MediaPayload, sendAt *time.Time) (*MediaPayload, error) {
+	data, err := decodeBase64(payload.Base64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid base64", ErrMediaInvalid)
+	}
+
+	media := &Media{
+		ID:       newID(),
+		MimeType: detectMimeType(payload.MimeType, data),
+		FileName: payload.FileName,
+		Size:     int64(len(data)),
+		Data:     data,
+	}
+	if s.MediaRetention > 0 {
+		expiresAt := time.Now()
+		if sendAt != nil && sendAt.After(expiresAt) {
+			expiresAt = *sendAt
+		}
+		expiresAt = expiresAt.Add(s.MediaRetention)
+		media.ExpiresAt = &expiresAt
+	}
+	err = s.MediaRepository.CreateMedia(ctx, media)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MediaPayload{
+		MediaID:  media.ID,
+		MimeType: payload.MimeType,
+		FileName: payload.FileName,
+	}, nil
+}
+
+/*
+Estrutura MediaService que contém o repositório MediaRepository.
+Esta estrutura é responsável por receber os arquivos usados nas mensagens de mídia.
+*/
+type MediaService struct {
+	MediaRepository MediaRepository
+}
+
+/*
+Método Upload guarda um arquivo de mídia para ser referenciado por mediaId no envio de mensagens.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- fileName: Nome original do arquivo.
+- mimeType: Tipo MIME informado; detectado pelo conteúdo quando vazio ou genérico.
+- data: Conteúdo do arquivo.
+Retorna:
+- Uma estrutura UploadMediaResponse com o ID da mídia e um erro, se houver.
+*/
+func (s MediaService) Upload(ctx context.Context, fileName string, mimeType string, data []byte) (res UploadMediaResponse, err error) {
+	if len(data) == 0 {
+		return UploadMediaResponse{}, fmt.Errorf("%w: file is empty", ErrMediaInvalid)
+	}
+	if len(data) > MaxMediaUploadSize {
+		return UploadMediaResponse{}, fmt.Errorf("%w: %d bytes, max %d", ErrMediaTooLarge, len(data), MaxMediaUploadSize)
+	}
+	if baseMimeType(mimeType) == "application/octet-stream" {
+		mimeType = ""
+	}
+
+	media := &Media{
+		ID:       newID(),
+		MimeType: detectMimeType(mimeType, data),
+		FileName: fileName,
+		Size:     int64(len(data)),
+		Data:     data,
+	}
+
+	if err = s.MediaRepository.CreateMedia(ctx, media); err != nil {
+		return UploadMediaResponse{}, err
+	}
+
+	return UploadMediaResponse{
+		ID:       media.ID,
+		MimeType: media.MimeType,
+		FileName: media.FileName,
+		Size:     media.Size,
 	}, nil
 }
+
+/*
+Estrutura MessageService que contém o repositório MessageRepository e o Messenger.
+Esta estrutura é responsável por consultar o status das mensagens enviadas e gerenciar as mensagens agendadas.
+*/
+type MessageService struct {
+	MessageRepository MessageRepository
+	Messenger         core.MessengerInterface
+}
+
+/*
+Método GetByID obtém uma mensagem pelo ID retornado em /send.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem.
+Retorna:
+- Uma estrutura GetMessageByIDResponse com o status da mensagem e um erro, se houver.
+*/
+func (s MessageService) GetByID(ctx context.Context, id string) (res GetMessageByIDResponse, err error) {
+	message, err := s.MessageRepository.FindMessageByID(ctx, id)
+	if err != nil {
+		return GetMessageByIDResponse{}, err
+	}
+
+	return message.ToResponse(), nil
+}
+
+/*
+Método ListScheduled lista as mensagens agendadas pendentes, pela data de envio.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListScheduledMessagesRequest com a sessão, opcional, e a paginação.
+Retorna:
+- Uma estrutura ListScheduledMessagesResponse e um erro, se houver.
+*/
+func (s MessageService) ListScheduled(ctx context.Context, req ListScheduledMessagesRequest) (res ListScheduledMessagesResponse, err error) {
+	req.Limit, req.Offset = normalizePagination(req.Limit, req.Offset)
+
+	messages, err := s.MessageRepository.ListScheduledMessages(ctx, req)
+	if err != nil {
+		return ListScheduledMessagesResponse{}, err
+	}
+
+	res = ListScheduledMessagesResponse{
+		Messages: make([]GetMessageByIDResponse, 0, len(messages)),
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	}
+	for _, message := range messages {
+		res.Messages = append(res.Messages, message.ToResponse())
+	}
+
+	return res, nil
+}
+
+/*
+Método Reschedule altera a data de envio de uma mensagem agendada pendente e a publica novamente.
+A publicação anterior, se houver, é descartada pelo consumer por não corresponder mais ao agendamento.
+Datas passadas enviam a mensagem assim que possível.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem.
+- req: Estrutura RescheduleMessageRequest com a nova data de envio.
+Retorna:
+- Uma estrutura GetMessageByIDResponse com a mensagem reagendada e um erro, se houver.
+*/
+func (s MessageService) Reschedule(ctx context.Context, id string, req RescheduleMessageRequest) (res GetMessageByIDResponse, err error) {
+	if req.SendAt == nil {
+		return GetMessageByIDResponse{}, ErrMessageSendAtRequired
+	}
+
+	scheduled, err := s.MessageRepository.RescheduleMessage(ctx, id, scheduleTime(*req.SendAt))
+	if err != nil {
+		return GetMessageByIDResponse{}, err
+	}
+
+	publishScheduled(ctx, s.Messenger, s.MessageRepository, *scheduled)
+
+	return s.GetByID(ctx, id)
+}
+
+/*
+Método Cancel cancela uma mensagem agendada pendente.
+A publicação anterior, se houver, é descartada pelo consumer.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem.
+Retorna:
+- ErrMessageInvalidTransition se a mensagem não estiver agendada, ou outro erro, se houver.
+*/
+func (s MessageService) Cancel(ctx context.Context, id string) (err error) {
+	return s.MessageRepository.CancelScheduledMessage(ctx, id)
+}
+
+/*
+Estrutura DeadLetterService que contém o Messenger e as filas de mensagens.
+Esta estrutura é responsável por inspecionar e reprocessar as mensagens que esgotaram as tentativas de envio.
+Com partições, Queues contém a fila de cada partição.
+Com OUTBOX_RELAY, Outbox é o outbox do relay: as mensagens que esgotaram as tentativas de encaminhamento ao Messenger
+ficam mortas no outbox e também são listadas e reprocessadas, com o prefixo "outbox:" no ID.
+*/
+type DeadLetterService struct {
+	Messenger core.MessengerInterface
+	Outbox    core.MessengerInterface
+	Queues    []string
+}
+
+/*
+Prefixo dos IDs das mensagens mortas do outbox do relay, que as distingue das mensagens mortas do Messenger.
+*/
+const outboxDeadLetterPrefix = "outbox:"
+
+/*
+Estrutura deadLetterSource representa um driver com fila de mensagens mortas e o prefixo dos IDs de suas mensagens.
+*/
+type deadLetterSource struct {
+	prefix      string
+	deadLetters core.DeadLetterInterface
+}
+
+/*
+Método sources retorna o Messenger e o Outbox que têm suporte à fila de mensagens mortas.
+Retorna ErrDeadLetterUnsupported se nenhum deles tiver suporte.
+*/
+func (s DeadLetterService) sources() ([]deadLetterSource, error) {
+	var sources []deadLetterSource
+	if deadLetters, ok := s.Messenger.(core.DeadLetterInterface); ok {
+		sources = append(sources, deadLetterSource{deadLetters: deadLetters})
+	}
+	if deadLetters, ok := s.Outbox.(core.DeadLetterInterface); ok {
+		sources = append(sources, deadLetterSource{prefix: outboxDeadLetterPrefix, deadLetters: deadLetters})
+	}
+
+	if len(sources) == 0 {
+		return nil, core.ErrDeadLetterUnsupported
+	}
+	return sources, nil
+}
+
+/*
+Método List lista as mensagens da fila de mensagens mortas.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListDeadLettersRequest com o limite de mensagens.
+Retorna:
+- Uma estrutura ListDeadLettersResponse com as mensagens e um erro, se houver.
+*/
+func (s DeadLetterService) List(ctx context.Context, req ListDeadLettersRequest) (res ListDeadLettersResponse, err error) {
+	sources, err := s.sources()
+	if err != nil {
+		return ListDeadLettersResponse{}, err
+	}
+
+	limit, _ := normalizePagination(req.Limit, 0)
+	res.Data = []DeadLetterResponse{}
+	for _, source := range sources {
+		for _, queue := range s.Queues {
+			if len(res.Data) >= limit {
+				return res, nil
+			}
+
+			entries, err := source.deadLetters.ListDeadLetters(queue, limit-len(res.Data))
+			if err != nil {
+				return ListDeadLettersResponse{}, err
+			}
+
+			for _, entry := range entries {
+				item := DeadLetterResponse{
+					ID:        source.prefix + entry.ID,
+					Queue:     entry.Queue,
+					MessageID: entry.MessageID,
+					Headers:   entry.Headers,
+					Body:      string(entry.Body),
+					Attempt:   entry.Attempt,
+					Reason:    entry.Reason,
+				}
+				if !entry.FailedAt.IsZero() {
+					item.FailedAt = entry.FailedAt.Format(time.RFC3339)
+				}
+				res.Data = append(res.Data, item)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+/*
+Método Replay devolve a mensagem morta à fila de mensagens, reiniciando as tentativas.
+Mensagens mortas do outbox do relay voltam ao outbox e são encaminhadas de novo ao Messenger.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da mensagem morta.
+Retorna:
+- core.ErrDeadLetterScanLimit se a mensagem não foi encontrada e alguma busca atingiu o limite do driver,
+ou outro erro, se houver.
+*/
+func (s DeadLetterService) Replay(ctx context.Context, id string) (err error) {
+	sources, err := s.sources()
+	if err != nil {
+		return err
+	}
+
+	notFound := core.ErrDeadLetterNotFound
+	for _, source := range sources {
+		entryID, ok := strings.CutPrefix(id, source.prefix)
+		if !ok || (source.prefix == "" && strings.HasPrefix(id, outboxDeadLetterPrefix)) {
+			continue
+		}
+
+		/*
+		   A mensagem é procurada em cada fila, pois o identificador não indica a partição.
+		   Uma fila cuja busca atingiu o limite não impede a busca nas demais.
+		*/
+		for _, queue := range s.Queues {
+			err = source.deadLetters.ReplayDeadLetter(queue, entryID)
+			if errors.Is(err, core.ErrDeadLetterScanLimit) {
+				notFound = err
+				continue
+			}
+			if !errors.Is(err, core.ErrDeadLetterNotFound) {
+				return err
+			}
+		}
+	}
+
+	return notFound
+}
+
+/*
+Limites de paginação usados nas listagens.
+*/
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+/*
+Função normalizePagination aplica os limites padrão de paginação.
+*/
+func normalizePagination(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+/*
+Estrutura AccountService que contém o repositório AccountRepository.
+Esta estrutura é responsável por fornecer as funcionalidades de gerenciamento de contas.
+*/
+type AccountService struct {
+	AccountRepository  AccountRepository
+	WebhookSecretGrace time.Duration
+}
+
+/*
+Método Create cria uma nova conta.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura CreateAccountRequest com os dados da conta.
+Retorna:
+- Uma estrutura CreateAccountResponse com o ID da conta criada e o segredo dos webhooks e um erro, se houver.
+*/
+func (s AccountService) Create(ctx context.Context, req CreateAccountRequest) (res CreateAccountResponse, err error) {
+	account := &Account{
+		Name:          req.Name,
+		Origin:        req.Origin,
+		ExternalId:    req.ExternalId,
+		WebhookURL:    req.WebhookURL,
+		WebhookSecret: NewWebhookSecret(),
+	}
+	if err = account.Validate(); err != nil {
+		return CreateAccountResponse{}, err
+	}
+
+	if err = s.AccountRepository.CreateAccount(ctx, account); err != nil {
+		return CreateAccountResponse{}, err
+	}
+
+	return CreateAccountResponse{
+		ID:            account.ID,
+		WebhookSecret: account.WebhookSecret,
+	}, nil
+}
+
+/*
+Método GetByID retorna uma conta pelo ID.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da conta.
+Retorna:
+- Uma estrutura GetAccountByIDResponse e um erro, se houver.
+*/
+func (s AccountService) GetByID(ctx context.Context, id string) (res GetAccountByIDResponse, err error) {
+	account, err := s.AccountRepository.FindAccountByID(ctx, id)
+	if err != nil {
+		return GetAccountByIDResponse{}, err
+	}
+
+	return account.ToResponse(), nil
+}
+
+/*
+Método List lista as contas de acordo com os filtros informados.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListAccountsRequest com os filtros e a paginação.
+Retorna:
+- Uma estrutura ListAccountsResponse e um erro, se houver.
+*/
+func (s AccountService) List(ctx context.Context, req ListAccountsRequest) (res ListAccountsResponse, err error) {
+	req.Limit, req.Offset = normalizePagination(req.Limit, req.Offset)
+
+	accounts, err := s.AccountRepository.ListAccounts(ctx, req)
+	if err != nil {
+		return ListAccountsResponse{}, err
+	}
+
+	res = ListAccountsResponse{
+		Accounts: make([]GetAccountByIDResponse, 0, len(accounts)),
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	}
+	for _, account := range accounts {
+		res.Accounts = append(res.Accounts, account.ToResponse())
+	}
+
+	return res, nil
+}
+
+/*
+Método Update atualiza os dados de uma conta.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da conta.
+- req: Estrutura UpdateAccountRequest com os novos dados.
+Retorna:
+- Uma estrutura GetAccountByIDResponse com a conta atualizada e um erro, se houver.
+*/
+func (s AccountService) Update(ctx context.Context, id string, req UpdateAccountRequest) (res GetAccountByIDResponse, err error) {
+	account := &Account{
+		ID:         id,
+		Name:       req.Name,
+		Origin:     req.Origin,
+		ExternalId: req.ExternalId,
+		WebhookURL: req.WebhookURL,
+	}
+	if err = account.Validate(); err != nil {
+		return GetAccountByIDResponse{}, err
+	}
+
+	if err = s.AccountRepository.UpdateAccount(ctx, account); err != nil {
+		return GetAccountByIDResponse{}, err
+	}
+
+	return account.ToResponse(), nil
+}
+
+/*
+Método RotateWebhookSecret gera um novo segredo de webhook para a conta.
+O segredo anterior continua sendo usado nas assinaturas por WebhookSecretGrace,
+dando tempo para o receptor atualizar sua configuração.
+Uma nova troca só é aceita depois que o segredo anterior expira, para que nenhum segredo seja descartado
+antes do fim do seu prazo; até lá, retorna ErrAccountRotationGrace.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da conta.
+Retorna:
+- Uma estrutura RotateWebhookSecretResponse com o novo segredo e um erro, se houver.
+*/
+func (s AccountService) RotateWebhookSecret(ctx context.Context, id string) (res RotateWebhookSecretResponse, err error) {
+	expiresAt := time.Now().Add(s.WebhookSecretGrace).UTC()
+	account := &Account{
+		ID:                             id,
+		WebhookSecret:                  NewWebhookSecret(),
+		WebhookPreviousSecretExpiresAt: &expiresAt,
+	}
+
+	if err = s.AccountRepository.RotateWebhookSecret(ctx, account); err != nil {
+		return RotateWebhookSecretResponse{}, err
+	}
+
+	return RotateWebhookSecretResponse{
+		WebhookSecret:           account.WebhookSecret,
+		PreviousSecretExpiresAt: expiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+/*
+Método Delete remove uma conta.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da conta.
+Retorna:
+- Um erro, se houver.
+*/
+func (s AccountService) Delete(ctx context.Context, id string) (err error) {
+	return s.AccountRepository.DeleteAccount(ctx, id)
+}
+
+/*
+Estrutura SessionService que contém os repositórios de sessões e contas e o serviço WhatsAppService.
+Esta estrutura é responsável por criar e consultar as sessões WhatsApp das contas.
+*/
+type SessionService struct {
+	SessionRepository SessionRepository
+	AccountRepository AccountRepository
+	WhatsAppService   WhatsAppService
+}
+
+/*
+Método Create cria uma nova sessão para a conta e inicia o pareamento por código QR ou por número de telefone.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura CreateSessionRequest com a conta da sessão.
+Retorna:
+- Uma estrutura CreateSessionResponse com o código QR ou de pareamento e o ID da sessão e um erro, se houver.
+*/
+func (s SessionService) Create(ctx context.Context, req CreateSessionRequest) (res CreateSessionResponse, err error) {
+	if req.AccountID == "" {
+		return CreateSessionResponse{}, ErrSessionAccountRequired
+	}
+
+	account, err := s.AccountRepository.FindAccountByID(ctx, req.AccountID)
+	if err != nil {
+		return CreateSessionResponse{}, err
+	}
+
+	/*
+	   Quando o número de telefone é informado, o pareamento é feito por código em vez de QR.
+	*/
+	var connectRes ConnectResponse
+	if req.Phone != "" {
+		connectRes, err = s.WhatsAppService.PairPhone(ctx, &account.ID, req.Phone)
+	} else {
+		connectRes, err = s.WhatsAppService.PairQR(ctx, &account.ID)
+	}
+	if err != nil {
+		return CreateSessionResponse{}, err
+	}
+
+	return CreateSessionResponse{
+		AuthCode:  connectRes.AuthCode,
+		SessionID: connectRes.SessionID,
+	}, nil
+}
+
+/*
+Método GetByID retorna uma sessão pelo ID.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da sessão.
+Retorna:
+- Uma estrutura GetSessionByIDResponse e um erro, se houver.
+*/
+func (s SessionService) GetByID(ctx context.Context, id string) (res GetSessionByIDResponse, err error) {
+	session, err := s.SessionRepository.FindSessionByID(ctx, id)
+	if err != nil {
+		return GetSessionByIDResponse{}, err
+	}
+
+	return session.ToResponse(), nil
+}
+
+/*
+Método List lista as sessões de acordo com os filtros informados.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListSessionsRequest com os filtros e a paginação.
+Retorna:
+- Uma estrutura ListSessionsResponse e um erro, se houver.
+*/
+func (s SessionService) List(ctx context.Context, req ListSessionsRequest) (res ListSessionsResponse, err error) {
+	req.Limit, req.Offset = normalizePagination(req.Limit, req.Offset)
+
+	sessions, err := s.SessionRepository.ListSessions(ctx, req)
+	if err != nil {
+		return ListSessionsResponse{}, err
+	}
+
+	res = ListSessionsResponse{
+		Sessions: make([]GetSessionByIDResponse, 0, len(sessions)),
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	}
+	for _, session := range sessions {
+		res.Sessions = append(res.Sessions, session.ToResponse())
+	}
+
+	return res, nil
+}
+
+/*
+Método SubscribePairing assina os eventos do pareamento em andamento da sessão.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da sessão.
+Retorna:
+- O canal de eventos, a função que cancela a assinatura e um erro, se houver.
+*/
+func (s SessionService) SubscribePairing(ctx context.Context, id string) (<-chan PairingEvent, func(), error) {
+	if _, err := s.SessionRepository.FindSessionByID(ctx, id); err != nil {
+		return nil, nil, err
+	}
+
+	return s.WhatsAppService.Pairings.Subscribe(id)
+}
+
+/*
+Método Logout desconecta a sessão do WhatsApp e a marca como logged_out.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da sessão.
+Retorna:
+- Um erro, se houver.
+*/
+func (s SessionService) Logout(ctx context.Context, id string) (err error) {
+	return s.WhatsAppService.Logout(ctx, id)
+}
+
+/*
+Estrutura CampaignService que contém o repositório CampaignRepository e o serviço WhatsAppService.
+Esta estrutura é responsável pelo ciclo de vida das campanhas; o envio é feito pelo CampaignRunner.
+*/
+type CampaignService struct {
+	CampaignRepository CampaignRepository
+	WhatsAppService    WhatsAppService
+}
+
+/*
+Método Create cria uma campanha em andamento, enviada a partir do próximo ciclo do CampaignRunner.
+O modelo é validado com o primeiro destinatário, como uma mensagem de POST /send.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura CreateCampaignRequest com a sessão, o modelo e os destinatários.
+Retorna:
+- Uma estrutura GetCampaignByIDResponse com a campanha criada e um erro, se houver.
+*/
+func (s CampaignService) Create(ctx context.Context, req CreateCampaignRequest) (res GetCampaignByIDResponse, err error) {
+	if req.SessionID == "" || len(req.Recipients) == 0 {
+		return GetCampaignByIDResponse{}, ErrCampaignInvalid
+	}
+	if len(req.Recipients) > MaxCampaignRecipients {
+		return GetCampaignByIDResponse{}, ErrCampaignTooLarge
+	}
+	for i, recipient := range req.Recipients {
+		if recipient.To == "" {
+			return GetCampaignByIDResponse{}, fmt.Errorf("%w: recipient %d has no \"to\"", ErrCampaignInvalid, i+1)
+		}
+	}
+
+	/*
+	   A mídia do modelo é enviada a todos os destinatários e precisa ser enviada antes em POST /media;
+	   mídias em base64 ou por url seriam copiadas ou baixadas em cada envio.
+	*/
+	if media := req.Template.Media; media != nil && (media.MediaID == "" || media.Base64 != "" || media.URL != "") {
+		return GetCampaignByIDResponse{}, fmt.Errorf("%w: template media must use mediaId", ErrCampaignInvalid)
+	}
+
+	_, err = s.WhatsAppService.SessionRepository.FindSessionByID(ctx, req.SessionID)
+	if err != nil {
+		return GetCampaignByIDResponse{}, err
+	}
+
+	first := CampaignRecipient{
+		To:        req.Recipients[0].To,
+		Variables: req.Recipients[0].Variables,
+	}
+	err = s.WhatsAppService.validateSend(ctx, req.Template.SendRequest(req.SessionID, first))
+	if err != nil {
+		return GetCampaignByIDResponse{}, err
+	}
+
+	campaign := &Campaign{
+		ID:        newID(),
+		SessionID: req.SessionID,
+		Name:      req.Name,
+		Template:  req.Template,
+		Status:    CampaignStatusRunning,
+	}
+	err = s.CampaignRepository.CreateCampaign(ctx, campaign, req.Recipients)
+	if err != nil {
+		return GetCampaignByIDResponse{}, err
+	}
+
+	return campaign.ToResponse(map[string]int{CampaignRecipientPending: len(req.Recipients)}), nil
+}
+
+/*
+Método GetByID retorna uma campanha pelo ID, com o progresso do envio.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- id: Identificador da campanha.
+Retorna:
+- Uma estrutura GetCampaignByIDResponse e um erro, se houver.
+*/
+func (s CampaignService) GetByID(ctx context.Context, id string) (res GetCampaignByIDResponse, err error) {
+	campaign, err := s.CampaignRepository.FindCampaignByID(ctx, id)
+	if err != nil {
+		return GetCampaignByIDResponse{}, err
+	}
+
+	progress, err := s.CampaignRepository.CampaignProgress(ctx, []string{id})
+	if err != nil {
+		return GetCampaignByIDResponse{}, err
+	}
+
+	return campaign.ToResponse(progress[id]), nil
+}
+
+/*
+Método List lista as campanhas de acordo com os filtros informados, com o progresso de cada uma.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListCampaignsRequest com os filtros e a paginação.
+Retorna:
+- Uma estrutura ListCampaignsResponse e um erro, se houver.
+*/
+func (s CampaignService) List(ctx context.Context, req ListCampaignsRequest) (res ListCampaignsResponse, err error) {
+	req.Limit, req.Offset = normalizePagination(req.Limit, req.Offset)
+
+	campaigns, err := s.CampaignRepository.ListCampaigns(ctx, req)
+	if err != nil {
+		return ListCampaignsResponse{}, err
+	}
+
+	ids := make([]string, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		ids = append(ids, campaign.ID)
+	}
+	progress, err := s.CampaignRepository.CampaignProgress(ctx, ids)
+	if err != nil {
+		return ListCampaignsResponse{}, err
+	}
+
+	res = ListCampaignsResponse{
+		Campaigns: make([]GetCampaignByIDResponse, 0, len(campaigns)),
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+	}
+	for _, campaign := range campaigns {
+		res.Campaigns = append(res.Campaigns, campaign.ToResponse(progress[campaign.ID]))
+	}
+
+	return res, nil
+}
+
+/*
+Método ListRecipients lista os destinatários da campanha com o status de cada um.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+- req: Estrutura ListCampaignRecipientsRequest com a campanha, o filtro de status e a paginação.
+Retorna:
+- Uma estrutura ListCampaignRecipientsResponse e um erro, se houver.
+*/
+func (s CampaignService) ListRecipients(ctx context.Context, req ListCampaignRecipientsRequest) (res ListCampaignRecipientsResponse, err error) {
+	req.Limit, req.Offset = normalizePagination(req.Limit, req.Offset)
+
+	_, err = s.CampaignRepository.FindCampaignByID(ctx, req.CampaignID)
+	if err != nil {
+		return ListCampaignRecipientsResponse{}, err
+	}
+
+	recipients, err := s.CampaignRepository.ListCampaignRecipients(ctx, req)
+	if err != nil {
+		return ListCampaignRecipientsResponse{}, err
+	}
+
+	res = ListCampaignRecipientsResponse{
+		Recipients: make([]CampaignRecipientResponse, 0, len(recipients)),
+		Limit:      req.Limit,
+		Offset:     req.Offset,
+	}
+	for _, recipient := range recipients {
+		res.Recipients = append(res.Recipients, recipient.ToResponse())
+	}
+
+	return res, nil
+}
+
+/*
+Método Pause pausa o envio da campanha a partir do próximo lote de destinatários.
+*/
+func (s CampaignService) Pause(ctx context.Context, id string) (res GetCampaignByIDResponse, err error) {
+	return s.updateStatus(ctx, id, CampaignStatusPaused)
+}
+
+/*
+Método Resume retoma o envio de uma campanha pausada.
+*/
+func (s CampaignService) Resume(ctx context.Context, id string) (res GetCampaignByIDResponse, err error) {
+	return s.updateStatus(ctx, id, CampaignStatusRunning)
+}
+
+/*
+Método Cancel cancela a campanha e seus destinatários pendentes.
+As mensagens já enfileiradas não são canceladas.
+*/
+func (s CampaignService) Cancel(ctx context.Context, id string) (res GetCampaignByIDResponse, err error) {
+	return s.updateStatus(ctx, id, CampaignStatusCancelled)
+}
+
+/*
+Método updateStatus altera o status da campanha e retorna a campanha atualizada.
+Retorna ErrCampaignNotFound se a campanha não existir ou ErrCampaignInvalidTransition
+se o status atual não permitir a mudança.
+*/
+func (s CampaignService) updateStatus(ctx context.Context, id string, status string) (GetCampaignByIDResponse, error) {
+	_, err := s.CampaignRepository.FindCampaignByID(ctx, id)
+	if err != nil {
+		return GetCampaignByIDResponse{}, err
+	}
+
+	err = s.CampaignRepository.UpdateCampaignStatus(ctx, id, status)
+	if err != nil {
+		return GetCampaignByIDResponse{}, err
+	}
+
+	return s.GetByID(ctx, id)
+}
+
+/*
+Estrutura HealthService que contém o Messenger e as conexões com o Postgres e o Redis.
+Esta estrutura é responsável por verificar as dependências da aplicação.
+Redis é opcional, pois a API só o usa no modo de binário único.
+*/
+type HealthService struct {
+	Messenger core.MessengerInterface
+	Postgres  *sql.DB
+	Redis     *core.RedisClient
+}
+
+/*
+Método Check verifica a conexão com o driver de mensageria, o Postgres e o Redis.
+Drivers que não implementam HealthInterface são considerados disponíveis.
+Parâmetros:
+- ctx: Contexto para controle de cancelamento e prazos.
+Retorna:
+- Uma estrutura HealthResponse com o resultado de cada verificação e true se todas passarem.
+*/
+func (s HealthService) Check(ctx context.Context) (res HealthResponse, healthy bool) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	checks := map[string]error{}
+	if health, ok := s.Messenger.(core.HealthInterface); ok {
+		checks["messenger"] = health.Health()
+	} else {
+		checks["messenger"] = nil
+	}
+	if s.Postgres != nil {
+		checks["postgres"] = s.Postgres.PingContext(ctx)
+	}
+	if s.Redis != nil {
+		checks["redis"] = s.Redis.Ping(ctx)
+	}
+
+	healthy = true
+	res.Checks = make(map[string]string, len(checks))
+	for name, err := range checks {
+		if err != nil {
+			healthy = false
+			res.Checks[name] = err.Error()
+			continue
+		}
+		res.Checks[name] = "ok"
+	}
+
+	res.Status = "ok"
+	if !healthy {
+		res.Status = "unavailable"
+	}
+	return res, healthy
+}
@@ -0,0 +1,193 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+/*
+Definição de variáveis de erro específicas para operações com sessões.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrSessionNotFound          = errors.New("session.not_found: session not found")
+	ErrSessionAccountRequired   = errors.New("session.account_required: accountId is required")
+	ErrSessionInvalidTransition = errors.New("session.invalid_transition: session status does not allow this change")
+	ErrSessionPairingFailed     = errors.New("session.pairing_failed: failed to start pairing")
+	ErrSessionPhoneInvalid      = errors.New("session.phone_invalid: phone must be in international format, with country code and without leading zero")
+)
+
+/*
+Status possíveis de uma sessão.
+O ciclo de vida segue: pending_qr (ou pending_code, no pareamento por telefone) → paired → ready → logged_out/failed.
+*/
+const (
+	SessionStatusPendingQR   = "pending_qr"
+	SessionStatusPendingCode = "pending_code"
+	SessionStatusPaired      = "paired"
+	SessionStatusReady       = "ready"
+	SessionStatusLoggedOut   = "logged_out"
+	SessionStatusFailed      = "failed"
+)
+
+/*
+Mapa sessionTransitions define, para cada status de destino, os status de origem permitidos.
+O status logged_out é final; uma sessão failed ainda pode ser desconectada, para remover o dispositivo.
+*/
+var sessionTransitions = map[string][]string{
+	SessionStatusPaired:    {SessionStatusPendingQR, SessionStatusPendingCode},
+	SessionStatusReady:     {SessionStatusPaired, SessionStatusReady},
+	SessionStatusLoggedOut: {SessionStatusPendingQR, SessionStatusPendingCode, SessionStatusPaired, SessionStatusReady, SessionStatusFailed},
+	SessionStatusFailed:    {SessionStatusPendingQR, SessionStatusPendingCode, SessionStatusPaired, SessionStatusReady},
+}
+
+/*
+Estrutura Session representa uma sessão WhatsApp (um aparelho pareado) vinculada a uma conta.
+O ID da sessão é o registration_id do dispositivo no banco de dados WhatsMeow.
+*/
+type Session struct {
+	ID            string
+	AccountID     *string
+	Status        string
+	AuthCode      string
+	JID           *string
+	PushName      *string
+	Platform      *string
+	ReadyAt       *time.Time
+	FailureReason *string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+/*
+Estrutura SessionUpdate contém os campos opcionais gravados junto com uma mudança de status.
+Campos nulos mantêm o valor atual.
+*/
+type SessionUpdate struct {
+	JID           *string
+	PushName      *string
+	Platform      *string
+	FailureReason *string
+}
+
+/*
+Método ToResponse converte a sessão na estrutura de resposta da API.
+*/
+func (s *Session) ToResponse() GetSessionByIDResponse {
+	res := GetSessionByIDResponse{
+		ID:            s.ID,
+		Status:        s.Status,
+		AuthCode:      s.AuthCode,
+		FailureReason: s.FailureReason,
+		CreatedAt:     s.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     s.UpdatedAt.Format(time.RFC3339),
+	}
+
+	if s.AccountID != nil {
+		res.AccountID = *s.AccountID
+	}
+
+	if s.ReadyAt != nil {
+		readyAt := s.ReadyAt.Format(time.RFC3339)
+		res.ReadyAt = &readyAt
+	}
+
+	if s.JID != nil || s.PushName != nil || s.Platform != nil {
+		res.SessionInfo = &SessionInfo{
+			Name:     s.PushName,
+			Platform: s.Platform,
+		}
+		if s.JID != nil {
+			if jid, err := types.ParseJID(*s.JID); err == nil {
+				phoneID := jid.User
+				phoneSerialized := jid.ToNonAD().String()
+				res.SessionInfo.PhoneID = &phoneID
+				res.SessionInfo.PhoneSerialized = &phoneSerialized
+			}
+		}
+	}
+
+	return res
+}
+
+/*
+Tipo SessionEventHandler representa uma função que recebe os eventos whatsmeow de uma sessão.
+*/
+type SessionEventHandler func(sessionID string, client *whatsmeow.Client, evt interface{})
+
+/*
+Estrutura SessionStateHandler atualiza o status persistido da sessão a partir dos eventos whatsmeow.
+É usada tanto pelo cliente de pareamento da API quanto pelos clientes do consumer.
+*/
+type SessionStateHandler struct {
+	SessionRepository SessionRepository
+}
+
+/*
+Método HandleEvent aplica a transição de status correspondente ao evento recebido.
+- PairSuccess: paired, registrando o JID e a plataforma do aparelho.
+- PairError: failed, registrando o motivo.
+- Connected: ready, registrando o nome do perfil.
+- LoggedOut: logged_out.
+Parâmetros:
+- sessionID: Identificador da sessão.
+- client: Cliente whatsmeow que emitiu o evento.
+- evt: Evento recebido.
+*/
+func (h SessionStateHandler) HandleEvent(sessionID string, client *whatsmeow.Client, evt interface{}) {
+	var (
+		status string
+		update SessionUpdate
+	)
+
+	switch evt := evt.(type) {
+	case *events.PairSuccess:
+		jid := evt.ID.String()
+		status = SessionStatusPaired
+		update.JID = &jid
+		update.Platform = &evt.Platform
+	case *events.PairError:
+		reason := evt.Error.Error()
+		status = SessionStatusFailed
+		update.FailureReason = &reason
+	case *events.Connected:
+		status = SessionStatusReady
+		if client.Store.PushName != "" {
+			update.PushName = &client.Store.PushName
+		}
+		if client.Store.ID != nil {
+			jid := client.Store.ID.String()
+			update.JID = &jid
+		}
+	case *events.LoggedOut:
+		status = SessionStatusLoggedOut
+	default:
+		return
+	}
+
+	err := h.SessionRepository.UpdateSessionStatus(context.Background(), sessionID, status, update)
+	if err != nil {
+		log.Printf("Error updating session %s to %s: %v", sessionID, status, err)
+	}
+}
+
+/*
+Método Fail marca a sessão como failed com o motivo informado.
+Parâmetros:
+- sessionID: Identificador da sessão.
+- reason: Motivo da falha.
+*/
+func (h SessionStateHandler) Fail(sessionID string, reason string) {
+	err := h.SessionRepository.UpdateSessionStatus(context.Background(), sessionID, SessionStatusFailed, SessionUpdate{
+		FailureReason: &reason,
+	})
+	if err != nil {
+		log.Printf("Error updating session %s to %s: %v", sessionID, SessionStatusFailed, err)
+	}
+}
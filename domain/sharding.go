@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"gozap/core"
+)
+
+/*
+Estrutura SessionSharding divide as sessões entre as réplicas ativas do consumer.
+Cada sessão pertence a uma partição, e cada partição à réplica escolhida por rendezvous hashing
+entre os membros ativos. As mensagens de uma sessão são publicadas na fila da sua partição,
+consumida somente pela réplica dona, que também mantém a conexão do aparelho.
+*/
+type SessionSharding struct {
+	Membership *core.Membership
+	Partitions int
+}
+
+/*
+Método Owns indica se a sessão pertence a esta réplica.
+Sem partições, todas as sessões pertencem a todas as réplicas.
+Parâmetros:
+- sessionID: Identificador da sessão.
+*/
+func (s *SessionSharding) Owns(sessionID string) bool {
+	if s == nil || s.Partitions <= 0 {
+		return true
+	}
+
+	partition := core.PartitionOf(sessionID, s.Partitions)
+	return core.PartitionOwner(partition, s.Membership.Members()) == s.Membership.InstanceID
+}
+
+/*
+Método OwnedPartitions retorna as partições que pertencem a esta réplica, segundo os membros ativos.
+*/
+func (s *SessionSharding) OwnedPartitions() []int {
+	return core.OwnedPartitions(s.Membership.InstanceID, s.Membership.Members(), s.Partitions)
+}
@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Definição de variáveis de erro específicas para a assinatura dos webhooks.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrWebhookSignatureMissing = errors.New("webhook.signature_missing: signature header is missing or malformed")
+	ErrWebhookSignatureExpired = errors.New("webhook.signature_expired: signature timestamp is outside the tolerance")
+	ErrWebhookSignatureInvalid = errors.New("webhook.signature_invalid: no signature matches the payload")
+)
+
+/*
+Nome do cabeçalho HTTP com a assinatura dos webhooks e prefixo dos segredos gerados.
+O cabeçalho tem o formato "t=<unix>,v1=<hex>[,v1=<hex>]", com uma assinatura por segredo ativo.
+*/
+const (
+	WebhookSignatureHeader = "X-Gozap-Signature"
+	webhookSecretPrefix    = "whsec_"
+)
+
+/*
+Função NewWebhookSecret gera um novo segredo aleatório para assinar os webhooks de uma conta.
+Retorna:
+- O segredo com o prefixo "whsec_".
+*/
+func NewWebhookSecret() string {
+	return webhookSecretPrefix + newID() + newID()
+}
+
+/*
+Função SignWebhookPayload calcula a assinatura HMAC-SHA256 de "<timestamp>.<corpo>" com o segredo informado.
+Parâmetros:
+- secret: Segredo da conta.
+- timestamp: Momento da assinatura, em segundos Unix.
+- body: Corpo da requisição.
+Retorna:
+- A assinatura em hexadecimal.
+*/
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+Função WebhookSignatureHeaderValue monta o valor do cabeçalho de assinatura com uma assinatura por segredo.
+Durante a rotação, a conta possui dois segredos ativos e o receptor pode validar com qualquer um deles.
+Parâmetros:
+- secrets: Segredos ativos da conta.
+- timestamp: Momento da assinatura.
+- body: Corpo da requisição.
+Retorna:
+- O valor do cabeçalho no formato "t=<unix>,v1=<hex>[,v1=<hex>]".
+*/
+func WebhookSignatureHeaderValue(secrets []string, timestamp time.Time, body []byte) string {
+	unix := timestamp.Unix()
+	parts := []string{fmt.Sprintf("t=%d", unix)}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+SignWebhookPayload(secret, unix, body))
+	}
+	return strings.Join(parts, ",")
+}
+
+/*
+Função VerifyWebhookSignature valida o cabeçalho de assinatura de um webhook recebido.
+Deve ser usada pelos serviços que recebem os webhooks, com o segredo da conta.
+Parâmetros:
+- header: Valor do cabeçalho X-Gozap-Signature.
+- body: Corpo bruto da requisição, sem alterações.
+- secret: Segredo da conta.
+- tolerance: Diferença máxima aceita entre o timestamp assinado e o relógio local; zero desativa a verificação.
+Retorna:
+- Nil se alguma assinatura do cabeçalho corresponder ao corpo, ou o erro de validação.
+*/
+func VerifyWebhookSignature(header string, body []byte, secret string, tolerance time.Duration) error {
+	var (
+		timestamp  int64
+		signatures []string
+		err        error
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrWebhookSignatureMissing
+			}
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrWebhookSignatureMissing
+	}
+
+	if tolerance > 0 {
+		age := time.Since(time.Unix(timestamp, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrWebhookSignatureExpired
+		}
+	}
+
+	expected := SignWebhookPayload(secret, timestamp, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return ErrWebhookSignatureInvalid
+}
@@ -0,0 +1,341 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gozap/core"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+Definição de variáveis de erro específicas para a entrega de webhooks.
+Essas variáveis são usadas para fornecer mensagens de erro detalhadas.
+*/
+var (
+	ErrWebhookQueueFull = errors.New("webhook.queue_full: webhook queue is full, event dropped")
+	ErrWebhookClosed    = errors.New("webhook.closed: webhook dispatcher is closed")
+	ErrWebhookRejected  = errors.New("webhook.rejected: webhook endpoint rejected the event")
+)
+
+/*
+Limites da entrega de webhooks: tamanho da fila em memória, prazo de cada requisição e das escritas no Postgres,
+intervalo e lote da leitura das entregas a repetir e duração da reserva de cada uma.
+*/
+const (
+	webhookQueueSize      = 1024
+	webhookRequestTimeout = 10 * time.Second
+	webhookStoreTimeout   = 5 * time.Second
+	webhookRetryInterval  = time.Second
+	webhookRetryBatch     = 100
+	webhookRetryLease     = 5 * time.Minute
+)
+
+/*
+Estrutura EventEnvelope representa um evento enviado aos webhooks das contas.
+Campos:
+- ID: Identificador único da entrega, repetido nas novas tentativas.
+- Type: Tipo do evento (message, receipt, presence, chat_presence).
+- SessionID: Sessão que recebeu o evento.
+- AccountID: Conta dona da sessão.
+- Timestamp: Momento em que o evento foi recebido.
+- Data: Conteúdo normalizado do evento.
+*/
+type EventEnvelope struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	SessionID string      `json:"sessionId"`
+	AccountID string      `json:"accountId,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+/*
+Estrutura WebhookDispatcher entrega os eventos às URLs de webhook das contas.
+Os eventos são enfileirados em memória e enviados por workers, com novas tentativas
+e espera exponencial quando o endpoint falha ou está indisponível.
+As entregas a repetir são guardadas no Postgres e voltam à fila quando a espera vence, sem ocupar os workers;
+eventos que não cabem na fila e os que ainda estão nela no Close também são guardados, para não serem perdidos.
+Cada tentativa é assinada com os segredos ativos da conta no cabeçalho X-Gozap-Signature.
+*/
+type WebhookDispatcher struct {
+	AccountRepository AccountRepository
+	RetryRepository   WebhookRetryRepository
+	HTTPClient        *http.Client
+	RetryPolicy       core.RetryPolicy
+
+	queue chan webhookDelivery
+	done  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+/*
+Estrutura webhookDelivery representa um evento na fila de entrega.
+Campos:
+- retry: Evento e quantidade de tentativas já feitas.
+- persisted: Indica se a entrega está guardada em webhook_retries e deve ser removida ao terminar.
+*/
+type webhookDelivery struct {
+	retry     WebhookRetry
+	persisted bool
+}
+
+/*
+Função NewWebhookDispatcher cria uma nova instância do WebhookDispatcher e inicia os workers
+e a leitura das entregas a repetir.
+Parâmetros:
+- accountRepository: Repositório usado para encontrar a URL de webhook da conta da sessão.
+- retryRepository: Repositório das entregas a repetir.
+- workers: Quantidade de entregas simultâneas.
+- retryPolicy: Política de novas tentativas de cada entrega.
+Retorna:
+- Um ponteiro para a estrutura WebhookDispatcher.
+*/
+func NewWebhookDispatcher(accountRepository AccountRepository, retryRepository WebhookRetryRepository, workers int, retryPolicy core.RetryPolicy) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		AccountRepository: accountRepository,
+		RetryRepository:   retryRepository,
+		HTTPClient:        &http.Client{Timeout: webhookRequestTimeout},
+		RetryPolicy:       retryPolicy,
+		queue:             make(chan webhookDelivery, webhookQueueSize),
+		done:              make(chan struct{}),
+	}
+
+	for i := 0; i < max(workers, 1); i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+
+	d.wg.Add(1)
+	go d.poll()
+
+	return d
+}
+
+/*
+Método Dispatch enfileira o evento para entrega sem bloquear quem o recebeu.
+Com a fila cheia, o evento é guardado no Postgres e entregue assim que houver espaço.
+Parâmetros:
+- envelope: Evento a ser entregue.
+Retorna:
+- ErrWebhookQueueFull se a fila estiver cheia e o evento não puder ser guardado, ou ErrWebhookClosed após Close.
+*/
+func (d *WebhookDispatcher) Dispatch(envelope EventEnvelope) error {
+	select {
+	case <-d.done:
+		return ErrWebhookClosed
+	default:
+	}
+
+	select {
+	case d.queue <- webhookDelivery{retry: WebhookRetry{Envelope: envelope}}:
+		return nil
+	default:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookStoreTimeout)
+	defer cancel()
+
+	err := d.RetryRepository.SaveWebhookRetry(ctx, WebhookRetry{Envelope: envelope}, time.Now())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookQueueFull, err)
+	}
+
+	return nil
+}
+
+/*
+Método Close interrompe os workers, aguardando as entregas em andamento.
+Eventos ainda na fila são guardados no Postgres, para serem entregues por esta ou outra réplica.
+*/
+func (d *WebhookDispatcher) Close() {
+	d.once.Do(func() {
+		close(d.done)
+	})
+	d.wg.Wait()
+
+	for {
+		select {
+		case delivery := <-d.queue:
+			d.schedule(delivery, time.Now())
+		default:
+			return
+		}
+	}
+}
+
+/*
+Método work consome a fila de eventos até o dispatcher ser fechado.
+*/
+func (d *WebhookDispatcher) work() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case delivery := <-d.queue:
+			d.deliver(delivery)
+		}
+	}
+}
+
+/*
+Método poll devolve à fila, a cada webhookRetryInterval, as entregas cuja espera venceu.
+Reserva apenas o espaço livre na fila, para que a reserva não expire antes da entrega.
+*/
+func (d *WebhookDispatcher) poll() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(webhookRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+
+		free := min(cap(d.queue)-len(d.queue), webhookRetryBatch)
+		if free <= 0 {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), webhookStoreTimeout)
+		retries, err := d.RetryRepository.ReserveWebhookRetries(ctx, free, webhookRetryLease)
+		cancel()
+		if err != nil {
+			log.Printf("Error reading webhook retries: %v", err)
+			continue
+		}
+
+		for _, retry := range retries {
+			select {
+			case d.queue <- webhookDelivery{retry: retry, persisted: true}:
+			default:
+				/*
+				   A fila encheu com novos eventos; a reserva expira e a entrega é lida novamente.
+				*/
+			}
+		}
+	}
+}
+
+/*
+Método deliver faz uma tentativa de entrega do evento à URL de webhook da conta da sessão.
+Eventos de sessões sem conta ou sem URL de webhook são ignorados.
+Respostas 4xx, exceto 408 e 429, não são repetidas; as demais falhas são guardadas para nova tentativa.
+*/
+func (d *WebhookDispatcher) deliver(delivery webhookDelivery) {
+	envelope := delivery.retry.Envelope
+	account, err := d.AccountRepository.FindAccountBySessionID(context.Background(), envelope.SessionID)
+	if err != nil {
+		if err != ErrAccountNotFound {
+			log.Printf("Error finding account for session %s: %v", envelope.SessionID, err)
+			d.schedule(delivery, time.Now().Add(d.RetryPolicy.Delay(delivery.retry.Attempt+1)))
+			return
+		}
+		d.finish(delivery)
+		return
+	}
+	if account.WebhookURL == "" {
+		d.finish(delivery)
+		return
+	}
+
+	envelope.AccountID = account.ID
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		log.Printf("Error marshalling webhook event %s: %v", envelope.ID, err)
+		d.finish(delivery)
+		return
+	}
+
+	delivery.retry.Attempt++
+	retry, err := d.post(account, envelope, body)
+	if err == nil {
+		d.finish(delivery)
+		return
+	}
+
+	if !retry || !d.RetryPolicy.CanRetry(delivery.retry.Attempt) {
+		log.Printf("Giving up webhook event %s for account %s after %d attempts: %v", envelope.ID, account.ID, delivery.retry.Attempt, err)
+		d.finish(delivery)
+		return
+	}
+
+	d.schedule(delivery, time.Now().Add(d.RetryPolicy.Delay(delivery.retry.Attempt)))
+}
+
+/*
+Método schedule guarda a entrega para uma nova tentativa na data informada.
+*/
+func (d *WebhookDispatcher) schedule(delivery webhookDelivery, at time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookStoreTimeout)
+	defer cancel()
+
+	err := d.RetryRepository.SaveWebhookRetry(ctx, delivery.retry, at)
+	if err != nil {
+		log.Printf("Error storing webhook event %s for retry, event dropped: %v", delivery.retry.Envelope.ID, err)
+	}
+}
+
+/*
+Método finish remove a entrega guardada, quando ela veio de webhook_retries.
+*/
+func (d *WebhookDispatcher) finish(delivery webhookDelivery) {
+	if !delivery.persisted {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookStoreTimeout)
+	defer cancel()
+
+	err := d.RetryRepository.DeleteWebhookRetry(ctx, delivery.retry.Envelope.ID)
+	if err != nil {
+		log.Printf("Error removing webhook retry %s: %v", delivery.retry.Envelope.ID, err)
+	}
+}
+
+/*
+Método post faz uma tentativa de entrega do evento.
+Retorna:
+- Se a falha pode ser repetida e um erro, se houver.
+*/
+func (d *WebhookDispatcher) post(account *Account, envelope EventEnvelope, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, account.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gozap-webhook")
+	req.Header.Set("X-Gozap-Event", envelope.Type)
+	req.Header.Set("X-Gozap-Delivery", envelope.ID)
+
+	now := time.Now()
+	if secrets := account.WebhookSecrets(now); len(secrets) > 0 {
+		req.Header.Set(WebhookSignatureHeader, WebhookSignatureHeaderValue(secrets, now, body))
+	}
+
+	res, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("%w: status %d", ErrWebhookRejected, res.StatusCode)
+	retry = res.StatusCode >= 500 || res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}